@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+// Alerter evaluates the alert rules against each change made by ingestion
+// and stores the alerts they fire. Changes are read back in order from the
+// last one evaluated, so none are missed when it falls behind.
+type Alerter struct {
+	*changeFollower
+	logger  *slog.Logger
+	alerts  *models.AlertModel
+	entries *models.EntryModel
+}
+
+func NewAlerter(logger *slog.Logger, alerts *models.AlertModel, entries *models.EntryModel, progress *models.ProgressModel) (*Alerter, error) {
+	follower, err := newChangeFollower(logger, "alerts", entries, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Alerter{
+		changeFollower: follower,
+		logger:         logger,
+		alerts:         alerts,
+		entries:        entries,
+	}, nil
+}
+
+// Run evaluates the changes as they are made until ctx is cancelled.
+func (a *Alerter) Run(ctx context.Context) {
+	a.follow(ctx, func(c models.EntryChange) {
+		start := time.Now()
+
+		fired, err := a.evaluate(c.Entry, start)
+		if err != nil {
+			a.logger.Error("evaluating alert rules", "guid", c.Entry.GUID, "error", err)
+			return
+		}
+
+		if fired > 0 {
+			a.logger.Info("Alert",
+				"time_ms", time.Since(start),
+				"guid", c.Entry.GUID,
+				"change", c.Change,
+				"fired", fired)
+		}
+	})
+}
+
+// evaluate checks e, as just stored, against every rule and returns the
+// number of alerts fired.
+func (a *Alerter) evaluate(e models.Entry, now time.Time) (fired int, err error) {
+	rules, err := a.alerts.Rules()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, rule := range rules {
+		var alert *models.Alert
+
+		switch rule.Kind {
+		case models.RuleMagnitude:
+			alert, err = a.evaluateMagnitude(rule, e, now)
+		case models.RuleCount:
+			alert, err = a.evaluateCount(rule, e)
+		}
+		if err != nil {
+			return fired, err
+		}
+
+		if alert != nil {
+			alert.RuleID = rule.ID
+			alert.GUID = e.GUID
+			alert.Magnitude = models.Widen(e.Magnitude)
+			alert.EventTime = e.Time
+			alert.FiredAt = now
+			if _, err := a.alerts.Fire(*alert); err != nil {
+				return fired, err
+			}
+			fired = fired + 1
+		}
+	}
+
+	return fired, nil
+}
+
+// evaluateMagnitude fires when e comes into the rule. A revision of an event
+// that already fired doesn't fire again unless an earlier revision took it
+// out of the rule, which clears the alert.
+func (a *Alerter) evaluateMagnitude(rule models.AlertRule, e models.Entry, now time.Time) (*models.Alert, error) {
+	last, err := a.alerts.LatestForEvent(rule.ID, e.GUID)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		return nil, err
+	}
+	holds := err == nil && last.ClearedAt == nil
+
+	switch matches := rule.Matches(e); {
+	case matches && !holds:
+		return &models.Alert{}, nil
+	case !matches && holds:
+		return nil, a.alerts.Clear(last.ID, now)
+	default:
+		return nil, nil
+	}
+}
+
+// evaluateCount fires when the events the rule considers in the window
+// ending at e, within e's cell, reach the rule's count. A cell fires once
+// for events within a window of each other.
+func (a *Alerter) evaluateCount(rule models.AlertRule, e models.Entry) (*models.Alert, error) {
+	if e.Time == nil || !rule.Matches(e) {
+		return nil, nil
+	}
+
+	cell, q := rule.Cell(e)
+
+	last, err := a.alerts.LatestForCell(rule.ID, cell)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		return nil, err
+	}
+	if err == nil && last.EventTime != nil && e.Time.Sub(*last.EventTime).Abs() < rule.Window {
+		return nil, nil
+	}
+
+	var count int
+	q = q.Since(e.Time.Add(-rule.Window)).Until(*e.Time)
+	err = a.entries.Each(q, func(candidate models.Entry) error {
+		if rule.Matches(candidate) {
+			count = count + 1
+		}
+		return nil
+	})
+	if err != nil || count < rule.MinCount {
+		return nil, err
+	}
+
+	return &models.Alert{Cell: cell, Count: count}, nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+func TestAlerter(t *testing.T) {
+	db := newTestDB(t)
+	alerts := &models.AlertModel{DB: db}
+	entries := &models.EntryModel{DB: db}
+	alerter, err := NewAlerter(discardLogger(), alerts, entries, &models.ProgressModel{DB: db})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	minMag := 4.0
+	montreal, err := alerts.InsertRule(models.AlertRule{
+		Name:         "M4+ near Montreal",
+		Kind:         models.RuleMagnitude,
+		Region:       models.Region{Circle: &models.Circle{Latitude: 45.5, Longitude: -73.6, RadiusKm: 200}},
+		MinMagnitude: &minMag,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	swarms, err := alerts.InsertRule(models.AlertRule{Name: "Swarms", Kind: models.RuleCount, MinCount: 5, Window: 24 * time.Hour, CellKm: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	now := base
+
+	// ingest stores e and evaluates it as the ingestion would, returning the
+	// number of alerts fired
+	ingest := func(e models.Entry) int {
+		t.Helper()
+		if e.Time == nil {
+			e.Time = &base
+		}
+		if _, err := entries.Insert(e); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Minute)
+		fired, err := alerter.evaluate(e, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fired
+	}
+
+	// Trois-Rivières is within 200 km of Montreal, Toronto isn't
+	quake := models.Entry{GUID: "a", Content: "1", Latitude: 46.35, Longitude: -72.55, Magnitude: 3.9}
+	for _, step := range []struct {
+		name      string
+		magnitude float32
+		fired     int
+		cleared   bool
+	}{
+		{"below the threshold", 3.9, 0, false},
+		{"revised across the threshold", 4.1, 1, false},
+		{"revised again above it", 4.3, 0, false},
+		{"revised below it", 3.5, 0, true},
+		{"revised back above it", 4.5, 1, false},
+	} {
+		quake.Magnitude = step.magnitude
+		quake.Content = step.name
+		if fired := ingest(quake); fired != step.fired {
+			t.Errorf("%s: fired %d alerts, want %d", step.name, fired, step.fired)
+		}
+
+		if latest, err := alerts.LatestForEvent(montreal, "a"); err == nil && (latest.ClearedAt != nil) != step.cleared {
+			t.Errorf("%s: got cleared at %v", step.name, latest.ClearedAt)
+		}
+	}
+	if _, total, _ := alerts.List(models.AlertQuery{RuleID: montreal}, 10, 0); total != 2 {
+		t.Errorf("got %d alerts for the event, want 2", total)
+	}
+	if latest, _ := alerts.LatestForEvent(montreal, "a"); latest.Magnitude != 4.5 || latest.ClearedAt != nil {
+		t.Errorf("got %+v, want the M4.5 alert holding", latest)
+	}
+
+	if fired := ingest(models.Entry{GUID: "far", Content: "1", Latitude: 43.65, Longitude: -79.38, Magnitude: 6}); fired != 0 {
+		t.Errorf("fired %d alerts for an event far away", fired)
+	}
+
+	// small events near Vancouver an hour apart, after one the day before
+	// that falls outside the window
+	at := func(hours int) *time.Time {
+		t := base.Add(time.Duration(hours) * time.Hour)
+		return &t
+	}
+	swarm := func(guid string, hours int) models.Entry {
+		return models.Entry{GUID: guid, Content: "1", Latitude: 49.28, Longitude: -123.12, Magnitude: 2, Time: at(hours)}
+	}
+	for i, e := range []models.Entry{swarm("s0", -25), swarm("s1", 1), swarm("s2", 2), swarm("s3", 3), swarm("s4", 4)} {
+		if fired := ingest(e); fired != 0 {
+			t.Errorf("event %d fired %d alerts", i, fired)
+		}
+	}
+
+	if fired := ingest(swarm("s5", 5)); fired != 1 {
+		t.Fatalf("fired %d alerts for the fifth event in the window, want 1", fired)
+	}
+	cell, _ := models.AlertRule{CellKm: 50}.Cell(swarm("", 0))
+	a, err := alerts.LatestForCell(swarms, cell)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.GUID != "s5" || a.Count != 5 {
+		t.Errorf("got %+v, want 5 events to s5", a)
+	}
+
+	if fired := ingest(swarm("s6", 6)); fired != 0 {
+		t.Errorf("fired %d alerts for a sixth event in the same window", fired)
+	}
+}
+
+func TestAlerterCatchesUp(t *testing.T) {
+	db := newTestDB(t)
+	alerts := &models.AlertModel{DB: db}
+	entries := &models.EntryModel{DB: db}
+	progress := &models.ProgressModel{DB: db}
+
+	minMag := 4.0
+	rule, err := alerts.InsertRule(models.AlertRule{Name: "M4+", Kind: models.RuleMagnitude, MinMagnitude: &minMag})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quake := func(guid string) models.Entry {
+		return models.Entry{GUID: guid, Content: "1", Latitude: 45, Longitude: -75, Magnitude: 5}
+	}
+
+	// events stored before the alerter first starts don't fire
+	insertTestEntries(t, entries, quake("before"))
+	alerter, err := NewAlerter(discardLogger(), alerts, entries, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// more changes than a page, made while the alerter isn't running
+	var burst []models.Entry
+	for i := range 2*followPage + 5 {
+		burst = append(burst, quake(fmt.Sprintf("burst %d", i)))
+	}
+	insertTestEntries(t, entries, burst...)
+
+	run := func(a *Alerter, want int) {
+		t.Helper()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			a.Run(ctx)
+			close(done)
+		}()
+		defer func() {
+			cancel()
+			<-done
+		}()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			_, total, err := alerts.List(models.AlertQuery{RuleID: rule}, 1, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("got %d alerts, want %d", total, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	run(alerter, len(burst))
+
+	// a restarted alerter carries on after the last change it evaluated
+	insertTestEntries(t, entries, quake("after restart"))
+	restarted, err := NewAlerter(discardLogger(), alerts, entries, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(restarted, len(burst)+1)
+
+	if last, err := progress.Get("alerts"); err != nil || last != int64(len(burst)+2) {
+		t.Errorf("got progress %d, %v, want %d", last, err, len(burst)+2)
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+const (
+	// maxAlertWindow is the longest window of a count rule.
+	maxAlertWindow = 30 * 24 * time.Hour
+	// maxRuleName is the longest name of an alert rule.
+	maxRuleName = 200
+)
+
+// alertRuleRequest is the body of a request creating an alert rule.
+type alertRuleRequest struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	regionJSON
+	MinMag   *float64 `json:"minmag"`
+	MinCount *int     `json:"mincount"`
+	Window   string   `json:"window"`
+	CellKm   *float64 `json:"cellkm"`
+}
+
+// alertRule is a models.AlertRule in a response.
+type alertRule struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	regionJSON
+	MinMag    *float64  `json:"minmag,omitempty"`
+	MinCount  int       `json:"mincount,omitempty"`
+	Window    string    `json:"window,omitempty"`
+	CellKm    float64   `json:"cellkm,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newAlertRule(r models.AlertRule) alertRule {
+	rule := alertRule{
+		ID:         r.ID,
+		Name:       r.Name,
+		Type:       r.Kind,
+		regionJSON: newRegionJSON(r.Region),
+		MinMag:     r.MinMagnitude,
+		CreatedAt:  r.CreatedAt,
+	}
+	if r.Kind == models.RuleCount {
+		rule.MinCount = r.MinCount
+		rule.Window = r.Window.String()
+		rule.CellKm = r.CellKm
+	}
+	return rule
+}
+
+// alert is a models.Alert in a response.
+type alert struct {
+	ID        int64      `json:"id"`
+	RuleID    int64      `json:"rule_id"`
+	RuleName  string     `json:"rule_name"`
+	Type      string     `json:"type"`
+	EventID   string     `json:"event_id"`
+	Magnitude float64    `json:"magnitude"`
+	EventTime *time.Time `json:"event_time"`
+	Cell      string     `json:"cell,omitempty"`
+	Count     int        `json:"count,omitempty"`
+	FiredAt   time.Time  `json:"fired_at"`
+	ClearedAt *time.Time `json:"cleared_at,omitempty"`
+}
+
+func newAlert(a models.Alert) alert {
+	return alert{
+		ID:        a.ID,
+		RuleID:    a.RuleID,
+		RuleName:  a.RuleName,
+		Type:      a.Kind,
+		EventID:   a.GUID,
+		Magnitude: a.Magnitude,
+		EventTime: a.EventTime,
+		Cell:      a.Cell,
+		Count:     a.Count,
+		FiredAt:   a.FiredAt,
+		ClearedAt: a.ClearedAt,
+	}
+}
+
+// parseAlertRuleRequest decodes and validates the body of r, responding with
+// a problem when it is not a valid rule.
+func parseAlertRuleRequest(w http.ResponseWriter, r *http.Request, logger *slog.Logger) (models.AlertRule, bool) {
+	var req alertRuleRequest
+	if !decodeBody(w, r, logger, &req, "an alert rule") {
+		return models.AlertRule{}, false
+	}
+
+	invalid := &validationError{}
+
+	if len(req.Name) > maxRuleName {
+		invalid.add("name", fmt.Sprintf("must be at most %d characters", maxRuleName))
+	}
+
+	rule := models.AlertRule{
+		Name:         req.Name,
+		Kind:         req.Type,
+		Region:       req.region(invalid),
+		MinMagnitude: req.MinMag,
+	}
+	checkRange(invalid, "minmag", req.MinMag, -2, 10)
+
+	switch req.Type {
+	case models.RuleMagnitude:
+		if req.MinMag == nil {
+			invalid.add("minmag", "is required")
+		}
+		if req.MinCount != nil || req.Window != "" || req.CellKm != nil {
+			invalid.add("type", "mincount, window and cellkm only apply to count rules")
+		}
+
+	case models.RuleCount:
+		if req.MinCount == nil {
+			invalid.add("mincount", "is required")
+		} else if *req.MinCount < 2 {
+			invalid.add("mincount", fmt.Sprintf("must be at least 2, got %d", *req.MinCount))
+		} else {
+			rule.MinCount = *req.MinCount
+		}
+
+		window, err := time.ParseDuration(req.Window)
+		switch {
+		case req.Window == "":
+			invalid.add("window", "is required")
+		case err != nil:
+			invalid.add("window", fmt.Sprintf("expected a duration such as 24h, got %q", req.Window))
+		case window < time.Minute || window > maxAlertWindow:
+			invalid.add("window", fmt.Sprintf("must be between 1m and %s, got %s", maxAlertWindow, window))
+		}
+		rule.Window = window.Truncate(time.Second)
+
+		rule.CellKm = requireRange(invalid, "cellkm", req.CellKm, 1, 1000)
+
+	default:
+		invalid.add("type", fmt.Sprintf("expected %s or %s, got %q", models.RuleMagnitude, models.RuleCount, req.Type))
+	}
+
+	if err := invalid.err(); err != nil {
+		writeInvalid(w, r, logger, err)
+		return models.AlertRule{}, false
+	}
+
+	return rule, true
+}
+
+func handleCreateAlertRule(logger *slog.Logger, alerts *models.AlertModel) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			rule, ok := parseAlertRuleRequest(w, r, logger)
+			if !ok {
+				return
+			}
+
+			id, err := alerts.InsertRule(rule)
+			if errors.Is(err, models.ErrLimit) {
+				writeProblem(w, r, logger, http.StatusConflict,
+					fmt.Sprintf("there are %d alert rules already, the most the server evaluates", alerts.MaxRules))
+				return
+			}
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			created, err := alerts.GetRule(id)
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			w.Header().Set("Location", fmt.Sprintf("/api/v1/alerts/rules/%d", id))
+			writeData(w, logger, http.StatusCreated, "Created alert rule", newAlertRule(created))
+
+			logger.Info("CreateAlertRule", "time_ms", time.Since(start), "id", id, "type", rule.Kind)
+		},
+	)
+}
+
+func handleListAlertRules(logger *slog.Logger, alerts *models.AlertModel) http.Handler {
+	type Response struct {
+		Message string      `json:"message"`
+		Data    []alertRule `json:"data"`
+		Count   int         `json:"count"`
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			rules, err := alerts.Rules()
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			resp := Response{
+				Message: "Alert rules",
+				Data:    []alertRule{},
+			}
+			for _, rule := range rules {
+				resp.Data = append(resp.Data, newAlertRule(rule))
+			}
+			resp.Count = len(resp.Data)
+
+			js, _ := json.Marshal(resp)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err = w.Write(js)
+			if err != nil {
+				logger.Error("writing response", "error", err)
+				return
+			}
+		},
+	)
+}
+
+func handleGetAlertRule(logger *slog.Logger, alerts *models.AlertModel) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			id, ok := pathID(w, r, logger, "alert rule")
+			if !ok {
+				return
+			}
+
+			rule, err := alerts.GetRule(id)
+			if err != nil {
+				writeLookupError(w, r, logger, "alert rule", id, err)
+				return
+			}
+
+			writeData(w, logger, http.StatusOK, "Alert rule", newAlertRule(rule))
+		},
+	)
+}
+
+// handleDeleteAlertRule removes a rule along with the alerts it fired.
+func handleDeleteAlertRule(logger *slog.Logger, alerts *models.AlertModel) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			id, ok := pathID(w, r, logger, "alert rule")
+			if !ok {
+				return
+			}
+
+			if err := alerts.DeleteRule(id); err != nil {
+				writeLookupError(w, r, logger, "alert rule", id, err)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+
+			logger.Info("DeleteAlertRule", "id", id)
+		},
+	)
+}
+
+// handleListAlerts returns fired alerts newest first, optionally only those
+// of one rule or fired since a time.
+func handleListAlerts(logger *slog.Logger, alerts *models.AlertModel) http.Handler {
+	type Response struct {
+		Message string  `json:"message"`
+		Data    []alert `json:"data"`
+		Count   int     `json:"count"`
+		Total   int     `json:"total"`
+		Limit   int     `json:"limit"`
+		Offset  int     `json:"offset"`
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			invalid := &validationError{}
+			limit, offset := parsePage(r, 20, 100, invalid)
+
+			var q models.AlertQuery
+			if v := r.URL.Query().Get("rule"); v != "" {
+				id, err := strconv.ParseInt(v, 10, 64)
+				if err != nil || id < 1 {
+					invalid.add("rule", fmt.Sprintf("expected the id of an alert rule, got %q", v))
+				}
+				q.RuleID = id
+			}
+
+			since, err := parseTimeParam(r.URL.Query().Get("since"))
+			if err != nil {
+				invalid.add("since", err.Error())
+			}
+			q.Since = since
+
+			if err := invalid.err(); err != nil {
+				writeInvalid(w, r, logger, err)
+				return
+			}
+
+			results, total, err := alerts.List(q, limit, offset)
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			resp := Response{
+				Message: "Alerts",
+				Data:    []alert{},
+				Total:   total,
+				Limit:   limit,
+				Offset:  offset,
+			}
+			for _, a := range results {
+				resp.Data = append(resp.Data, newAlert(a))
+			}
+			resp.Count = len(resp.Data)
+
+			js, _ := json.Marshal(resp)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err = w.Write(js)
+			if err != nil {
+				logger.Error("writing response", "error", err)
+				return
+			}
+
+			logger.Info("Alerts", "time_ms", time.Since(start), "count", resp.Count)
+		},
+	)
+}
+
+func handleGetAlert(logger *slog.Logger, alerts *models.AlertModel) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			id, ok := pathID(w, r, logger, "alert")
+			if !ok {
+				return
+			}
+
+			a, err := alerts.Get(id)
+			if err != nil {
+				writeLookupError(w, r, logger, "alert", id, err)
+				return
+			}
+
+			writeData(w, logger, http.StatusOK, "Alert", newAlert(a))
+		},
+	)
+}
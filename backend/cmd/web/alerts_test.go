@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+func TestHandleAlerts(t *testing.T) {
+	alerts := &models.AlertModel{DB: newTestDB(t)}
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/v1/alerts/rules", handleCreateAlertRule(discardLogger(), alerts))
+	mux.Handle("GET /api/v1/alerts/rules", handleListAlertRules(discardLogger(), alerts))
+	mux.Handle("GET /api/v1/alerts/rules/{id}", handleGetAlertRule(discardLogger(), alerts))
+	mux.Handle("DELETE /api/v1/alerts/rules/{id}", handleDeleteAlertRule(discardLogger(), alerts))
+	mux.Handle("GET /api/v1/alerts", handleListAlerts(discardLogger(), alerts))
+	mux.Handle("GET /api/v1/alerts/{id}", handleGetAlert(discardLogger(), alerts))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	type Rule struct {
+		ID       int64
+		Type     string
+		RadiusKm *float64
+		MinMag   *float64
+		MinCount int
+		Window   string
+		CellKm   float64
+	}
+	var resp struct {
+		Data Rule
+	}
+
+	rec := do(http.MethodPost, "/api/v1/alerts/rules", `{"name":"Montreal","type":"magnitude","lat":45.5,"lng":-73.6,"radiuskm":200,"minmag":4}`)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Location") != "/api/v1/alerts/rules/1" {
+		t.Fatalf("got status %d at %q: %s", rec.Code, rec.Header().Get("Location"), rec.Body)
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.Type != "magnitude" || *resp.Data.RadiusKm != 200 || *resp.Data.MinMag != 4 || resp.Data.Window != "" {
+		t.Errorf("got %+v", resp.Data)
+	}
+
+	rec = do(http.MethodPost, "/api/v1/alerts/rules", `{"name":"Swarms","type":"count","mincount":5,"window":"24h","cellkm":50}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	resp.Data = Rule{}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.ID != 2 || resp.Data.MinCount != 5 || resp.Data.Window != "24h0m0s" || resp.Data.CellKm != 50 || resp.Data.MinMag != nil {
+		t.Errorf("got %+v", resp.Data)
+	}
+
+	for _, tt := range []struct {
+		body  string
+		param string
+	}{
+		{`{"type":"magnitude"}`, "minmag"},
+		{`{"type":"magnitude","minmag":4,"window":"1h"}`, "type"},
+		{`{"type":"count","window":"1h","cellkm":50}`, "mincount"},
+		{`{"type":"count","mincount":1,"window":"1h","cellkm":50}`, "mincount"},
+		{`{"type":"count","mincount":5,"window":"a day","cellkm":50}`, "window"},
+		{`{"type":"count","mincount":5,"window":"1000h","cellkm":50}`, "window"},
+		{`{"type":"count","mincount":5,"window":"1h"}`, "cellkm"},
+		{`{"type":"swarm"}`, "type"},
+		{`{"type":"magnitude","minmag":4,"coords":"1,2,3"}`, "coords"},
+	} {
+		rec := do(http.MethodPost, "/api/v1/alerts/rules", tt.body)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tt.param) {
+			t.Errorf("%s: got status %d: %s", tt.body, rec.Code, rec.Body)
+		}
+	}
+
+	at := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	for _, a := range []models.Alert{
+		{RuleID: 1, GUID: "a", Magnitude: 4.2, EventTime: &at, FiredAt: at},
+		{RuleID: 2, GUID: "b", Magnitude: 2, EventTime: &at, Cell: "301:165", Count: 5, FiredAt: at.Add(time.Hour)},
+	} {
+		if _, err := alerts.Fire(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var list struct {
+		Data  []alert
+		Total int
+	}
+	rec = do(http.MethodGet, "/api/v1/alerts?rule=2", "")
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 1 || list.Data[0].EventID != "b" || list.Data[0].RuleName != "Swarms" || list.Data[0].Count != 5 {
+		t.Errorf("got %+v", list)
+	}
+	rec = do(http.MethodGet, "/api/v1/alerts?since=2025-10-01T00:30:00Z", "")
+	list.Data = nil
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Total != 1 || list.Data[0].EventID != "b" {
+		t.Errorf("got %+v", list)
+	}
+	for _, query := range []string{"rule=x", "since=yesterday", "limit=0"} {
+		if rec := do(http.MethodGet, "/api/v1/alerts?"+query, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want 400", query, rec.Code)
+		}
+	}
+
+	if rec := do(http.MethodGet, "/api/v1/alerts/1", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"event_id":"a"`) {
+		t.Errorf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	if rec := do(http.MethodDelete, "/api/v1/alerts/rules/1", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("got status %d for delete", rec.Code)
+	}
+	for _, path := range []string{"/api/v1/alerts/rules/1", "/api/v1/alerts/1", "/api/v1/alerts/x"} {
+		if rec := do(http.MethodGet, path, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: got status %d, want 404", path, rec.Code)
+		}
+	}
+
+	rec = do(http.MethodGet, "/api/v1/alerts/rules", "")
+	var rules struct {
+		Count int
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&rules); err != nil {
+		t.Fatal(err)
+	}
+	if rules.Count != 1 {
+		t.Errorf("got %d rules after delete, want 1", rules.Count)
+	}
+
+	alerts.MaxRules = 1
+	rec = do(http.MethodPost, "/api/v1/alerts/rules", `{"name":"Montreal","type":"magnitude","minmag":4}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("got status %d past the limit of rules, want 409: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+// Clusters are cut from a grid of cellsPerTile by cellsPerTile cells on each
+// 256 pixel map tile, so a cell is about 64 pixels wide at any zoom.
+const (
+	cellsPerTile = 4
+	maxZoom      = 22
+)
+
+// cellSizeDeg returns the width in degrees of a cluster cell at zoom.
+func cellSizeDeg(zoom int) float64 {
+	return 360 / math.Exp2(float64(zoom)) / cellsPerTile
+}
+
+func handleGetClusters(logger *slog.Logger, entries *models.EntryModel) http.Handler {
+	type Cell struct {
+		ID           string     `json:"id"`
+		Count        int        `json:"count"`
+		MaxMagnitude float32    `json:"max_magnitude"`
+		Latest       *time.Time `json:"latest"`
+		Latitude     float64    `json:"latitude"`
+		Longitude    float64    `json:"longitude"`
+		Bounds       [4]float64 `json:"bounds"`
+	}
+
+	type Response struct {
+		Message     string  `json:"message"`
+		Zoom        int     `json:"zoom"`
+		CellSizeDeg float64 `json:"cell_size_deg"`
+		Data        []Cell  `json:"data"`
+		Count       int     `json:"count"`
+		Events      int     `json:"events"`
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			invalid := &validationError{}
+			q := parseAggregateQuery(r, invalid)
+
+			// every cluster in coords is returned, a page of them would
+			// leave out part of the map
+			for _, name := range []string{"limit", "offset"} {
+				if r.URL.Query().Has(name) {
+					invalid.add(name, "is not supported here, every cluster in coords is returned")
+				}
+			}
+
+			zoom, err := parseIntParam(r.URL.Query().Get("zoom"), 0)
+			switch {
+			case err != nil:
+				invalid.add("zoom", err.Error())
+			case !r.URL.Query().Has("zoom"):
+				invalid.add("zoom", "is required")
+			case zoom > maxZoom:
+				invalid.add("zoom", fmt.Sprintf("must be at most %d, got %d", maxZoom, zoom))
+			}
+
+			if err := invalid.err(); err != nil {
+				writeInvalid(w, r, logger, err)
+				return
+			}
+
+			size := cellSizeDeg(zoom)
+
+			clusters, err := entries.Clusters(q, size)
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			resp := Response{
+				Message:     "Clusters",
+				Zoom:        zoom,
+				CellSizeDeg: size,
+				Data:        []Cell{},
+			}
+			for _, c := range clusters {
+				swlng := -180 + float64(c.Column)*size
+				swlat := -90 + float64(c.Row)*size
+
+				resp.Data = append(resp.Data, Cell{
+					ID:           fmt.Sprintf("%d/%d/%d", zoom, c.Column, c.Row),
+					Count:        c.Count,
+					MaxMagnitude: c.MaxMagnitude,
+					Latest:       c.Latest,
+					Latitude:     c.Latitude,
+					Longitude:    c.Longitude,
+					Bounds:       [4]float64{swlng, swlat, min(swlng+size, 180), min(swlat+size, 90)},
+				})
+				resp.Events = resp.Events + c.Count
+			}
+			resp.Count = len(resp.Data)
+
+			js, _ := json.Marshal(resp)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err = w.Write(js)
+			if err != nil {
+				logger.Error("writing response", "error", err)
+				return
+			}
+
+			logger.Info("GetClusters",
+				"time_ms", time.Since(start),
+				"coords", r.URL.Query().Get("coords"),
+				"zoom", zoom,
+				"count", resp.Count)
+		},
+	)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+func TestHandleGetClusters(t *testing.T) {
+	entries := &models.EntryModel{DB: newTestDB(t)}
+	insertTestEntries(t, entries,
+		models.Entry{GUID: "a", Latitude: 49.1, Longitude: -122.7, Magnitude: 2.6},
+		models.Entry{GUID: "b", Latitude: 48.4, Longitude: -123.4, Magnitude: 1.1},
+		models.Entry{GUID: "c", Latitude: 45.5, Longitude: -73.6, Magnitude: 1.2},
+	)
+	handler := handleGetClusters(discardLogger(), entries)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/events/clusters?coords=-141,41,-52,84&zoom=3", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp struct {
+		CellSizeDeg float64 `json:"cell_size_deg"`
+		Events      int
+		Data        []struct {
+			ID     string
+			Count  int
+			Bounds [4]float64
+		}
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.CellSizeDeg != 11.25 || resp.Events != 3 || len(resp.Data) != 2 {
+		t.Fatalf("got %+v", resp)
+	}
+	if c := resp.Data[0]; c.ID != "3/5/12" || c.Count != 2 || c.Bounds != [4]float64{-123.75, 45, -112.5, 56.25} {
+		t.Errorf("got cell %+v", c)
+	}
+
+	for _, query := range []string{
+		"coords=-141,41,-52,84",
+		"zoom=3",
+		"coords=-141,41,-52,84&zoom=30",
+		"coords=-141,41,-52,84&zoom=3&limit=10",
+		"coords=-141,41,-52,84&zoom=3&offset=10",
+		"coords=-141,41,-52,84&zoom=3&lat=45&lng=-73&radiuskm=100",
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/events/clusters?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want 400", query, rec.Code)
+		}
+	}
+}
@@ -5,12 +5,25 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
+	"net/mail"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 )
 
 //go:embed "schema.sql"
 var schemaSQL []byte
 
+// SourceConfig describes one earthquake feed to poll.
+type SourceConfig struct {
+	Name     string
+	Kind     string
+	URL      string
+	Interval time.Duration
+}
+
 type Config struct {
 	Host     string
 	Port     string
@@ -18,6 +31,65 @@ type Config struct {
 	DSN      string
 	Schema   string
 	AtomFeed string
+	Sources  []SourceConfig
+
+	PollInterval   time.Duration
+	PollJitter     time.Duration
+	PollBackoff    time.Duration
+	PollMaxBackoff time.Duration
+	UpdateCooldown time.Duration
+
+	// APIToken authorizes the requests managing subscriptions and alert
+	// rules and importing events, which are refused when it is empty. It is
+	// read from QUAKES_API_TOKEN rather than a flag so it doesn't show in the
+	// process list.
+	APIToken string
+
+	// MaxPageSize caps the events returned by one page of a listing
+	MaxPageSize int
+
+	// StreamHeartbeat is the time between comments sent to keep idle
+	// event streams open through proxies
+	StreamHeartbeat time.Duration
+
+	// MaxWebSockets caps the WebSocket connections served at once
+	MaxWebSockets int
+	// WebSocketBuffer is how many events a WebSocket client can fall behind
+	// before it is disconnected
+	WebSocketBuffer int
+
+	// WebhookAttempts is how many times a change is sent to a webhook
+	// before giving up, with the wait between attempts doubling from
+	// WebhookBackoff up to WebhookMaxBackoff
+	WebhookAttempts   int
+	WebhookBackoff    time.Duration
+	WebhookMaxBackoff time.Duration
+	// WebhookWorkers caps the webhook deliveries made at once
+	WebhookWorkers int
+	// WebhookAllowPrivate lets webhooks reach loopback, private and
+	// link-local addresses, which are refused by default
+	WebhookAllowPrivate bool
+
+	// MaxAlertRules caps the alert rules, which are each evaluated on every
+	// change
+	MaxAlertRules int
+
+	// SMTPAddr is the host:port of the relay digests are sent through,
+	// digests aren't sent when it is empty. SMTPPassword is read from
+	// QUAKES_SMTP_PASSWORD, like APIToken.
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	// PublicURL is where the server is reached from outside, for the
+	// unsubscribe links in digests
+	PublicURL string
+	// DigestInterval is the time between checks for digests due to be sent
+	DigestInterval time.Duration
+
+	// DeclusterInterval is the least time between two passes regrouping the
+	// events into sequences
+	DeclusterInterval time.Duration
 }
 
 func NewConfiguration() *Config {
@@ -26,6 +98,39 @@ func NewConfiguration() *Config {
 	port := flag.String("port", "4000", "Listen on port")
 	dsn := flag.String("dsn", "file:quakes.sqlite3", "Database connection string")
 	schema := flag.String("schema", "./cmd/web/schema.sql", "Custom database schema")
+	pollInterval := flag.Duration("poll-interval", 5*time.Minute, "Time between feed polls")
+	pollJitter := flag.Duration("poll-jitter", 30*time.Second, "Maximum random delay added to each poll")
+	pollBackoff := flag.Duration("poll-backoff", 30*time.Second, "Initial delay before retrying a failed poll")
+	pollMaxBackoff := flag.Duration("poll-max-backoff", 30*time.Minute, "Maximum delay between failed polls")
+	updateCooldown := flag.Duration("update-cooldown", 5*time.Minute, "Minimum time between manual update requests")
+	maxPageSize := flag.Int("max-page-size", 1000, "Maximum number of events in one page of a listing")
+	streamHeartbeat := flag.Duration("stream-heartbeat", 15*time.Second, "Time between heartbeats on idle event streams")
+	maxWebSockets := flag.Int("max-websockets", 1000, "Maximum number of WebSocket connections served at once")
+	webSocketBuffer := flag.Int("websocket-buffer", 64, "Number of events a WebSocket client can fall behind before it is disconnected")
+	webhookAttempts := flag.Int("webhook-attempts", 5, "Maximum number of attempts to deliver a change to a webhook")
+	webhookBackoff := flag.Duration("webhook-backoff", 30*time.Second, "Initial delay before retrying a failed webhook delivery")
+	webhookMaxBackoff := flag.Duration("webhook-max-backoff", 30*time.Minute, "Maximum delay between webhook delivery attempts")
+	webhookWorkers := flag.Int("webhook-workers", 8, "Maximum number of webhook deliveries made at once")
+	webhookAllowPrivate := flag.Bool("webhook-allow-private", false, "Allow webhooks to loopback, private and link-local addresses")
+	maxAlertRules := flag.Int("max-alert-rules", 100, "Maximum number of alert rules")
+	smtpAddr := flag.String("smtp-addr", "", "SMTP relay to send digests through as host:port, digests aren't sent when empty")
+	smtpUsername := flag.String("smtp-username", "", "Username for the SMTP relay, no authentication when empty, the password is read from QUAKES_SMTP_PASSWORD")
+	smtpFrom := flag.String("smtp-from", "quakes@localhost", "Sender address of digests")
+	publicURL := flag.String("public-url", "", "URL the server is reached at from outside, for links in digests, http://host:port when empty")
+	digestInterval := flag.Duration("digest-interval", 5*time.Minute, "Time between checks for digests due to be sent")
+	declusterInterval := flag.Duration("decluster-interval", time.Minute, "Minimum time between two passes grouping events into sequences")
+
+	// sources are given as name,kind,url[,interval] and the flag can be
+	// repeated to poll several feeds at once.
+	var sources []SourceConfig
+	flag.Func("source", "Feed to poll as name,kind,url[,interval] where kind is nrcan, usgs or quakeml (repeatable)", func(v string) error {
+		source, err := parseSourceFlag(v)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, source)
+		return nil
+	})
 	flag.Parse()
 
 	// the schema.sql file is embedded during build
@@ -48,7 +153,105 @@ func NewConfiguration() *Config {
 		DSN:      *dsn,
 		Schema:   string(schemaSQL),
 		AtomFeed: "https://www.earthquakescanada.nrcan.gc.ca/cache/earthquakes/canada-en.atom",
+
+		PollInterval:   *pollInterval,
+		PollJitter:     *pollJitter,
+		PollBackoff:    *pollBackoff,
+		PollMaxBackoff: *pollMaxBackoff,
+		UpdateCooldown: *updateCooldown,
+
+		APIToken: os.Getenv("QUAKES_API_TOKEN"),
+
+		MaxPageSize:     *maxPageSize,
+		StreamHeartbeat: *streamHeartbeat,
+		MaxWebSockets:   *maxWebSockets,
+		WebSocketBuffer: *webSocketBuffer,
+
+		WebhookAttempts:     *webhookAttempts,
+		WebhookBackoff:      *webhookBackoff,
+		WebhookMaxBackoff:   *webhookMaxBackoff,
+		WebhookWorkers:      *webhookWorkers,
+		WebhookAllowPrivate: *webhookAllowPrivate,
+
+		MaxAlertRules: *maxAlertRules,
+
+		SMTPAddr:       *smtpAddr,
+		SMTPUsername:   *smtpUsername,
+		SMTPPassword:   os.Getenv("QUAKES_SMTP_PASSWORD"),
+		SMTPFrom:       *smtpFrom,
+		PublicURL:      strings.TrimSuffix(*publicURL, "/"),
+		DigestInterval: *digestInterval,
+
+		DeclusterInterval: *declusterInterval,
+	}
+
+	if config.MaxPageSize < 1 {
+		log.Fatal("max-page-size must be at least 1")
+	}
+	if config.StreamHeartbeat <= 0 {
+		log.Fatal("stream-heartbeat must be positive")
+	}
+	if config.MaxWebSockets < 1 {
+		log.Fatal("max-websockets must be at least 1")
+	}
+	if config.WebSocketBuffer < 1 {
+		log.Fatal("websocket-buffer must be at least 1")
+	}
+	if config.WebhookAttempts < 1 {
+		log.Fatal("webhook-attempts must be at least 1")
+	}
+	if config.WebhookWorkers < 1 {
+		log.Fatal("webhook-workers must be at least 1")
+	}
+	if config.MaxAlertRules < 1 {
+		log.Fatal("max-alert-rules must be at least 1")
+	}
+	if _, err := mail.ParseAddress(config.SMTPFrom); err != nil {
+		log.Fatalf("smtp-from: %s", err)
+	}
+	if config.PublicURL == "" {
+		config.PublicURL = "http://" + net.JoinHostPort(config.Host, config.Port)
+	}
+	if u, err := url.Parse(config.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		log.Fatalf("public-url: expected an absolute http or https URL, got %q", config.PublicURL)
+	}
+	if config.DigestInterval <= 0 {
+		log.Fatal("digest-interval must be positive")
+	}
+	if config.DeclusterInterval < 0 {
+		log.Fatal("decluster-interval must not be negative")
+	}
+
+	if len(sources) == 0 {
+		sources = append(sources, SourceConfig{Name: "nrcan", Kind: "nrcan", URL: config.AtomFeed})
 	}
 
+	for i := range sources {
+		if sources[i].Interval == 0 {
+			sources[i].Interval = config.PollInterval
+		}
+	}
+	config.Sources = sources
+
 	return &config
 }
+
+func parseSourceFlag(v string) (source SourceConfig, err error) {
+	parts := strings.Split(v, ",")
+	if len(parts) < 3 || len(parts) > 4 {
+		return source, fmt.Errorf("expected name,kind,url[,interval], got %q", v)
+	}
+
+	source.Name = parts[0]
+	source.Kind = parts[1]
+	source.URL = parts[2]
+
+	if len(parts) == 4 {
+		source.Interval, err = time.ParseDuration(parts[3])
+		if err != nil {
+			return source, err
+		}
+	}
+
+	return source, nil
+}
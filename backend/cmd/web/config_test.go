@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSourceFlag(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		want  SourceConfig
+	}{
+		{"nrcan,nrcan,https://example.com/feed.atom", SourceConfig{Name: "nrcan", Kind: "nrcan", URL: "https://example.com/feed.atom"}},
+		{"usgs,usgs,https://example.com/all_hour.geojson,1m", SourceConfig{Name: "usgs", Kind: "usgs", URL: "https://example.com/all_hour.geojson", Interval: time.Minute}},
+	} {
+		got, err := parseSourceFlag(tt.value)
+		if err != nil {
+			t.Errorf("%s: %s", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{
+		"",
+		"nrcan,nrcan",
+		"nrcan,nrcan,https://example.com/feed.atom,1m,extra",
+		"nrcan,nrcan,https://example.com/feed.atom,often",
+	} {
+		if got, err := parseSourceFlag(value); err == nil {
+			t.Errorf("%q: got %+v, want an error", value, got)
+		}
+	}
+}
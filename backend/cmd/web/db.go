@@ -79,6 +79,22 @@ func NewDB(params []string) (db *DB) {
 }
 
 func connectDB(dsn string) (db *sql.DB) {
+	// pollers write concurrently, so wait for locks held by other
+	// connections in the pool rather than failing with SQLITE_BUSY.
+	// Transactions read before they write, they take the write lock up front
+	// so two of them can't deadlock upgrading their locks.
+	if !strings.Contains(dsn, "busy_timeout") {
+		dsn = withParam(dsn, "_pragma=busy_timeout(5000)")
+	}
+	if !strings.Contains(dsn, "_txlock") {
+		dsn = withParam(dsn, "_txlock=immediate")
+	}
+	// exports stream rows for the whole download, in WAL mode their read
+	// doesn't hold off the pollers' writes. In-memory databases ignore it.
+	if !strings.Contains(dsn, "journal_mode") {
+		dsn = withParam(dsn, "_pragma=journal_mode(WAL)")
+	}
+
 	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		log.Fatal(err)
@@ -87,6 +103,13 @@ func connectDB(dsn string) (db *sql.DB) {
 	return db
 }
 
+func withParam(dsn, param string) string {
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + param
+	}
+	return dsn + "?" + param
+}
+
 func (db *DB) Close() (err error) {
 	err = db.Connection.Close()
 	return err
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestConnectDBWritesDuringRead(t *testing.T) {
+	db := connectDB("file:" + filepath.Join(t.TempDir(), "quakes.sqlite3"))
+	t.Cleanup(func() { db.Close() })
+
+	var mode string
+	if err := db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil || mode != "wal" {
+		t.Fatalf("got journal mode %q, %v, want wal", mode, err)
+	}
+
+	if _, err := db.Exec(`CREATE TABLE t (n INTEGER); INSERT INTO t VALUES (1), (2)`); err != nil {
+		t.Fatal(err)
+	}
+
+	// an export holds its rows open while the pollers write
+	rows, err := db.Query(`SELECT n FROM t`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatal("no rows")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(`INSERT INTO t VALUES (3)`); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("committing during a read: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+// Declusterer regroups the stored events into sequences after they change.
+// A pass reads the whole table, so rather than one for every poll or import,
+// the changes made during a pass and the interval after it are grouped by
+// a single pass that follows.
+type Declusterer struct {
+	logger   *slog.Logger
+	entries  *models.EntryModel
+	interval time.Duration
+	wake     chan struct{}
+}
+
+func NewDeclusterer(logger *slog.Logger, config *Config, entries *models.EntryModel) *Declusterer {
+	return &Declusterer{
+		logger:   logger,
+		entries:  entries,
+		interval: config.DeclusterInterval,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Notify requests a pass. It never blocks, and requests made before the
+// pass starts are merged into it. Its signature fits
+// models.EntryModel.OnChange.
+func (d *Declusterer) Notify(models.Entry, models.Change) {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run makes the requested passes, at most one per interval, until ctx is
+// cancelled.
+func (d *Declusterer) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		}
+
+		decluster(d.logger, d.entries)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.interval):
+		}
+	}
+}
+
+// decluster regroups the stored events into sequences. The events are
+// already stored, so a failure is only logged.
+func decluster(logger *slog.Logger, entries *models.EntryModel) {
+	start := time.Now()
+
+	changed, err := entries.Decluster()
+	if err != nil {
+		logger.Error("declustering events", "error", err)
+		return
+	}
+
+	logger.Info("Decluster",
+		"time_ms", time.Since(start),
+		"changed", changed)
+}
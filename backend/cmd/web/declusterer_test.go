@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+func TestDeclusterer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	entries := &models.EntryModel{DB: newTestDB(t)}
+	declusterer := NewDeclusterer(discardLogger(), &Config{DeclusterInterval: time.Hour}, entries)
+	entries.OnChange = declusterer.Notify
+
+	done := make(chan struct{})
+	go func() {
+		declusterer.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	sequenceOf := func(guid string) *int64 {
+		t.Helper()
+		e, err := entries.Get(guid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e.SequenceID
+	}
+
+	insertTestEntries(t, entries,
+		models.Entry{GUID: "main", Latitude: 40, Longitude: 140, Magnitude: 5},
+		models.Entry{GUID: "after1", Latitude: 40.1, Longitude: 140, Magnitude: 3},
+	)
+
+	// the changes are grouped after they are stored
+	deadline := time.Now().Add(2 * time.Second)
+	for sequenceOf("after1") == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the events to be declustered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mainshock, err := entries.Get("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := sequenceOf("after1"); *id != mainshock.ID {
+		t.Errorf("got sequence %d, want that of main %d", *id, mainshock.ID)
+	}
+
+	// a change made within the interval waits for the next pass
+	at := time.Date(2025, 10, 3, 0, 0, 0, 0, time.UTC)
+	insertTestEntries(t, entries, models.Entry{GUID: "after2", Latitude: 40, Longitude: 140.1, Magnitude: 3, Time: &at})
+	time.Sleep(100 * time.Millisecond)
+	if id := sequenceOf("after2"); id != nil {
+		t.Errorf("got sequence %d within the interval, want none yet", *id)
+	}
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/tls"
+	_ "embed"
+	"fmt"
+	htmltemplate "html/template"
+	"log/slog"
+	"math"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+const (
+	// digestTopEvents is how many of the largest events a digest lists.
+	digestTopEvents = 10
+	// smtpTimeout limits sending one digest.
+	smtpTimeout = 30 * time.Second
+)
+
+var (
+	//go:embed "digest.txt.tmpl"
+	digestText string
+	//go:embed "digest.html.tmpl"
+	digestHTML string
+
+	digestTextTemplate = template.Must(template.New("digest.txt").Parse(digestText))
+	digestHTMLTemplate = htmltemplate.Must(htmltemplate.New("digest.html").Parse(digestHTML))
+)
+
+// digestBands are the magnitude bands a digest counts events in, largest
+// first, each from its magnitude up to the one before.
+var digestBands = []struct {
+	label string
+	min   float64
+}{
+	{"M5 and above", 5},
+	{"M4 to 5", 4},
+	{"M3 to 4", 3},
+	{"M2 to 3", 2},
+	{"below M2", math.Inf(-1)},
+}
+
+// digestReport is the content of a digest, as shown by its templates.
+type digestReport struct {
+	ID             int64
+	Subject        string
+	Heading        string
+	Period         string
+	Area           string
+	Count          int
+	Bands          []digestBand
+	Top            []digestEvent
+	MapURL         string
+	UnsubscribeURL string
+}
+
+type digestBand struct {
+	Label string
+	Count int
+}
+
+type digestEvent struct {
+	Magnitude string
+	Time      string
+	Place     string
+	DepthKm   string
+	MapURL    string
+}
+
+// Digester emails the digests due on each check.
+type Digester struct {
+	logger   *slog.Logger
+	digests  *models.DigestModel
+	entries  *models.EntryModel
+	addr     string
+	auth     smtp.Auth
+	from     *mail.Address
+	baseURL  string
+	interval time.Duration
+}
+
+func NewDigester(logger *slog.Logger, config *Config, digests *models.DigestModel, entries *models.EntryModel) *Digester {
+	// the sender was checked with the configuration
+	from, _ := mail.ParseAddress(config.SMTPFrom)
+
+	var auth smtp.Auth
+	if config.SMTPUsername != "" {
+		host, _, _ := net.SplitHostPort(config.SMTPAddr)
+		auth = smtp.PlainAuth("", config.SMTPUsername, config.SMTPPassword, host)
+	}
+
+	return &Digester{
+		logger:   logger,
+		digests:  digests,
+		entries:  entries,
+		addr:     config.SMTPAddr,
+		auth:     auth,
+		from:     from,
+		baseURL:  config.PublicURL,
+		interval: config.DigestInterval,
+	}
+}
+
+// Run sends the digests due now and on each interval until ctx is
+// cancelled. A digest that fails to send is tried again on the next check.
+func (d *Digester) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		d.sendDue(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDue sends the digests due at now and returns how many were sent.
+func (d *Digester) sendDue(ctx context.Context, now time.Time) (sent int) {
+	due, err := d.digests.Due(now)
+	if err != nil {
+		d.logger.Error("finding digests", "error", err)
+		return 0
+	}
+
+	for _, digest := range due {
+		if ctx.Err() != nil {
+			return sent
+		}
+
+		start := time.Now()
+		from, until := digest.Period(now)
+
+		report, err := d.report(digest, from, until)
+		if err != nil {
+			d.logger.Error("building digest", "id", digest.ID, "error", err)
+			continue
+		}
+
+		msg, err := d.compose(digest.Email, report)
+		if err != nil {
+			d.logger.Error("composing digest", "id", digest.ID, "error", err)
+			continue
+		}
+
+		if err := d.send(digest.Email, msg); err != nil {
+			d.logger.Error("sending digest", "id", digest.ID, "error", err)
+			continue
+		}
+
+		if err := d.digests.MarkSent(digest.ID, until); err != nil {
+			d.logger.Error("recording digest", "id", digest.ID, "error", err)
+			continue
+		}
+		sent = sent + 1
+
+		d.logger.Info("Digest",
+			"time_ms", time.Since(start),
+			"id", digest.ID,
+			"frequency", digest.Frequency,
+			"until", until,
+			"events", report.Count)
+	}
+
+	return sent
+}
+
+// report summarises the events of digest from..until.
+func (d *Digester) report(digest models.Digest, from, until time.Time) (digestReport, error) {
+	var events []models.Entry
+	err := d.entries.Each(digest.Query(from, until), func(e models.Entry) error {
+		if e.Time != nil && e.Time.Before(until) && digest.Matches(e) {
+			events = append(events, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return digestReport{}, err
+	}
+
+	heading := "Daily earthquake digest"
+	period := from.Format(time.DateOnly)
+	if digest.Frequency == models.DigestWeekly {
+		heading = "Weekly earthquake digest"
+		period = from.Format(time.DateOnly) + " to " + until.AddDate(0, 0, -1).Format(time.DateOnly)
+	}
+
+	count := fmt.Sprintf("%d events", len(events))
+	if len(events) == 1 {
+		count = "1 event"
+	}
+
+	report := digestReport{
+		ID:      digest.ID,
+		Subject: fmt.Sprintf("%s for %s: %s", heading, period, count),
+		Heading: heading,
+		Period:  period,
+		Area:    describeArea(digest),
+		Count:   len(events),
+		MapURL:  regionMapURL(digest.Region),
+	}
+	if digest.UnsubscribeToken != "" {
+		report.UnsubscribeURL = d.baseURL + "/api/v1/digests/unsubscribe/" + digest.UnsubscribeToken
+	}
+
+	for _, band := range digestBands {
+		report.Bands = append(report.Bands, digestBand{Label: band.label})
+	}
+	for _, e := range events {
+		for i, band := range digestBands {
+			if e.MagnitudeAtLeast(band.min) {
+				report.Bands[i].Count++
+				break
+			}
+		}
+	}
+
+	// largest first, the most recent on a tie
+	slices.SortFunc(events, func(a, b models.Entry) int {
+		if c := cmp.Compare(b.Magnitude, a.Magnitude); c != 0 {
+			return c
+		}
+		return b.Time.Compare(*a.Time)
+	})
+	for _, e := range events[:min(len(events), digestTopEvents)] {
+		report.Top = append(report.Top, newDigestEvent(e))
+	}
+
+	return report, nil
+}
+
+func newDigestEvent(e models.Entry) digestEvent {
+	lat, lng := models.Widen(e.Latitude), models.Widen(e.Longitude)
+
+	place := e.Place
+	if place == "" {
+		place = fmt.Sprintf("%.2f, %.2f", lat, lng)
+	}
+
+	magnitude := strconv.FormatFloat(models.Widen(e.Magnitude), 'f', 1, 64)
+	if e.MagnitudeType != "" {
+		magnitude += " " + e.MagnitudeType
+	}
+
+	return digestEvent{
+		Magnitude: magnitude,
+		Time:      e.Time.UTC().Format("2006-01-02 15:04 UTC"),
+		Place:     place,
+		DepthKm:   strconv.FormatFloat(float64(-e.Elevation)/1000, 'f', 1, 64),
+		MapURL:    fmt.Sprintf("https://www.openstreetmap.org/?mlat=%.4f&mlon=%.4f#map=8/%.4f/%.4f", lat, lng, lat, lng),
+	}
+}
+
+// describeArea describes the events a digest covers, to follow a count.
+func describeArea(digest models.Digest) string {
+	var parts []string
+
+	region := newRegionJSON(digest.Region)
+	if region.Coords != "" {
+		parts = append(parts, "in the area "+region.Coords)
+	}
+	if c := digest.Region.Circle; c != nil {
+		parts = append(parts, fmt.Sprintf("within %g km of %g, %g", c.RadiusKm, c.Latitude, c.Longitude))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "worldwide")
+	}
+
+	if digest.MinMagnitude != nil {
+		parts = append(parts, fmt.Sprintf("of magnitude %g or more", *digest.MinMagnitude))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// regionMapURL links to a map of r, zoomed to show all of it.
+func regionMapURL(r models.Region) string {
+	lat, lng, zoom := 20.0, 0.0, 2
+
+	switch {
+	case r.Circle != nil:
+		lat, lng = r.Circle.Latitude, r.Circle.Longitude
+		zoom = mapZoom(2 * r.Circle.RadiusKm / 111)
+
+	case r.Box != nil:
+		b := r.Box
+		// a box crossing the antimeridian has its west corner east of its
+		// east corner
+		width := math.Mod(b.NELng-b.SWLng+360, 360)
+		lat = (b.SWLat + b.NELat) / 2
+		lng = b.SWLng + width/2
+		if lng > 180 {
+			lng -= 360
+		}
+		zoom = mapZoom(max(width, b.NELat-b.SWLat))
+	}
+
+	return fmt.Sprintf("https://www.openstreetmap.org/#map=%d/%.4f/%.4f", zoom, lat, lng)
+}
+
+// mapZoom returns the web map zoom level showing about spanDegrees across.
+func mapZoom(spanDegrees float64) int {
+	if spanDegrees <= 0 {
+		return 12
+	}
+	zoom := int(math.Floor(math.Log2(360 / spanDegrees)))
+	return max(2, min(zoom, 12))
+}
+
+// compose renders report as an email to to with plain text and HTML
+// alternatives.
+func (d *Digester) compose(to string, report digestReport) ([]byte, error) {
+	var buf bytes.Buffer
+	body := multipart.NewWriter(&buf)
+
+	header := []string{
+		"From: " + d.from.String(),
+		"To: " + to,
+		"Subject: " + mime.QEncoding.Encode("utf-8", report.Subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + body.Boundary(),
+	}
+	if report.UnsubscribeURL != "" {
+		header = append(header,
+			"List-Unsubscribe: <"+report.UnsubscribeURL+">",
+			"List-Unsubscribe-Post: List-Unsubscribe=One-Click")
+	}
+	buf.WriteString(strings.Join(header, "\r\n") + "\r\n\r\n")
+
+	for _, alternative := range []struct {
+		contentType string
+		execute     func(w *quotedprintable.Writer) error
+	}{
+		{"text/plain", func(w *quotedprintable.Writer) error { return digestTextTemplate.Execute(w, report) }},
+		{"text/html", func(w *quotedprintable.Writer) error { return digestHTMLTemplate.Execute(w, report) }},
+	} {
+		part, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {alternative.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		w := quotedprintable.NewWriter(part)
+		if err := alternative.execute(w); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// send delivers msg to the relay like smtp.SendMail, within smtpTimeout.
+func (d *Digester) send(to string, msg []byte) error {
+	conn, err := net.DialTimeout("tcp", d.addr, smtpTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		return err
+	}
+
+	host, _, _ := net.SplitHostPort(d.addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if d.auth != nil {
+		if err := c.Auth(d.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(d.from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+type smtpMessage struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// fakeSMTP starts an SMTP server passing on each message it accepts. It
+// rejects recipients at bounce.example.com.
+func fakeSMTP(t *testing.T) (addr string, messages <-chan smtpMessage) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan smtpMessage, 10)
+
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ESMTP")
+
+		var msg smtpMessage
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+
+			verb, arg, _ := strings.Cut(line, " ")
+			address := func() string {
+				_, v, _ := strings.Cut(arg, ":")
+				return strings.Trim(v, "<>")
+			}
+
+			switch strings.ToUpper(verb) {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 localhost")
+			case "MAIL":
+				msg = smtpMessage{From: address()}
+				tp.PrintfLine("250 OK")
+			case "RCPT":
+				if strings.HasSuffix(address(), "@bounce.example.com") {
+					tp.PrintfLine("550 no such user")
+					continue
+				}
+				msg.To = append(msg.To, address())
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				if msg.Data, err = tp.ReadDotBytes(); err != nil {
+					return
+				}
+				received <- msg
+				tp.PrintfLine("250 OK")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				return
+			default:
+				tp.PrintfLine("502 not implemented")
+			}
+		}
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+
+	return ln.Addr().String(), received
+}
+
+func TestDigester(t *testing.T) {
+	db := newTestDB(t)
+	digests := &models.DigestModel{DB: db}
+	entries := &models.EntryModel{DB: db}
+
+	addr, messages := fakeSMTP(t)
+	config := &Config{SMTPAddr: addr, SMTPFrom: "Quakes <quakes@example.com>", PublicURL: "https://quakes.example.com", DigestInterval: time.Minute}
+	digester := NewDigester(discardLogger(), config, digests, entries)
+
+	at := func(day, hour int) *time.Time {
+		t := time.Date(2025, 10, day, hour, 0, 0, 0, time.UTC)
+		return &t
+	}
+	insertTestEntries(t, entries,
+		models.Entry{GUID: "large", Place: "Trois-Rivières & area", Latitude: 46.35, Longitude: -72.55, Magnitude: 4.5, MagnitudeType: "mb", Elevation: -10000, Time: at(15, 10)},
+		models.Entry{GUID: "small", Place: "Montreal", Latitude: 45.5, Longitude: -73.6, Magnitude: 2.1, Time: at(15, 12)},
+		models.Entry{GUID: "too small", Place: "Montreal", Latitude: 45.5, Longitude: -73.6, Magnitude: 1.9, Time: at(15, 13)},
+		models.Entry{GUID: "day before", Place: "Montreal", Latitude: 45.5, Longitude: -73.6, Magnitude: 5, Time: at(14, 23)},
+		models.Entry{GUID: "day after", Place: "Montreal", Latitude: 45.5, Longitude: -73.6, Magnitude: 3, Time: at(16, 0)},
+		models.Entry{GUID: "far", Place: "Vancouver", Latitude: 49.28, Longitude: -123.12, Magnitude: 6, Time: at(15, 1)},
+	)
+
+	minMag := 2.0
+	id, err := digests.Insert(models.Digest{
+		Email:        "ops@example.com",
+		Frequency:    models.DigestDaily,
+		Region:       models.Region{Circle: &models.Circle{Latitude: 45.5, Longitude: -73.6, RadiusKm: 200}},
+		MinMagnitude: &minMag,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bounceID, err := digests.Insert(models.Digest{Email: "nobody@bounce.example.com", Frequency: models.DigestDaily})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the digests were last sent for the 14th
+	for _, id := range []int64{id, bounceID} {
+		if err := digests.MarkSent(id, *at(15, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := *at(16, 6)
+	if sent := digester.sendDue(context.Background(), now); sent != 1 {
+		t.Fatalf("sent %d digests, want 1", sent)
+	}
+
+	var msg smtpMessage
+	select {
+	case msg = <-messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	if msg.From != "quakes@example.com" || len(msg.To) != 1 || msg.To[0] != "ops@example.com" {
+		t.Errorf("got envelope from %s to %v", msg.From, msg.To)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(msg.Data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != "Daily earthquake digest for 2025-10-15: 2 events" {
+		t.Errorf("got subject %q, %v", subject, err)
+	}
+	if from := parsed.Header.Get("From"); from != `"Quakes" <quakes@example.com>` {
+		t.Errorf("got from %q", from)
+	}
+
+	subscription, err := digests.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsubscribeURL := "https://quakes.example.com/api/v1/digests/unsubscribe/" + subscription.UnsubscribeToken
+	if got := parsed.Header.Get("List-Unsubscribe"); got != "<"+unsubscribeURL+">" {
+		t.Errorf("got List-Unsubscribe %q", got)
+	}
+	if got := parsed.Header.Get("List-Unsubscribe-Post"); got != "List-Unsubscribe=One-Click" {
+		t.Errorf("got List-Unsubscribe-Post %q", got)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("got content type %q, %v", mediaType, err)
+	}
+	parts := map[string]string{}
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(body)
+	}
+
+	text := parts["text/plain"]
+	for _, want := range []string{
+		"2 earthquakes within 200 km of 45.5, -73.6 of magnitude 2 or more.",
+		"M4 to 5          1",
+		"M2 to 3          1",
+		"M4.5 mb  2025-10-15 10:00 UTC  Trois-Rivières & area, 10.0 km deep",
+		"M2.1  2025-10-15 12:00 UTC  Montreal, 0.0 km deep",
+		"https://www.openstreetmap.org/?mlat=46.3500&mlon=-72.5500#map=8/46.3500/-72.5500",
+		"Map: https://www.openstreetmap.org/#map=6/45.5000/-73.6000",
+		"To unsubscribe, open\n" + unsubscribeURL,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("plain text is missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Index(text, "Trois-Rivières") > strings.Index(text, "Montreal") {
+		t.Errorf("got the smaller event first:\n%s", text)
+	}
+	for _, unwanted := range []string{"Vancouver", "M1.9", "M5.0", "M3.0"} {
+		if strings.Contains(text, unwanted) {
+			t.Errorf("plain text has %q from outside the digest:\n%s", unwanted, text)
+		}
+	}
+
+	html := parts["text/html"]
+	for _, want := range []string{
+		"Trois-Rivières &amp; area</a>",
+		`<a href="https://www.openstreetmap.org/#map=6/45.5000/-73.6000">`,
+		`<a href="` + unsubscribeURL + `">Unsubscribe</a>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML is missing %q:\n%s", want, html)
+		}
+	}
+
+	// the digest is sent once a period, the bounced one is tried again
+	if d, _ := digests.Get(id); !d.SentUntil.Equal(*at(16, 0)) {
+		t.Errorf("got sent until %s", d.SentUntil)
+	}
+	if d, _ := digests.Get(bounceID); !d.SentUntil.Equal(*at(15, 0)) {
+		t.Errorf("got sent until %s for a bounced digest", d.SentUntil)
+	}
+	if due, _ := digests.Due(now); len(due) != 1 || due[0].ID != bounceID {
+		t.Errorf("got %+v due after sending", due)
+	}
+}
+
+func TestRegionMapURL(t *testing.T) {
+	for _, tt := range []struct {
+		region models.Region
+		want   string
+	}{
+		{models.Region{}, "https://www.openstreetmap.org/#map=2/20.0000/0.0000"},
+		{models.Region{Circle: &models.Circle{Latitude: 45.5, Longitude: -73.6, RadiusKm: 50}}, "https://www.openstreetmap.org/#map=8/45.5000/-73.6000"},
+		{models.Region{Box: &models.Corners{SWLng: -80, SWLat: 40, NELng: -70, NELat: 50}}, "https://www.openstreetmap.org/#map=5/45.0000/-75.0000"},
+		// across the antimeridian
+		{models.Region{Box: &models.Corners{SWLng: 170, SWLat: -50, NELng: -170, NELat: -30}}, "https://www.openstreetmap.org/#map=4/-40.0000/180.0000"},
+		{models.Region{Box: &models.Corners{SWLng: 160, SWLat: -50, NELng: -170, NELat: -30}}, "https://www.openstreetmap.org/#map=3/-40.0000/175.0000"},
+	} {
+		if got := regionMapURL(tt.region); got != tt.want {
+			t.Errorf("got %s, want %s", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+var (
+	//go:embed "unsubscribe.html.tmpl"
+	unsubscribeHTML string
+
+	unsubscribeTemplate = template.Must(template.New("unsubscribe.html").Parse(unsubscribeHTML))
+)
+
+// digestRequest is the body of a request subscribing to a digest.
+type digestRequest struct {
+	Email     string `json:"email"`
+	Frequency string `json:"frequency"`
+	regionJSON
+	MinMag *float64 `json:"minmag"`
+}
+
+// digest is a models.Digest in a response. Listings leave out the email.
+type digest struct {
+	ID        int64  `json:"id"`
+	Email     string `json:"email,omitempty"`
+	Frequency string `json:"frequency"`
+	regionJSON
+	MinMag    *float64  `json:"minmag,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	SentUntil time.Time `json:"sent_until"`
+}
+
+func newDigest(d models.Digest) digest {
+	return digest{
+		ID:         d.ID,
+		Email:      d.Email,
+		Frequency:  d.Frequency,
+		regionJSON: newRegionJSON(d.Region),
+		MinMag:     d.MinMagnitude,
+		CreatedAt:  d.CreatedAt,
+		SentUntil:  d.SentUntil,
+	}
+}
+
+// parseDigestRequest decodes and validates the body of r, responding with a
+// problem when it is not a valid subscription.
+func parseDigestRequest(w http.ResponseWriter, r *http.Request, logger *slog.Logger) (models.Digest, bool) {
+	var req digestRequest
+	if !decodeBody(w, r, logger, &req, "a digest subscription") {
+		return models.Digest{}, false
+	}
+
+	invalid := &validationError{}
+
+	// only a bare address, so a name can't be used to change the headers
+	address, err := mail.ParseAddress(req.Email)
+	switch {
+	case req.Email == "":
+		invalid.add("email", "is required")
+	case err != nil || address.Name != "" || address.Address != req.Email:
+		invalid.add("email", fmt.Sprintf("expected an email address such as name@example.com, got %q", req.Email))
+	}
+
+	switch req.Frequency {
+	case models.DigestDaily, models.DigestWeekly:
+	case "":
+		invalid.add("frequency", "is required")
+	default:
+		invalid.add("frequency", fmt.Sprintf("expected %s or %s, got %q", models.DigestDaily, models.DigestWeekly, req.Frequency))
+	}
+
+	region := req.region(invalid)
+	checkRange(invalid, "minmag", req.MinMag, -2, 10)
+
+	if err := invalid.err(); err != nil {
+		writeInvalid(w, r, logger, err)
+		return models.Digest{}, false
+	}
+
+	return models.Digest{
+		Email:        req.Email,
+		Frequency:    req.Frequency,
+		Region:       region,
+		MinMagnitude: req.MinMag,
+	}, true
+}
+
+func handleCreateDigest(logger *slog.Logger, digests *models.DigestModel) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			d, ok := parseDigestRequest(w, r, logger)
+			if !ok {
+				return
+			}
+
+			id, err := digests.Insert(d)
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			created, err := digests.Get(id)
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			w.Header().Set("Location", fmt.Sprintf("/api/v1/digests/%d", id))
+			writeData(w, logger, http.StatusCreated, "Created digest", newDigest(created))
+
+			logger.Info("CreateDigest", "time_ms", time.Since(start), "id", id, "frequency", d.Frequency)
+		},
+	)
+}
+
+func handleListDigests(logger *slog.Logger, digests *models.DigestModel) http.Handler {
+	type Response struct {
+		Message string   `json:"message"`
+		Data    []digest `json:"data"`
+		Count   int      `json:"count"`
+		Total   int      `json:"total"`
+		Limit   int      `json:"limit"`
+		Offset  int      `json:"offset"`
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			invalid := &validationError{}
+			limit, offset := parsePage(r, 20, 100, invalid)
+			if err := invalid.err(); err != nil {
+				writeInvalid(w, r, logger, err)
+				return
+			}
+
+			results, total, err := digests.List(limit, offset)
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			resp := Response{
+				Message: "Digests",
+				Data:    []digest{},
+				Total:   total,
+				Limit:   limit,
+				Offset:  offset,
+			}
+			// a listing doesn't hand out the subscribers' addresses
+			for _, d := range results {
+				listed := newDigest(d)
+				listed.Email = ""
+				resp.Data = append(resp.Data, listed)
+			}
+			resp.Count = len(resp.Data)
+
+			js, _ := json.Marshal(resp)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err = w.Write(js)
+			if err != nil {
+				logger.Error("writing response", "error", err)
+				return
+			}
+		},
+	)
+}
+
+func handleGetDigest(logger *slog.Logger, digests *models.DigestModel) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			id, ok := pathID(w, r, logger, "digest")
+			if !ok {
+				return
+			}
+
+			d, err := digests.Get(id)
+			if err != nil {
+				writeLookupError(w, r, logger, "digest", id, err)
+				return
+			}
+
+			writeData(w, logger, http.StatusOK, "Digest", newDigest(d))
+		},
+	)
+}
+
+func handleDeleteDigest(logger *slog.Logger, digests *models.DigestModel) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			id, ok := pathID(w, r, logger, "digest")
+			if !ok {
+				return
+			}
+
+			if err := digests.Delete(id); err != nil {
+				writeLookupError(w, r, logger, "digest", id, err)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+
+			logger.Info("DeleteDigest", "id", id)
+		},
+	)
+}
+
+// writeUnsubscribePage responds with the page asking to confirm
+// unsubscribing from d, or telling it is done.
+func writeUnsubscribePage(w http.ResponseWriter, logger *slog.Logger, d models.Digest, done bool) {
+	page := struct {
+		Email     string
+		Frequency string
+		Done      bool
+	}{d.Email, d.Frequency, done}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if err := unsubscribeTemplate.Execute(w, page); err != nil {
+		logger.Error("writing response", "error", err)
+	}
+}
+
+// handleConfirmUnsubscribeDigest answers the link in the digests with a page
+// whose button posts to the same URL. Following the link doesn't unsubscribe,
+// since mail scanners and link previews follow it too.
+func handleConfirmUnsubscribeDigest(logger *slog.Logger, digests *models.DigestModel) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			d, err := digests.GetByToken(r.PathValue("token"))
+			switch {
+			case errors.Is(err, models.ErrNoRecord):
+				writeProblem(w, r, logger, http.StatusNotFound, "no digest subscription with this token, it may be unsubscribed already")
+				return
+			case err != nil:
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			writeUnsubscribePage(w, logger, d, false)
+		},
+	)
+}
+
+// handleUnsubscribeDigest cancels the subscription with the token from the
+// link in its digests. It is posted by the confirmation page and by the
+// one-click List-Unsubscribe of RFC 8058.
+func handleUnsubscribeDigest(logger *slog.Logger, digests *models.DigestModel) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			token := r.PathValue("token")
+			d, err := digests.GetByToken(token)
+			if err == nil {
+				err = digests.DeleteByToken(token)
+			}
+			switch {
+			case errors.Is(err, models.ErrNoRecord):
+				writeProblem(w, r, logger, http.StatusNotFound, "no digest subscription with this token, it may be unsubscribed already")
+				return
+			case err != nil:
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			// the confirmation page is posted by a browser
+			if strings.Contains(r.Header.Get("Accept"), "text/html") {
+				writeUnsubscribePage(w, logger, d, true)
+			} else {
+				writeData(w, logger, http.StatusOK, "Unsubscribed from the digest", nil)
+			}
+
+			logger.Info("UnsubscribeDigest", "id", d.ID)
+		},
+	)
+}
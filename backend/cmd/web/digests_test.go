@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+func TestHandleDigests(t *testing.T) {
+	digests := &models.DigestModel{DB: newTestDB(t)}
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/v1/digests", handleCreateDigest(discardLogger(), digests))
+	mux.Handle("GET /api/v1/digests", handleListDigests(discardLogger(), digests))
+	mux.Handle("GET /api/v1/digests/{id}", handleGetDigest(discardLogger(), digests))
+	mux.Handle("DELETE /api/v1/digests/{id}", handleDeleteDigest(discardLogger(), digests))
+	mux.Handle("GET /api/v1/digests/unsubscribe/{token}", handleConfirmUnsubscribeDigest(discardLogger(), digests))
+	mux.Handle("POST /api/v1/digests/unsubscribe/{token}", handleUnsubscribeDigest(discardLogger(), digests))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/api/v1/digests", `{"email":"ops@example.com","frequency":"weekly","coords":"-80,40,-70,50","minmag":2.5}`)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Location") != "/api/v1/digests/1" {
+		t.Fatalf("got status %d at %q: %s", rec.Code, rec.Header().Get("Location"), rec.Body)
+	}
+	var resp struct {
+		Data struct {
+			ID        int64
+			Email     string
+			Frequency string
+			Coords    string
+			MinMag    *float64
+			SentUntil time.Time `json:"sent_until"`
+		}
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.Email != "ops@example.com" || resp.Data.Frequency != "weekly" || resp.Data.Coords != "-80,40,-70,50" || *resp.Data.MinMag != 2.5 {
+		t.Errorf("got %+v", resp.Data)
+	}
+	// the first digest covers the first whole week
+	if resp.Data.SentUntil.Weekday() != time.Monday || resp.Data.SentUntil.After(time.Now()) {
+		t.Errorf("got sent until %s, want the last Monday", resp.Data.SentUntil)
+	}
+
+	for _, tt := range []struct {
+		body  string
+		param string
+	}{
+		{`{"frequency":"daily"}`, "email"},
+		{`{"email":"not an address","frequency":"daily"}`, "email"},
+		{`{"email":"Ops <ops@example.com>","frequency":"daily"}`, "email"},
+		{`{"email":"ops@example.com"}`, "frequency"},
+		{`{"email":"ops@example.com","frequency":"hourly"}`, "frequency"},
+		{`{"email":"ops@example.com","frequency":"daily","lat":45}`, "radiuskm"},
+		{`{"email":"ops@example.com","frequency":"daily","minmag":11}`, "minmag"},
+		{`{"email":"ops@example.com","frequency":"daily","to":"x"}`, "unknown field"},
+	} {
+		rec := do(http.MethodPost, "/api/v1/digests", tt.body)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tt.param) {
+			t.Errorf("%s: got status %d: %s", tt.body, rec.Code, rec.Body)
+		}
+	}
+
+	rec = do(http.MethodGet, "/api/v1/digests", "")
+	var list struct {
+		Data  []map[string]any
+		Count int
+		Total int
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Count != 1 || list.Total != 1 {
+		t.Errorf("got %+v", list)
+	}
+	if _, ok := list.Data[0]["email"]; ok {
+		t.Errorf("got the email in a listing: %+v", list.Data[0])
+	}
+
+	if rec := do(http.MethodGet, "/api/v1/digests/1", ""); rec.Code != http.StatusOK {
+		t.Errorf("got status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodDelete, "/api/v1/digests/1", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("got status %d for delete", rec.Code)
+	}
+
+	// the recipient unsubscribes with the token from the digest
+	rec = do(http.MethodPost, "/api/v1/digests", `{"email":"ops@example.com","frequency":"daily"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	body := rec.Body.String()
+	var subscribed struct{ Data struct{ ID int64 } }
+	if err := json.Unmarshal([]byte(body), &subscribed); err != nil {
+		t.Fatal(err)
+	}
+	created, err := digests.Get(subscribed.Data.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(body, created.UnsubscribeToken) {
+		t.Errorf("got the unsubscribe token in the response: %s", body)
+	}
+
+	// following the link only asks to confirm
+	rec = do(http.MethodGet, "/api/v1/digests/unsubscribe/"+created.UnsubscribeToken, "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `<form method="post">`) || !strings.Contains(rec.Body.String(), "ops@example.com") {
+		t.Errorf("got status %d: %s", rec.Code, rec.Body)
+	}
+	if _, err := digests.Get(created.ID); err != nil {
+		t.Errorf("getting the digest after following the unsubscribe link: %v", err)
+	}
+	if rec := do(http.MethodGet, "/api/v1/digests/unsubscribe/guess", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("got status %d for an unknown token, want 404", rec.Code)
+	}
+
+	for _, tt := range []struct {
+		token string
+		want  int
+	}{
+		{"guess", http.StatusNotFound},
+		{created.UnsubscribeToken, http.StatusOK},
+		{created.UnsubscribeToken, http.StatusNotFound},
+	} {
+		if rec := do(http.MethodPost, "/api/v1/digests/unsubscribe/"+tt.token, ""); rec.Code != tt.want {
+			t.Errorf("unsubscribing with %q: got status %d, want %d: %s", tt.token, rec.Code, tt.want, rec.Body)
+		}
+	}
+	for _, path := range []string{"/api/v1/digests/1", "/api/v1/digests/x"} {
+		if rec := do(http.MethodGet, path, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: got status %d, want 404", path, rec.Code)
+		}
+	}
+}
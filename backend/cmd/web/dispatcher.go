@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+// webhookTimeout limits one delivery attempt.
+const webhookTimeout = 10 * time.Second
+
+// webhookPayload is the body POSTed to a webhook.
+type webhookPayload struct {
+	Type      string    `json:"type"`
+	WebhookID int64     `json:"webhook_id"`
+	ChangeID  int64     `json:"change_id"`
+	Event     exportRow `json:"event"`
+}
+
+// Dispatcher delivers the changes made by ingestion to the webhooks they
+// match. Every attempt is recorded in the delivery log, and failed attempts
+// are retried with exponential backoff.
+//
+// Changes are read back in order from the last one dispatched, so none are
+// missed when it falls behind. Each delivery of a change is stored as
+// pending before the change counts as dispatched, and stays pending until it
+// is accepted or its attempts run out, so the deliveries cut short when the
+// server stops are resumed when it starts again. A fixed number of workers
+// make one attempt at a time, the soonest due first, and an attempt waiting
+// to be retried doesn't hold a worker.
+type Dispatcher struct {
+	*changeFollower
+	logger     *slog.Logger
+	webhooks   *models.WebhookModel
+	client     *http.Client
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	workers    int
+
+	mu     sync.Mutex
+	queue  pendingQueue
+	queued chan struct{}
+}
+
+// queuedDelivery is a pending delivery waiting in the queue for its next
+// attempt.
+type queuedDelivery struct {
+	id  int64
+	due time.Time
+}
+
+// pendingQueue orders the pending deliveries by when they are due. It
+// implements heap.Interface.
+type pendingQueue []queuedDelivery
+
+func (q pendingQueue) Len() int           { return len(q) }
+func (q pendingQueue) Less(i, j int) bool { return q[i].due.Before(q[j].due) }
+func (q pendingQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *pendingQueue) Push(x any)        { *q = append(*q, x.(queuedDelivery)) }
+func (q *pendingQueue) Pop() any {
+	old := *q
+	last := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return last
+}
+
+func NewDispatcher(logger *slog.Logger, config *Config, webhooks *models.WebhookModel, entries *models.EntryModel, progress *models.ProgressModel) (*Dispatcher, error) {
+	follower, err := newChangeFollower(logger, "webhooks", entries, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Dispatcher{
+		changeFollower: follower,
+		logger:         logger,
+		webhooks:       webhooks,
+		client:         newWebhookClient(config.WebhookAllowPrivate),
+		attempts:       config.WebhookAttempts,
+		backoff:        config.WebhookBackoff,
+		maxBackoff:     config.WebhookMaxBackoff,
+		workers:        config.WebhookWorkers,
+		queued:         make(chan struct{}, 1),
+	}, nil
+}
+
+// Run resumes the pending deliveries and delivers the changes as they are
+// made until ctx is cancelled, then waits for the attempts in progress to
+// stop.
+func (d *Dispatcher) Run(ctx context.Context) {
+	pending, err := d.webhooks.Pending()
+	if err != nil {
+		d.logger.Error("reading pending webhook deliveries", "error", err)
+	}
+	for _, p := range pending {
+		d.enqueue(p.ID, p.DueAt)
+	}
+
+	jobs := make(chan int64)
+
+	var wg sync.WaitGroup
+	for range d.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				d.attempt(ctx, id)
+			}
+		}()
+	}
+	defer wg.Wait()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.schedule(ctx, jobs)
+	}()
+
+	d.follow(ctx, func(c models.EntryChange) {
+		webhooks, err := d.webhooks.Matching(c.Entry)
+		if err != nil {
+			d.logger.Error("finding webhooks", "guid", c.Entry.GUID, "error", err)
+			return
+		}
+
+		for _, webhook := range webhooks {
+			d.dispatch(webhook, c)
+		}
+	})
+}
+
+// dispatch stores the delivery of c to webhook as pending and queues its
+// first attempt.
+func (d *Dispatcher) dispatch(webhook models.Webhook, c models.EntryChange) {
+	body, err := json.Marshal(webhookPayload{
+		Type:      "event." + c.Change.String(),
+		WebhookID: webhook.ID,
+		ChangeID:  c.Entry.ChangeID,
+		Event:     newExportRow(c.Entry, false),
+	})
+	if err != nil {
+		d.logger.Error("encoding webhook payload", "webhook_id", webhook.ID, "error", err)
+		return
+	}
+
+	now := time.Now()
+	id, err := d.webhooks.InsertPending(models.PendingDelivery{
+		WebhookID: webhook.ID,
+		GUID:      c.Entry.GUID,
+		ChangeID:  c.Entry.ChangeID,
+		Change:    c.Change.String(),
+		Body:      body,
+		Attempt:   1,
+		DueAt:     now,
+	})
+	if err != nil {
+		d.logger.Error("storing pending webhook delivery", "webhook_id", webhook.ID, "error", err)
+		return
+	}
+	// a change dispatched again after a restart is queued already
+	if id != 0 {
+		d.enqueue(id, now)
+	}
+}
+
+// enqueue queues the attempt of the pending delivery id due at due.
+func (d *Dispatcher) enqueue(id int64, due time.Time) {
+	d.mu.Lock()
+	heap.Push(&d.queue, queuedDelivery{id: id, due: due})
+	d.mu.Unlock()
+
+	select {
+	case d.queued <- struct{}{}:
+	default:
+	}
+}
+
+// schedule hands the queued attempts to the workers as they fall due, until
+// ctx is cancelled. The attempts still queued then stay pending.
+func (d *Dispatcher) schedule(ctx context.Context, jobs chan<- int64) {
+	defer close(jobs)
+
+	for {
+		var next *queuedDelivery
+		wait := time.Duration(-1)
+
+		d.mu.Lock()
+		if d.queue.Len() > 0 {
+			if until := time.Until(d.queue[0].due); until > 0 {
+				wait = until
+			} else {
+				due := heap.Pop(&d.queue).(queuedDelivery)
+				next = &due
+			}
+		}
+		d.mu.Unlock()
+
+		if next != nil {
+			select {
+			case jobs <- next.id:
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		var timer <-chan time.Time
+		if wait >= 0 {
+			timer = time.After(wait)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.queued:
+		case <-timer:
+		}
+	}
+}
+
+// attempt POSTs the pending delivery id to its webhook once. It is retried
+// later when the attempt failed and may succeed, and is done with when it
+// was accepted or the attempts ran out. An attempt cut short by ctx is
+// made again on the next run.
+func (d *Dispatcher) attempt(ctx context.Context, id int64) {
+	p, err := d.webhooks.GetPending(id)
+	if errors.Is(err, models.ErrNoRecord) {
+		// the webhook was deleted
+		return
+	}
+	if err != nil {
+		d.logger.Error("reading pending webhook delivery", "id", id, "error", err)
+		d.enqueue(id, time.Now().Add(followRetry))
+		return
+	}
+
+	webhook, err := d.webhooks.Get(p.WebhookID)
+	if errors.Is(err, models.ErrNoRecord) {
+		return
+	}
+	if err != nil {
+		d.logger.Error("reading webhook", "webhook_id", p.WebhookID, "error", err)
+		d.enqueue(id, time.Now().Add(followRetry))
+		return
+	}
+
+	start := time.Now()
+	status, err := d.post(ctx, webhook, p.Body)
+	if ctx.Err() != nil {
+		return
+	}
+
+	delivery := models.WebhookDelivery{
+		WebhookID:   webhook.ID,
+		GUID:        p.GUID,
+		ChangeID:    p.ChangeID,
+		Change:      p.Change,
+		Attempt:     p.Attempt,
+		AttemptedAt: start,
+		StatusCode:  status,
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	if _, err := d.webhooks.RecordDelivery(delivery); err != nil {
+		d.logger.Error("recording webhook delivery", "webhook_id", webhook.ID, "error", err)
+	}
+
+	d.logger.Info("Webhook",
+		"time_ms", time.Since(start),
+		"webhook_id", webhook.ID,
+		"guid", p.GUID,
+		"attempt", p.Attempt,
+		"status", status,
+		"error", err)
+
+	if err == nil || !retryable(status) || p.Attempt >= d.attempts {
+		if err := d.webhooks.DeletePending(id); err != nil && !errors.Is(err, models.ErrNoRecord) {
+			d.logger.Error("removing pending webhook delivery", "webhook_id", webhook.ID, "error", err)
+		}
+		return
+	}
+
+	due := time.Now().Add(backoffDelay(d.backoff, d.maxBackoff, p.Attempt))
+	err = d.webhooks.ReschedulePending(id, p.Attempt+1, due)
+	if errors.Is(err, models.ErrNoRecord) {
+		return
+	}
+	if err != nil {
+		// the attempt is made again, under its old number
+		d.logger.Error("rescheduling webhook delivery", "webhook_id", webhook.ID, "error", err)
+	}
+	d.enqueue(id, due)
+}
+
+// post sends one signed delivery and returns the response status. A response
+// other than 2xx is an error.
+func (d *Dispatcher) post(ctx context.Context, webhook models.Webhook, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", signWebhook(webhook.Secret, timestamp, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook responded %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// newWebhookClient returns the client making deliveries. Unless
+// allowPrivate, it refuses to connect to addresses that aren't public, so a
+// webhook can't be used to reach services behind the server, such as a
+// cloud metadata endpoint. The address is checked as it is dialled, after
+// the name was resolved and on every redirect, so a name that resolves to a
+// private address is refused too.
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivate {
+		dialer.Control = refusePrivate
+	}
+
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+			MaxIdleConnsPerHost: 2,
+		},
+	}
+}
+
+// nonPublicPrefixes are the ranges refused to webhooks besides those the
+// netip.Addr methods report.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+}
+
+// refusePrivate is a net.Dialer Control refusing addresses that aren't
+// public: loopback, private, link-local, multicast and unspecified ones.
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+
+	public := ip.IsGlobalUnicast() && !ip.IsPrivate()
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			public = false
+		}
+	}
+	if !public {
+		return fmt.Errorf("webhook address %s is not public", ip)
+	}
+	return nil
+}
+
+// retryable reports whether a failed attempt that got status is worth
+// repeating. Status 0 means no response was received.
+func retryable(status int) bool {
+	switch {
+	case status == 0, status >= 500:
+		return true
+	case status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	default:
+		return false
+	}
+}
+
+// signWebhook returns the X-Webhook-Signature of body sent at timestamp: the
+// hex HMAC-SHA256, keyed with secret, of the timestamp and the body joined by
+// a dot. Receivers recompute it to check the payload came from this server
+// and reject old timestamps to prevent replays.
+func signWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
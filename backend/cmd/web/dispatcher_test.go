@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+func TestDispatcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := newTestDB(t)
+	webhooks := &models.WebhookModel{DB: db}
+
+	// the receiver fails the first attempt, then checks and accepts the
+	// payload
+	const secret = "0123456789abcdef"
+	var calls atomic.Int32
+	received := make(chan webhookPayload, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get("X-Webhook-Timestamp"), 10, 64)
+		if got := r.Header.Get("X-Webhook-Signature"); got != signWebhook(secret, timestamp, body) {
+			t.Errorf("got signature %q", got)
+		}
+
+		var payload webhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Error(err)
+		}
+		received <- payload
+	}))
+	defer receiver.Close()
+
+	// rejected deliveries aren't retried
+	rejecter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer rejecter.Close()
+
+	minMag := 4.0
+	id, err := webhooks.Insert(models.Webhook{URL: receiver.URL, MinMagnitude: &minMag, Secret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejectedID, err := webhooks.Insert(models.Webhook{URL: rejecter.URL, Secret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the receivers listen on loopback
+	config := &Config{
+		WebhookAttempts:     3,
+		WebhookBackoff:      time.Millisecond,
+		WebhookMaxBackoff:   time.Millisecond,
+		WebhookWorkers:      2,
+		WebhookAllowPrivate: true,
+	}
+	entries := &models.EntryModel{DB: db}
+	dispatcher, err := NewDispatcher(discardLogger(), config, webhooks, entries, &models.ProgressModel{DB: db})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a change made while the dispatcher isn't running is delivered once it
+	// runs
+	insertTestEntries(t, entries, models.Entry{GUID: "small", Latitude: 45, Longitude: -75, Magnitude: 2})
+
+	done := make(chan struct{})
+	go func() {
+		dispatcher.Run(ctx)
+		close(done)
+	}()
+
+	entries.OnChange = dispatcher.Notify
+	insertTestEntries(t, entries, models.Entry{GUID: "big", Latitude: 45, Longitude: -75, Magnitude: 5})
+
+	select {
+	case payload := <-received:
+		if payload.Type != "event.created" || payload.WebhookID != id || payload.ChangeID != 2 || payload.Event.GUID != "big" {
+			t.Errorf("got %+v, want big created", payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a delivery")
+	}
+
+	deliveries := waitForDeliveries(t, webhooks, id, 2)
+	if len(deliveries) != 2 || deliveries[1].StatusCode != http.StatusServiceUnavailable || deliveries[1].Error == "" ||
+		deliveries[0].Attempt != 2 || deliveries[0].StatusCode != http.StatusOK || deliveries[0].Error != "" {
+		t.Errorf("got %+v, want a failed attempt then a delivery", deliveries)
+	}
+
+	deliveries = waitForDeliveries(t, webhooks, rejectedID, 2)
+	if len(deliveries) != 2 || deliveries[0].StatusCode != http.StatusGone || deliveries[0].Attempt != 1 {
+		t.Errorf("got %+v, want one rejected attempt for each event", deliveries)
+	}
+
+	// the dispatcher stops with its context
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("dispatcher still running after cancel")
+	}
+}
+
+// waitForDeliveries returns the delivery log of a webhook once it has n
+// attempts.
+func waitForDeliveries(t *testing.T, webhooks *models.WebhookModel, id int64, n int) []models.WebhookDelivery {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		deliveries, total, err := webhooks.Deliveries(id, 100, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total >= n || time.Now().After(deadline) {
+			return deliveries
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitForPending returns the pending deliveries once there are n of them.
+func waitForPending(t *testing.T, webhooks *models.WebhookModel, n int) []models.PendingDelivery {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		pending, err := webhooks.Pending()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pending) == n || time.Now().After(deadline) {
+			return pending
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRefusePrivate(t *testing.T) {
+	for _, tt := range []struct {
+		address string
+		public  bool
+	}{
+		{"93.184.215.14:443", true},
+		{"[2606:2800:21f:cb07:6820:80da:af6b:8b2c]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"100.64.0.1:80", false},
+		{"0.0.0.0:80", false},
+		{"[::]:80", false},
+		{"[fd00::1]:80", false},
+		{"[fe80::1]:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"224.0.0.1:80", false},
+	} {
+		if err := refusePrivate("tcp", tt.address, nil); (err == nil) != tt.public {
+			t.Errorf("%s: got %v, want public %t", tt.address, err, tt.public)
+		}
+	}
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("delivered to a loopback address")
+	}))
+	defer receiver.Close()
+
+	_, err := newWebhookClient(false).Get(receiver.URL)
+	if err == nil || !strings.Contains(err.Error(), "not public") {
+		t.Errorf("got %v, want the loopback address refused", err)
+	}
+}
+
+func TestSignWebhook(t *testing.T) {
+	// echo -n '1700000000.{}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"
+	if got := signWebhook("secret", 1700000000, []byte("{}")); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestDispatcherWorkers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db := newTestDB(t)
+	webhooks := &models.WebhookModel{DB: db}
+
+	// the receiver holds each delivery a while to see how many overlap
+	var inFlight, most atomic.Int32
+	var delivered atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := most.Load()
+			if n <= m || most.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		delivered.Add(1)
+	}))
+	defer receiver.Close()
+
+	for range 6 {
+		if _, err := webhooks.Insert(models.Webhook{URL: receiver.URL, Secret: "0123456789abcdef"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := &Config{WebhookAttempts: 1, WebhookWorkers: 2, WebhookAllowPrivate: true}
+	entries := &models.EntryModel{DB: db}
+	dispatcher, err := NewDispatcher(discardLogger(), config, webhooks, entries, &models.ProgressModel{DB: db})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries.OnChange = dispatcher.Notify
+	done := make(chan struct{})
+	go func() {
+		dispatcher.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	insertTestEntries(t, entries,
+		models.Entry{GUID: "a", Latitude: 45, Longitude: -75, Magnitude: 2},
+		models.Entry{GUID: "b", Latitude: 45, Longitude: -75, Magnitude: 3},
+	)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for delivered.Load() < 12 {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d deliveries, want 12", delivered.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := most.Load(); n > 2 {
+		t.Errorf("got %d deliveries at once, want at most 2", n)
+	}
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	db := newTestDB(t)
+	webhooks := &models.WebhookModel{DB: db}
+
+	// the first receiver is down until it comes back
+	var back atomic.Bool
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !back.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer down.Close()
+	received := make(chan struct{}, 10)
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+	}))
+	defer up.Close()
+
+	downID, err := webhooks.Insert(models.Webhook{URL: down.URL, Secret: "0123456789abcdef"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := webhooks.Insert(models.Webhook{URL: up.URL, Secret: "0123456789abcdef"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a single worker, and a retry far off
+	config := &Config{
+		WebhookAttempts:     3,
+		WebhookBackoff:      time.Hour,
+		WebhookMaxBackoff:   time.Hour,
+		WebhookWorkers:      1,
+		WebhookAllowPrivate: true,
+	}
+	entries := &models.EntryModel{DB: db}
+	progress := &models.ProgressModel{DB: db}
+	run := func(ctx context.Context) (*Dispatcher, chan struct{}) {
+		dispatcher, err := NewDispatcher(discardLogger(), config, webhooks, entries, progress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries.OnChange = dispatcher.Notify
+		done := make(chan struct{})
+		go func() {
+			dispatcher.Run(ctx)
+			close(done)
+		}()
+		return dispatcher, done
+	}
+
+	_, done := run(ctx)
+	insertTestEntries(t, entries, models.Entry{GUID: "a", Latitude: 45, Longitude: -75, Magnitude: 2})
+
+	// the retry waiting for the receiver that is down doesn't hold the
+	// worker
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the delivery to the receiver that is up")
+	}
+
+	pending := waitForPending(t, webhooks, 1)
+	cancel()
+	<-done
+
+	if len(pending) != 1 || pending[0].WebhookID != downID || pending[0].Attempt != 2 || !pending[0].DueAt.After(time.Now()) {
+		t.Fatalf("got %+v, want the second attempt pending", pending)
+	}
+
+	// the pending attempt is made when the dispatcher runs again
+	if err := webhooks.ReschedulePending(pending[0].ID, 2, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	back.Store(true)
+	ctx, cancel = context.WithCancel(context.Background())
+	_, done = run(ctx)
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deliveries := waitForDeliveries(t, webhooks, downID, 2)
+	if len(deliveries) != 2 || deliveries[0].Attempt != 2 || deliveries[0].StatusCode != http.StatusOK {
+		t.Errorf("got %+v, want the second attempt accepted", deliveries)
+	}
+	if pending := waitForPending(t, webhooks, 0); len(pending) != 0 {
+		t.Errorf("got %+v pending after the delivery", pending)
+	}
+	select {
+	case <-received:
+		t.Error("delivered again to the receiver that was up")
+	default:
+	}
+}
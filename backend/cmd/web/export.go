@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+// exportRow is one event in the CSV and NDJSON exports.
+type exportRow struct {
+	GUID          string     `json:"id"`
+	Time          *time.Time `json:"time"`
+	Latitude      float32    `json:"latitude"`
+	Longitude     float32    `json:"longitude"`
+	DepthKm       float64    `json:"depth_km"`
+	Magnitude     float32    `json:"magnitude"`
+	MagnitudeType string     `json:"magnitude_type"`
+	Place         string     `json:"place"`
+	Title         string     `json:"title"`
+	Updated       *time.Time `json:"updated"`
+	DistanceKm    *float64   `json:"distance_km,omitempty"`
+}
+
+func newExportRow(entry models.Entry, near bool) exportRow {
+	row := exportRow{
+		GUID:          entry.GUID,
+		Time:          entry.Time,
+		Latitude:      entry.Latitude,
+		Longitude:     entry.Longitude,
+		DepthKm:       float64(-entry.Elevation) / 1000,
+		Magnitude:     entry.Magnitude,
+		MagnitudeType: entry.MagnitudeType,
+		Place:         entry.Place,
+		Title:         entry.Title,
+		Updated:       entry.Updated,
+	}
+	if near {
+		row.DistanceKm = &entry.DistanceKm
+	}
+
+	return row
+}
+
+var csvHeader = []string{"id", "time", "latitude", "longitude", "depth_km", "magnitude", "magnitude_type", "place", "title", "updated"}
+
+func (row exportRow) csvRecord() []string {
+	record := []string{
+		row.GUID,
+		formatTime(row.Time),
+		strconv.FormatFloat(float64(row.Latitude), 'f', -1, 32),
+		strconv.FormatFloat(float64(row.Longitude), 'f', -1, 32),
+		strconv.FormatFloat(row.DepthKm, 'f', -1, 64),
+		strconv.FormatFloat(float64(row.Magnitude), 'f', -1, 32),
+		row.MagnitudeType,
+		row.Place,
+		row.Title,
+		formatTime(row.Updated),
+	}
+	if row.DistanceKm != nil {
+		record = append(record, strconv.FormatFloat(*row.DistanceKm, 'f', 3, 64))
+	}
+
+	return record
+}
+
+// handleExportEntries serves the events query in a fixed format, as a file
+// download.
+func handleExportEntries(logger *slog.Logger, entries *models.EntryModel, format string) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			invalid := &validationError{}
+			q, near := parseEntriesQuery(r, invalid)
+
+			if err := invalid.err(); err != nil {
+				writeInvalid(w, r, logger, err)
+				return
+			}
+
+			streamEntries(w, r, logger, entries, q, near, format)
+		},
+	)
+}
+
+// streamEntries writes the entries matching q as CSV or NDJSON while they are
+// read from the database. Once the first row is written the status can't
+// change, so later errors cut the response short and are only logged.
+func streamEntries(w http.ResponseWriter, r *http.Request, logger *slog.Logger, entries *models.EntryModel, q *models.EntryQuery, near bool, format string) {
+	start := time.Now()
+
+	contentType := "text/csv; charset=utf-8"
+	if format == formatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	filename := fmt.Sprintf("events-%s.%s", start.UTC().Format("20060102T150405Z"), format)
+
+	var cw *csv.Writer
+	var enc *json.Encoder
+	if format == formatCSV {
+		cw = csv.NewWriter(w)
+	} else {
+		enc = json.NewEncoder(w)
+	}
+
+	// the headers wait for the first row, so a failed query can still get
+	// an error response
+	var started bool
+	begin := func() error {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		w.WriteHeader(http.StatusOK)
+		started = true
+
+		if cw == nil {
+			return nil
+		}
+		if near {
+			return cw.Write(append(csvHeader[:len(csvHeader):len(csvHeader)], "distance_km"))
+		}
+		return cw.Write(csvHeader)
+	}
+
+	var count int
+	err := entries.Each(q, func(entry models.Entry) error {
+		if !started {
+			if err := begin(); err != nil {
+				return err
+			}
+		}
+
+		count = count + 1
+		row := newExportRow(entry, near)
+		if cw != nil {
+			return cw.Write(row.csvRecord())
+		}
+		return enc.Encode(row)
+	})
+	if err != nil && !started {
+		writeServerError(w, r, logger, err)
+		return
+	}
+	if err == nil && !started {
+		err = begin()
+	}
+	if err == nil && cw != nil {
+		cw.Flush()
+		err = cw.Error()
+	}
+	if err != nil {
+		logger.Error("streaming entries", "format", format, "error", err)
+		return
+	}
+
+	logger.Info("StreamEntries",
+		"time_ms", time.Since(start),
+		"format", format,
+		"count", count)
+}
+
+// formatTime writes t as RFC 3339, or nothing when it is nil.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+func TestExportEntries(t *testing.T) {
+	entries := &models.EntryModel{DB: newTestDB(t)}
+	insertTestEntries(t, entries,
+		models.Entry{GUID: "a", Title: "M 1.2, Montréal", Latitude: 45.5, Longitude: -73.6, Magnitude: 1.2, Elevation: -5000},
+		models.Entry{GUID: "b", Latitude: 49.1, Longitude: -122.7, Magnitude: 2.6, Elevation: -18400},
+		models.Entry{GUID: "c", Latitude: 53.9, Longitude: -167.1, Magnitude: 4.3, Elevation: -45100},
+	)
+
+	t.Run("csv", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/events.csv?coords=-180,-90,180,90&minmag=1", nil)
+		handleExportEntries(discardLogger(), entries, formatCSV).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+		}
+		if got := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(got, `attachment; filename="events-`) || !strings.HasSuffix(got, `.csv"`) {
+			t.Errorf("got Content-Disposition %q", got)
+		}
+
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 4 || strings.Join(records[0], ",") != strings.Join(csvHeader, ",") {
+			t.Fatalf("got %v", records)
+		}
+		if want := "c,2025-10-03T00:00:00Z,53.9,-167.1,45.1,4.3"; strings.Join(records[1][:6], ",") != want {
+			t.Errorf("got row %v, want %s", records[1], want)
+		}
+		if records[3][8] != "M 1.2, Montréal" {
+			t.Errorf("got title %q", records[3][8])
+		}
+	})
+
+	t.Run("ndjson near", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/events.ndjson?lat=50&lng=-120&radiuskm=3300&sort=distance", nil)
+		handleExportEntries(discardLogger(), entries, formatNDJSON).ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Type"); got != "application/x-ndjson" {
+			t.Errorf("got Content-Type %q", got)
+		}
+
+		var got []string
+		scanner := bufio.NewScanner(rec.Body)
+		for scanner.Scan() {
+			var row exportRow
+			if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+				t.Fatal(err)
+			}
+			if row.DistanceKm == nil {
+				t.Errorf("%s has no distance", row.GUID)
+			}
+			got = append(got, row.GUID)
+		}
+
+		if strings.Join(got, "") != "bc" {
+			t.Errorf("got %v, want b then c", got)
+		}
+	})
+
+	t.Run("empty csv keeps the header", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/events.csv?coords=0,0,1,1", nil)
+		handleExportEntries(discardLogger(), entries, formatCSV).ServeHTTP(rec, req)
+
+		if got := strings.TrimSpace(rec.Body.String()); got != strings.Join(csvHeader, ",") {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/events.csv?coords=1,2", nil)
+		handleExportEntries(discardLogger(), entries, formatCSV).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("got status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+		}
+	})
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/earthquake-service/internal/geo"
+	"github.com/earthquake-service/internal/models"
+)
+
+// parseEntriesQuery reads the query of the events endpoints: either a coords
+// box or a lat, lng and radiuskm circle, the sort order, the filters and the
+// cursor of an earlier page.
+// near is set for circles. Rejected parameters are added to invalid.
+func parseEntriesQuery(r *http.Request, invalid *validationError) (q *models.EntryQuery, near bool) {
+	q = models.NewEntryQuery()
+
+	lat, lng, radiusKm, near := parseRadius(r, invalid)
+
+	coords := r.URL.Query().Get("coords")
+	switch {
+	case near && coords != "":
+		invalid.add("coords", "can't be combined with lat, lng and radiuskm")
+	case !near && coords == "":
+		invalid.add("coords", "is required unless lat, lng and radiuskm are given")
+	case !near:
+		box, err := parseBox(coords)
+		if err != nil {
+			invalid.add("coords", err.Error())
+		}
+		q.WithinBox(box)
+	}
+
+	sort := r.URL.Query().Get("sort")
+	switch sort {
+	case "", "time":
+	case "distance":
+		if !near {
+			invalid.add("sort", "distance requires lat, lng and radiuskm")
+		}
+		q.OrderByDistance()
+	default:
+		invalid.add("sort", fmt.Sprintf("must be time or distance, got %q", sort))
+	}
+
+	parseEntryFilters(r, q, invalid)
+
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		cursor, err := models.ParseCursor(v)
+		switch {
+		case err != nil:
+			invalid.add("cursor", "must be the next cursor of an earlier page")
+		case r.URL.Query().Get("offset") != "":
+			invalid.add("offset", "can't be combined with cursor")
+		case sort == "distance" && cursor.Offset == 0:
+			invalid.add("cursor", "was not made for sort=distance")
+		default:
+			q.After(cursor)
+		}
+	}
+
+	if near && invalid.err() == nil {
+		q.Near(lat, lng, radiusKm)
+	}
+
+	return q, near
+}
+
+// parseAggregateQuery reads the query of the endpoints summarizing the events
+// in a region: a required coords box and the filters. Circles are refined in
+// Go after the query, which aggregates can't do, so they are rejected.
+// Rejected parameters are added to invalid.
+func parseAggregateQuery(r *http.Request, invalid *validationError) *models.EntryQuery {
+	q := models.NewEntryQuery()
+
+	coords := r.URL.Query().Get("coords")
+	if coords == "" {
+		invalid.add("coords", "is required")
+	} else {
+		box, err := parseBox(coords)
+		if err != nil {
+			invalid.add("coords", err.Error())
+		}
+		q.WithinBox(box)
+	}
+
+	for _, name := range []string{"lat", "lng", "radiuskm"} {
+		if r.URL.Query().Has(name) {
+			invalid.add(name, "is not supported here, use coords")
+		}
+	}
+
+	parseEntryFilters(r, q, invalid)
+
+	return q
+}
+
+// parseEntryFilters adds the start, end, minmag, maxmag, mindepth, maxdepth,
+// limit and offset query parameters of r to q. Rejected parameters are added
+// to invalid.
+func parseEntryFilters(r *http.Request, q *models.EntryQuery, invalid *validationError) {
+	query := r.URL.Query()
+
+	start, err := parseTimeParam(query.Get("start"))
+	if err != nil {
+		invalid.add("start", err.Error())
+	}
+	end, err := parseTimeParam(query.Get("end"))
+	if err != nil {
+		invalid.add("end", err.Error())
+	}
+	if !start.IsZero() && !end.IsZero() && end.Before(start) {
+		invalid.add("end", "must not be before start")
+	}
+
+	minMag, hasMinMag, err := parseFloatParam(query.Get("minmag"), -2, 10)
+	if err != nil {
+		invalid.add("minmag", err.Error())
+	}
+	maxMag, hasMaxMag, err := parseFloatParam(query.Get("maxmag"), -2, 10)
+	if err != nil {
+		invalid.add("maxmag", err.Error())
+	}
+	if hasMinMag && hasMaxMag && maxMag < minMag {
+		invalid.add("maxmag", "must not be less than minmag")
+	}
+
+	minDepth, hasMinDepth, err := parseFloatParam(query.Get("mindepth"), -10, 1000)
+	if err != nil {
+		invalid.add("mindepth", err.Error())
+	}
+	maxDepth, hasMaxDepth, err := parseFloatParam(query.Get("maxdepth"), -10, 1000)
+	if err != nil {
+		invalid.add("maxdepth", err.Error())
+	}
+	if hasMinDepth && hasMaxDepth && maxDepth < minDepth {
+		invalid.add("maxdepth", "must not be less than mindepth")
+	}
+
+	limit, err := parseIntParam(query.Get("limit"), 1)
+	if err != nil {
+		invalid.add("limit", err.Error())
+	}
+	offset, err := parseIntParam(query.Get("offset"), 0)
+	if err != nil {
+		invalid.add("offset", err.Error())
+	}
+
+	if !start.IsZero() {
+		q.Since(start)
+	}
+	if !end.IsZero() {
+		q.Until(end)
+	}
+	if hasMinMag {
+		q.MinMagnitude(minMag)
+	}
+	if hasMaxMag {
+		q.MaxMagnitude(maxMag)
+	}
+	if hasMinDepth {
+		q.MinDepth(minDepth)
+	}
+	if hasMaxDepth {
+		q.MaxDepth(maxDepth)
+	}
+	q.Limit(limit).Offset(offset)
+}
+
+// parseEventFilter reads the optional coords and minmag parameters that
+// select the events pushed to a subscriber. Rejected parameters are added to
+// invalid.
+func parseEventFilter(query url.Values, invalid *validationError) eventFilter {
+	var filter eventFilter
+
+	if coords := query.Get("coords"); coords != "" {
+		box, err := parseBox(coords)
+		if err != nil {
+			invalid.add("coords", err.Error())
+		}
+		filter.box = &box
+	}
+
+	minMag, hasMinMag, err := parseFloatParam(query.Get("minmag"), -2, 10)
+	if err != nil {
+		invalid.add("minmag", err.Error())
+	}
+	if hasMinMag {
+		filter.minMagnitude = &minMag
+	}
+
+	return filter
+}
+
+// parseRadius reads the lat, lng and radiuskm query parameters, which select
+// events within radiuskm of a point. ok is false when none of them are given.
+func parseRadius(r *http.Request, invalid *validationError) (lat, lng, radiusKm float64, ok bool) {
+	query := r.URL.Query()
+
+	if !query.Has("lat") && !query.Has("lng") && !query.Has("radiuskm") {
+		return 0, 0, 0, false
+	}
+
+	lat, hasLat, err := parseFloatParam(query.Get("lat"), -90, 90)
+	if err != nil {
+		invalid.add("lat", err.Error())
+	}
+	lng, hasLng, err := parseFloatParam(query.Get("lng"), -180, 180)
+	if err != nil {
+		invalid.add("lng", err.Error())
+	}
+	// half the circumference reaches every point on the globe
+	radiusKm, hasRadius, err := parseFloatParam(query.Get("radiuskm"), 0, math.Pi*geo.EarthRadiusKm)
+	if err != nil {
+		invalid.add("radiuskm", err.Error())
+	} else if hasRadius && radiusKm == 0 {
+		invalid.add("radiuskm", "must be greater than 0")
+	}
+
+	for _, p := range []struct {
+		name string
+		has  bool
+	}{{"lat", hasLat}, {"lng", hasLng}, {"radiuskm", hasRadius}} {
+		if !p.has && query.Get(p.name) == "" {
+			invalid.add(p.name, "is required with lat, lng and radiuskm")
+		}
+	}
+
+	return lat, lng, radiusKm, true
+}
+
+// parseCoords reads a "swlng,swlat,nelng,nelat" bounding box.
+func parseCoords(v string) (swlng, swlat, nelng, nelat float64, err error) {
+	coords := strings.Split(v, ",")
+	if len(coords) != 4 {
+		return 0, 0, 0, 0, fmt.Errorf("expected 4 comma separated numbers swlng,swlat,nelng,nelat, got %d values", len(coords))
+	}
+
+	names := []string{"swlng", "swlat", "nelng", "nelat"}
+	values := make([]float64, 4)
+	for i, c := range coords {
+		values[i], err = strconv.ParseFloat(strings.TrimSpace(c), 64)
+		if err != nil {
+			return 0, 0, 0, 0, fmt.Errorf("%s: expected a number, got %q", names[i], c)
+		}
+	}
+
+	return values[0], values[1], values[2], values[3], nil
+}
+
+// parseBox reads a "swlng,swlat,nelng,nelat" bounding box, which may cross
+// the antimeridian.
+func parseBox(v string) (geo.Box, error) {
+	swlng, swlat, nelng, nelat, err := parseCoords(v)
+	if err != nil {
+		return geo.Box{}, err
+	}
+
+	return geo.NewBox(swlng, swlat, nelng, nelat)
+}
+
+// parsePage reads the limit and offset query parameters. A missing limit is
+// defaultLimit and larger limits are capped at maxLimit.
+func parsePage(r *http.Request, defaultLimit, maxLimit int, invalid *validationError) (limit, offset int) {
+	limit, err := parseIntParam(r.URL.Query().Get("limit"), 1)
+	if err != nil {
+		invalid.add("limit", err.Error())
+	}
+	if limit == 0 {
+		limit = defaultLimit
+	}
+
+	offset, err = parseIntParam(r.URL.Query().Get("offset"), 0)
+	if err != nil {
+		invalid.add("offset", err.Error())
+	}
+
+	return min(limit, maxLimit), offset
+}
+
+// parseTimeParam reads an RFC 3339 timestamp or a plain date, which is taken
+// as midnight UTC. An empty value gives the zero time.
+func parseTimeParam(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err == nil {
+		return t, nil
+	}
+
+	t, err = time.Parse(time.DateOnly, v)
+	if err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("expected an RFC 3339 time or YYYY-MM-DD date, got %q", v)
+}
+
+// parseFloatParam reads a number between lo and hi. ok is false when v is
+// empty.
+func parseFloatParam(v string, lo, hi float64) (f float64, ok bool, err error) {
+	if v == "" {
+		return 0, false, nil
+	}
+
+	f, err = strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, false, fmt.Errorf("expected a number, got %q", v)
+	}
+
+	if f < lo || f > hi {
+		return 0, false, fmt.Errorf("must be between %g and %g, got %g", lo, hi, f)
+	}
+
+	return f, true, nil
+}
+
+// parseIntParam reads an integer of at least lo. An empty value gives zero.
+func parseIntParam(v string, lo int) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		var numErr *strconv.NumError
+		if errors.As(err, &numErr) && errors.Is(numErr.Err, strconv.ErrRange) {
+			return 0, fmt.Errorf("is too large, got %q", v)
+		}
+		return 0, fmt.Errorf("expected an integer, got %q", v)
+	}
+
+	if n < lo {
+		return 0, fmt.Errorf("must be at least %d, got %d", lo, n)
+	}
+
+	return n, nil
+}
+
+// Response formats of the events endpoint.
+const (
+	formatJSON    = "json"
+	formatGeoJSON = "geojson"
+	formatCSV     = "csv"
+	formatNDJSON  = "ndjson"
+)
+
+// formatMediaTypes maps the media types accepted in an Accept header to the
+// formats they select.
+var formatMediaTypes = map[string]string{
+	"application/geo+json": formatGeoJSON,
+	"text/csv":             formatCSV,
+	"application/x-ndjson": formatNDJSON,
+	"application/ndjson":   formatNDJSON,
+}
+
+// parseFormat reads the format query parameter, falling back to the first
+// known media type of the Accept header and then to formatJSON.
+func parseFormat(r *http.Request, invalid *validationError) string {
+	switch v := r.URL.Query().Get("format"); v {
+	case formatJSON, formatGeoJSON, formatCSV, formatNDJSON:
+		return v
+	case "":
+	default:
+		invalid.add("format", fmt.Sprintf("must be one of %s, %s, %s or %s, got %q",
+			formatJSON, formatGeoJSON, formatCSV, formatNDJSON, v))
+		return formatJSON
+	}
+
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaType := range strings.Split(accept, ",") {
+			mediaType, _, _ = strings.Cut(mediaType, ";")
+			if format, ok := formatMediaTypes[strings.TrimSpace(mediaType)]; ok {
+				return format
+			}
+		}
+	}
+
+	return formatJSON
+}
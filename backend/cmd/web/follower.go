@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+const (
+	// followPage is how many changes a follower reads at a time.
+	followPage = 100
+	// followRetry is how long a follower waits to read the changes again
+	// when it failed and nothing new wakes it.
+	followRetry = time.Minute
+)
+
+// changeFollower reads the changes to entries in order from the last one
+// it processed, which it stores under its consumer name. A burst of changes
+// or a restart delays their processing but doesn't lose any.
+type changeFollower struct {
+	logger   *slog.Logger
+	consumer string
+	entries  *models.EntryModel
+	progress *models.ProgressModel
+	last     int64
+	wake     chan struct{}
+}
+
+// newChangeFollower carries on from the progress stored for consumer. A new
+// consumer starts with the changes made after it is created, not with the
+// entries already stored.
+func newChangeFollower(logger *slog.Logger, consumer string, entries *models.EntryModel, progress *models.ProgressModel) (*changeFollower, error) {
+	last, err := progress.Get(consumer)
+	if errors.Is(err, models.ErrNoRecord) {
+		last, err = entries.LastChangeID()
+		if err == nil {
+			err = progress.Set(consumer, last)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &changeFollower{
+		logger:   logger,
+		consumer: consumer,
+		entries:  entries,
+		progress: progress,
+		last:     last,
+		wake:     make(chan struct{}, 1),
+	}, nil
+}
+
+// Notify wakes the follower to read the new changes. It never blocks, and
+// the changes are read back from the database rather than passed on. Its
+// signature fits models.EntryModel.OnChange.
+func (f *changeFollower) Notify(models.Entry, models.Change) {
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// follow calls process with each change after the last one processed, then
+// with the new changes it is notified of, until ctx is cancelled.
+func (f *changeFollower) follow(ctx context.Context, process func(models.EntryChange)) {
+	retry := time.NewTicker(followRetry)
+	defer retry.Stop()
+
+	for {
+		f.catchUp(ctx, process)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-f.wake:
+		case <-retry.C:
+		}
+	}
+}
+
+// catchUp processes the changes made since the last one processed,
+// storing the progress after each.
+func (f *changeFollower) catchUp(ctx context.Context, process func(models.EntryChange)) {
+	for ctx.Err() == nil {
+		changes, err := f.entries.ChangesSince(f.last, followPage)
+		if err != nil {
+			f.logger.Error("reading changes", "consumer", f.consumer, "error", err)
+			return
+		}
+		if len(changes) == 0 {
+			return
+		}
+
+		for _, c := range changes {
+			process(c)
+			// a change cut short is processed again on the next run
+			if ctx.Err() != nil {
+				return
+			}
+
+			f.last = c.Entry.ChangeID
+			if err := f.progress.Set(f.consumer, f.last); err != nil {
+				f.logger.Error("storing change progress", "consumer", f.consumer, "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+// featureCollection is a GeoJSON (RFC 7946) FeatureCollection of events.
+// Next is a foreign member holding the cursor of the following page.
+type featureCollection struct {
+	Type     string    `json:"type"`
+	Features []feature `json:"features"`
+	Next     string    `json:"next,omitempty"`
+}
+
+type feature struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id"`
+	Geometry   point             `json:"geometry"`
+	Properties featureProperties `json:"properties"`
+}
+
+// point is a GeoJSON Point. Its coordinates are longitude, latitude and the
+// depth of the event in metres, as given by the negated elevation.
+type point struct {
+	Type        string     `json:"type"`
+	Coordinates [3]float64 `json:"coordinates"`
+}
+
+type featureProperties struct {
+	Title         string     `json:"title"`
+	Content       string     `json:"content"`
+	Categories    string     `json:"categories"`
+	Place         string     `json:"place"`
+	Time          *time.Time `json:"time"`
+	Updated       *time.Time `json:"updated"`
+	Elevation     int32      `json:"elevation"`
+	Magnitude     float32    `json:"magnitude"`
+	MagnitudeType string     `json:"magnitude_type"`
+	DistanceKm    *float64   `json:"distance_km,omitempty"`
+}
+
+// newFeatureCollection converts entries to features. The distance of each
+// entry is included when near is set.
+func newFeatureCollection(entries []models.Entry, near bool) featureCollection {
+	fc := featureCollection{
+		Type:     "FeatureCollection",
+		Features: []feature{},
+	}
+
+	for _, entry := range entries {
+		var distance *float64
+		if near {
+			distance = &entry.DistanceKm
+		}
+
+		fc.Features = append(fc.Features, feature{
+			Type: "Feature",
+			ID:   entry.GUID,
+			Geometry: point{
+				Type:        "Point",
+				Coordinates: [3]float64{models.Widen(entry.Longitude), models.Widen(entry.Latitude), float64(-entry.Elevation)},
+			},
+			Properties: featureProperties{
+				Title:         entry.Title,
+				Content:       entry.Content,
+				Categories:    entry.Categories,
+				Place:         entry.Place,
+				Time:          entry.Time,
+				Updated:       entry.Updated,
+				Elevation:     entry.Elevation,
+				Magnitude:     entry.Magnitude,
+				MagnitudeType: entry.MagnitudeType,
+				DistanceKm:    distance,
+			},
+		})
+	}
+
+	return fc
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+func TestNewFeatureCollection(t *testing.T) {
+	at := time.Date(2025, 10, 16, 22, 0, 0, 0, time.UTC)
+	fc := newFeatureCollection([]models.Entry{
+		{GUID: "a", Latitude: 45.1, Longitude: -73.6, Elevation: -18400, Magnitude: 2.6, Time: &at},
+	}, false)
+
+	js, err := json.Marshal(fc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Type     string
+		Features []struct {
+			Type     string
+			ID       string
+			Geometry struct {
+				Type        string
+				Coordinates []float64
+			}
+			Properties map[string]any
+		}
+	}
+	if err := json.Unmarshal(js, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Type != "FeatureCollection" || len(got.Features) != 1 {
+		t.Fatalf("got %s", js)
+	}
+
+	f := got.Features[0]
+	if f.Type != "Feature" || f.ID != "a" || f.Geometry.Type != "Point" {
+		t.Errorf("got feature %s", js)
+	}
+	if c := f.Geometry.Coordinates; len(c) != 3 || c[0] != -73.6 || c[1] != 45.1 || c[2] != 18400 {
+		t.Errorf("got coordinates %v, want [-73.6 45.1 18400]", c)
+	}
+	if f.Properties["magnitude"] != 2.6 || f.Properties["time"] != "2025-10-16T22:00:00Z" {
+		t.Errorf("got properties %v", f.Properties)
+	}
+	if _, ok := f.Properties["distance_km"]; ok {
+		t.Errorf("got distance_km without a radius search")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		accept  string
+		want    string
+		invalid bool
+	}{
+		{"default", "", "", formatJSON, false},
+		{"query", "format=geojson", "", formatGeoJSON, false},
+		{"accept", "", "application/json;q=0.5, application/geo+json", formatGeoJSON, false},
+		{"query wins", "format=json", "application/geo+json", formatJSON, false},
+		{"unknown", "format=kml", "", formatJSON, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/?"+tt.query, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			invalid := &validationError{}
+			got := parseFormat(r, invalid)
+
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if (invalid.err() != nil) != tt.invalid {
+				t.Errorf("got invalid %v, want %v", invalid.err(), tt.invalid)
+			}
+		})
+	}
+}
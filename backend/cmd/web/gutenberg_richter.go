@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+	"github.com/earthquake-service/internal/seismicity"
+)
+
+// defaultMagnitudeBin is the bin width of the frequency-magnitude
+// distribution, the precision most catalogues give magnitudes to.
+const defaultMagnitudeBin = 0.1
+
+func handleGetGutenbergRichter(logger *slog.Logger, entries *models.EntryModel) http.Handler {
+	type Bin struct {
+		Magnitude  float64 `json:"magnitude"`
+		Count      int     `json:"count"`
+		Cumulative int     `json:"cumulative"`
+	}
+
+	type Analysis struct {
+		Count        int      `json:"count"`
+		BinWidth     float64  `json:"bin_width"`
+		Mc           *float64 `json:"mc"`
+		McMethod     string   `json:"mc_method"`
+		N            int      `json:"n"`
+		AValue       *float64 `json:"a_value"`
+		BValue       *float64 `json:"b_value"`
+		BUncertainty *float64 `json:"b_uncertainty"`
+		Series       []Bin    `json:"series"`
+	}
+
+	type Response struct {
+		Message string   `json:"message"`
+		Data    Analysis `json:"data"`
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			invalid := &validationError{}
+			q := parseAggregateQuery(r, invalid)
+
+			width, hasWidth, err := parseFloatParam(r.URL.Query().Get("binwidth"), 0.01, 1)
+			if err != nil {
+				invalid.add("binwidth", err.Error())
+			}
+			if !hasWidth {
+				width = defaultMagnitudeBin
+			}
+
+			mc, hasMc, err := parseFloatParam(r.URL.Query().Get("mc"), -2, 10)
+			if err != nil {
+				invalid.add("mc", err.Error())
+			}
+
+			if err := invalid.err(); err != nil {
+				writeInvalid(w, r, logger, err)
+				return
+			}
+
+			magnitudes, err := entries.Magnitudes(q)
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			var gr seismicity.GutenbergRichter
+			analysis := Analysis{
+				Count:    len(magnitudes),
+				BinWidth: width,
+				McMethod: "maximum curvature",
+				Series:   []Bin{},
+			}
+			if hasMc {
+				analysis.McMethod = "given"
+				gr, err = seismicity.AnalyzeGutenbergRichterAbove(magnitudes, width, mc)
+			} else {
+				gr, err = seismicity.AnalyzeGutenbergRichter(magnitudes, width)
+			}
+			switch {
+			case errors.Is(err, seismicity.ErrNoEvents):
+			case err != nil:
+				writeServerError(w, r, logger, err)
+				return
+			default:
+				analysis.Mc = finite(gr.Mc)
+				analysis.N = gr.N
+				analysis.AValue = finite(gr.A)
+				analysis.BValue = finite(gr.B)
+				analysis.BUncertainty = finite(gr.BUncertainty)
+				for _, bin := range gr.Bins {
+					analysis.Series = append(analysis.Series, Bin(bin))
+				}
+			}
+
+			js, _ := json.Marshal(Response{Message: "Gutenberg-Richter", Data: analysis})
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err = w.Write(js)
+			if err != nil {
+				logger.Error("writing response", "error", err)
+				return
+			}
+
+			logger.Info("GetGutenbergRichter",
+				"time_ms", time.Since(start),
+				"coords", r.URL.Query().Get("coords"),
+				"count", analysis.Count,
+				"mc", gr.Mc,
+				"b", gr.B)
+		},
+	)
+}
+
+// finite returns f, or nil when it is NaN or infinite and can't be written
+// as JSON.
+func finite(f float64) *float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
+	}
+	return &f
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+func TestHandleGetGutenbergRichter(t *testing.T) {
+	entries := &models.EntryModel{DB: newTestDB(t)}
+
+	// 8 events at 1.0, 4 at 1.1, 2 at 1.2 and 1 at 1.3, with one missed below
+	var events []models.Entry
+	for i, m := range []float32{0.9, 1, 1, 1, 1, 1, 1, 1, 1, 1.1, 1.1, 1.1, 1.1, 1.2, 1.2, 1.3} {
+		events = append(events, models.Entry{GUID: fmt.Sprint(i), Latitude: 45, Longitude: -75, Magnitude: m})
+	}
+	events = append(events, models.Entry{GUID: "far", Latitude: -45, Longitude: 170, Magnitude: 5})
+	insertTestEntries(t, entries, events...)
+
+	handler := handleGetGutenbergRichter(discardLogger(), entries)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/gutenberg-richter?coords=-80,40,-70,50", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp struct {
+		Data struct {
+			Count        int
+			Mc           *float64
+			McMethod     string `json:"mc_method"`
+			N            int
+			BValue       *float64 `json:"b_value"`
+			BUncertainty *float64 `json:"b_uncertainty"`
+			Series       []struct {
+				Magnitude  float64
+				Count      int
+				Cumulative int
+			}
+		}
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	analysis := resp.Data
+	if analysis.Count != 16 || analysis.N != 15 || analysis.Mc == nil || *analysis.Mc != 1 || analysis.McMethod != "maximum curvature" {
+		t.Fatalf("got %+v, want Mc 1 from 15 of 16 events", analysis)
+	}
+
+	// the mean of the 15 events at or above Mc is 16.1 / 15
+	want := math.Log10E / (16.1/15 - 0.95)
+	if analysis.BValue == nil || math.Abs(*analysis.BValue-want) > 1e-6 {
+		t.Errorf("got b %v, want %g", analysis.BValue, want)
+	}
+	if analysis.BUncertainty == nil || math.Abs(*analysis.BUncertainty-want/math.Sqrt(15)) > 1e-6 {
+		t.Errorf("got b uncertainty %v, want %g", analysis.BUncertainty, want/math.Sqrt(15))
+	}
+
+	if len(analysis.Series) != 5 || analysis.Series[0].Cumulative != 16 || analysis.Series[4].Magnitude != 1.3 || analysis.Series[4].Cumulative != 1 {
+		t.Errorf("got series %+v", analysis.Series)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/gutenberg-richter?coords=-80,40,-70,50&mc=1.2", nil))
+	resp.Data.BValue = nil
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.McMethod != "given" || resp.Data.N != 3 || resp.Data.BValue == nil {
+		t.Errorf("got %+v, want a fit of the 3 events from 1.2", resp.Data)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/gutenberg-richter?coords=0,0,10,10", nil))
+	var empty struct {
+		Data map[string]any
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&empty); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || empty.Data["count"] != 0.0 || empty.Data["b_value"] != nil {
+		t.Errorf("got %d %+v, want an empty analysis", rec.Code, empty.Data)
+	}
+
+	for _, query := range []string{"", "coords=-80,40,-70,50&binwidth=2", "coords=-80,40,-70,50&mc=x"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/gutenberg-richter?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: got status %d, want 400", query, rec.Code)
+		}
+	}
+}
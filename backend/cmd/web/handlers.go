@@ -2,82 +2,118 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/earthquake-service/internal/geo"
 	"github.com/earthquake-service/internal/models"
-	"github.com/mmcdole/gofeed"
+	"github.com/earthquake-service/internal/quakeml"
 )
 
-func handleRoot() http.Handler {
+// maxUploadBytes limits the size of imported documents.
+const maxUploadBytes = 32 << 20
+
+// maxBodyBytes limits the size of JSON request bodies.
+const maxBodyBytes = 64 << 10
+
+func handleRoot(logger *slog.Logger) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte(""))
+			writeProblem(w, r, logger, http.StatusNotFound, "no endpoint at this path")
 		},
 	)
 }
 
-func handleGetEntries(logger *slog.Logger, entries *models.EntryModel) http.Handler {
+func handleGetEntries(logger *slog.Logger, config *Config, entries *models.EntryModel) http.Handler {
 	type Point struct {
-		GUID       string  `json:"id"`
-		Title      string  `json:"title"`
-		Content    string  `json:"content"`
-		Categories string  `json:"categoires"`
-		Elevation  int32   `json:"elevation"`
-		Time       string  `json:"time"`
-		Latitude   float32 `json:"latitude"`
-		Longitude  float32 `json:"longitude"`
-		Magnitude  float32 `json:"magnitude"`
+		GUID          string   `json:"id"`
+		Title         string   `json:"title"`
+		Content       string   `json:"content"`
+		Categories    string   `json:"categoires"`
+		Place         string   `json:"place"`
+		Elevation     int32    `json:"elevation"`
+		Time          string   `json:"time"`
+		Latitude      float32  `json:"latitude"`
+		Longitude     float32  `json:"longitude"`
+		Magnitude     float32  `json:"magnitude"`
+		MagnitudeType string   `json:"magnitude_type"`
+		DistanceKm    *float64 `json:"distance_km,omitempty"`
 	}
 
 	type Response struct {
 		Message string  `json:"message"`
 		Data    []Point `json:"data"`
 		Count   int     `json:"count"`
+		Next    string  `json:"next,omitempty"`
 	}
 
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 
-			qCoords := r.URL.Query()["coords"]
-			if len(qCoords) == 0 {
-				logger.Info("no coordinates provided")
+			// the format may come from the Accept header
+			w.Header().Add("Vary", "Accept")
 
-				w.WriteHeader(http.StatusBadRequest)
-				_, err := w.Write([]byte(""))
-				if err != nil {
-					logger.Error("writing response", "error", err)
-					return
-				}
+			invalid := &validationError{}
+			format := parseFormat(r, invalid)
+			q, near := parseEntriesQuery(r, invalid)
+
+			if err := invalid.err(); err != nil {
+				writeInvalid(w, r, logger, err)
 				return
 			}
 
-			coords := strings.Split(qCoords[0], ",")
-
-			swlng, err := strconv.ParseFloat(coords[0], 32)
-			if err != nil {
-				logger.Error("SW Longitude is invalid", "error", err)
+			if format == formatCSV || format == formatNDJSON {
+				streamEntries(w, r, logger, entries, q, near, format)
+				return
 			}
-			swlat, err := strconv.ParseFloat(coords[1], 32)
-			if err != nil {
-				logger.Error("SW Latitude is invalid", "error", err)
+
+			// listings are paged, a missing or larger limit gets the
+			// largest page allowed
+			limit, _ := parseIntParam(r.URL.Query().Get("limit"), 1)
+			if limit == 0 || limit > config.MaxPageSize {
+				limit = config.MaxPageSize
 			}
-			nelng, err := strconv.ParseFloat(coords[2], 32)
+			q.Limit(limit)
+
+			results, cursor, err := entries.Page(q)
 			if err != nil {
-				logger.Error("NE Longitude is invalid", "error", err)
+				writeServerError(w, r, logger, err)
+				return
 			}
-			nelat, err := strconv.ParseFloat(coords[3], 32)
-			if err != nil {
-				logger.Error("NE latitude is invalid", "error", err)
+
+			var next string
+			if cursor != nil {
+				next = cursor.String()
+				w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, nextPageURL(r, next)))
 			}
 
-			results := entries.QueryWithBounds(swlat, nelat, swlng, nelng)
+			if format == formatGeoJSON {
+				fc := newFeatureCollection(results, near)
+				fc.Next = next
+
+				js, _ := json.Marshal(fc)
+
+				w.Header().Set("Content-Type", "application/geo+json")
+				w.WriteHeader(http.StatusOK)
+				_, err = w.Write(js)
+				if err != nil {
+					logger.Error("writing response", "error", err)
+					return
+				}
+
+				logger.Info("GetEntries",
+					"time_ms", time.Since(start),
+					"format", format,
+					"count", len(results))
+				return
+			}
 
 			var data []Point
 			var count int
@@ -88,16 +124,24 @@ func handleGetEntries(logger *slog.Logger, entries *models.EntryModel) http.Hand
 					t = point.Time.Format(time.RFC3339)
 				}
 
+				var distance *float64
+				if near {
+					distance = &point.DistanceKm
+				}
+
 				data = append(data, Point{
-					GUID:       point.GUID,
-					Title:      point.Title,
-					Content:    point.Content,
-					Categories: point.Categories,
-					Time:       t,
-					Elevation:  point.Elevation,
-					Latitude:   point.Latitude,
-					Longitude:  point.Longitude,
-					Magnitude:  point.Magnitude,
+					GUID:          point.GUID,
+					Title:         point.Title,
+					Content:       point.Content,
+					Categories:    point.Categories,
+					Place:         point.Place,
+					Time:          t,
+					Elevation:     point.Elevation,
+					Latitude:      point.Latitude,
+					Longitude:     point.Longitude,
+					Magnitude:     point.Magnitude,
+					MagnitudeType: point.MagnitudeType,
+					DistanceKm:    distance,
 				})
 
 				count = count + 1
@@ -107,6 +151,7 @@ func handleGetEntries(logger *slog.Logger, entries *models.EntryModel) http.Hand
 				Message: "Recent points",
 				Data:    data,
 				Count:   count,
+				Next:    next,
 			}
 
 			js, _ := json.Marshal(resp)
@@ -121,126 +166,600 @@ func handleGetEntries(logger *slog.Logger, entries *models.EntryModel) http.Hand
 
 			logger.Info("GetEntries",
 				"time_ms", time.Since(start),
-				"sq_lat", swlat,
-				"ne_lat", nelat,
-				"sw_lng", swlng,
-				"ne_lng", nelng,
+				"coords", r.URL.Query().Get("coords"),
+				"lat", r.URL.Query().Get("lat"),
+				"lng", r.URL.Query().Get("lng"),
+				"radius_km", r.URL.Query().Get("radiuskm"),
 				"count", count)
 		},
 	)
 }
 
-func handleUpdateEntries(logger *slog.Logger, config *Config, appState *State, entryModel *models.EntryModel) http.Handler {
+func handleUpdateEntries(logger *slog.Logger, config *Config, pollers []*Poller) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
-			currentWindowStart := time.Now().Add(time.Duration(-5) * time.Minute)
-			if currentWindowStart.Before(appState.LastRun) {
-				w.WriteHeader(http.StatusTooEarly)
-				w.Write([]byte(""))
+			currentWindowStart := time.Now().Add(-config.UpdateCooldown)
+
+			// an optional source parameter limits the update to one feed
+			name := r.URL.Query().Get("source")
+
+			var polled int
+			var failures []string
+			for _, poller := range pollers {
+				if name != "" && poller.Name != name {
+					continue
+				}
+				if currentWindowStart.Before(poller.State.lastRun()) {
+					continue
+				}
+
+				err := poller.PollNow(r.Context())
+				if err != nil {
+					logger.Error("error polling feed", "source", poller.Name, "error", err)
+					failures = append(failures, fmt.Sprintf("%s: %s", poller.Name, err))
+				}
+				polled = polled + 1
+			}
+
+			if name != "" && !hasPoller(pollers, name) {
+				invalid := &validationError{}
+				invalid.add("source", fmt.Sprintf("no source is named %q", name))
+				writeInvalid(w, r, logger, invalid)
 				return
 			}
 
-			fp := gofeed.Parser{}
-			feed, err := fp.ParseURL(config.AtomFeed)
-			if err != nil {
-				logger.Error("error fetching feed", "error", err)
-				w.WriteHeader(http.StatusOK)
-				appState.updateFailure()
+			if polled == 0 {
+				writeProblem(w, r, logger, http.StatusTooEarly,
+					fmt.Sprintf("sources are polled at most once every %s", config.UpdateCooldown))
 				return
 			}
 
-			var count int
-			for _, item := range feed.Items {
-				// this is where we want to make a new struct that satisfies the entry model
-				entry := models.Entry{
-					GUID:       item.GUID,
-					Title:      item.Title,
-					Content:    item.Content,
-					Categories: strings.Join(item.Categories[:], ", "),
-					Published:  item.PublishedParsed,
-					Updated:    item.UpdatedParsed,
-				}
+			if len(failures) == polled {
+				writeProblem(w, r, logger, http.StatusBadGateway,
+					"polling failed for every source, "+strings.Join(failures, "; "))
+				return
+			}
 
-				ext := item.Extensions["georss"]
-				if len(ext["elev"]) == 0 {
-					logger.Error("electation is unset", "elevation", ext["elev"])
-					continue
-				}
-				elev := ext["elev"][0].Value
-				point := ext["point"][0].Value
+			w.WriteHeader(http.StatusOK)
 
-				latlong := strings.Split(point, " ")
+			logger.Info("Update Entries",
+				"time_ms", time.Since(start),
+				"sources", polled)
+		},
+	)
+}
 
-				// latitude
-				latitude64, err := strconv.ParseFloat(latlong[0], 32)
-				if err != nil {
-					logger.Error("parsing latitude", "error", err)
-					continue
+func handleGetEntry(logger *slog.Logger, entries *models.EntryModel) http.Handler {
+	type Event struct {
+		GUID                 string     `json:"id"`
+		Title                string     `json:"title"`
+		Content              string     `json:"content"`
+		Categories           string     `json:"categoires"`
+		Place                string     `json:"place"`
+		Elevation            int32      `json:"elevation"`
+		Time                 *time.Time `json:"time"`
+		Latitude             float32    `json:"latitude"`
+		Longitude            float32    `json:"longitude"`
+		Magnitude            float32    `json:"magnitude"`
+		MagnitudeType        string     `json:"magnitude_type"`
+		MagnitudeUncertainty *float32   `json:"magnitude_uncertainty"`
+		Updated              *time.Time `json:"updated"`
+		Published            *time.Time `json:"published"`
+		SequenceID           *int64     `json:"sequence_id"`
+		Mainshock            bool       `json:"mainshock"`
+	}
+
+	type Response struct {
+		Message string `json:"message"`
+		Data    Event  `json:"data"`
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			guid := r.PathValue("guid")
+
+			entry, err := entries.Get(guid)
+			if err != nil {
+				if errors.Is(err, models.ErrNoRecord) {
+					writeProblem(w, r, logger, http.StatusNotFound, fmt.Sprintf("no event has the id %q", guid))
+					return
 				}
-				entry.Latitude = float32(latitude64)
 
-				// longitude
-				longitude64, err := strconv.ParseFloat(latlong[1], 32)
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			resp := Response{
+				Message: "Event",
+				Data: Event{
+					GUID:                 entry.GUID,
+					Title:                entry.Title,
+					Content:              entry.Content,
+					Categories:           entry.Categories,
+					Place:                entry.Place,
+					Elevation:            entry.Elevation,
+					Time:                 entry.Time,
+					Latitude:             entry.Latitude,
+					Longitude:            entry.Longitude,
+					Magnitude:            entry.Magnitude,
+					MagnitudeType:        entry.MagnitudeType,
+					MagnitudeUncertainty: entry.MagnitudeUncertainty,
+					Updated:              entry.Updated,
+					Published:            entry.Published,
+					SequenceID:           entry.SequenceID,
+					Mainshock:            entry.Mainshock,
+				},
+			}
+
+			js, _ := json.Marshal(resp)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err = w.Write(js)
+			if err != nil {
+				logger.Error("writing response", "error", err)
+				return
+			}
+		},
+	)
+}
+
+func handleExportQuakeML(logger *slog.Logger, entries *models.EntryModel) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			// without coords the whole table is exported
+			box := geo.World()
+
+			qCoords := r.URL.Query().Get("coords")
+			if qCoords != "" {
+				var err error
+				box, err = parseBox(qCoords)
 				if err != nil {
-					logger.Error("parsing longitude", "error", err)
-					continue
+					invalid := &validationError{}
+					invalid.add("coords", err.Error())
+					writeInvalid(w, r, logger, invalid)
+					return
 				}
-				entry.Longitude = float32(longitude64)
+			}
+
+			results, err := entries.Query(models.NewEntryQuery().WithinBox(box))
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/xml")
+			w.Header().Set("Content-Disposition", `attachment; filename="events.quakeml"`)
+			w.WriteHeader(http.StatusOK)
+
+			err = quakeml.Encode(w, results)
+			if err != nil {
+				logger.Error("writing response", "error", err)
+				return
+			}
+
+			logger.Info("ExportQuakeML",
+				"time_ms", time.Since(start),
+				"count", len(results))
+		},
+	)
+}
+
+func handleImportQuakeML(logger *slog.Logger, entryModel *models.EntryModel) http.Handler {
+	type Skipped struct {
+		ID     string `json:"id"`
+		Reason string `json:"reason"`
+	}
+
+	type Response struct {
+		Message   string    `json:"message"`
+		Imported  int       `json:"imported"`
+		Created   int       `json:"created"`
+		Updated   int       `json:"updated"`
+		Unchanged int       `json:"unchanged"`
+		Skipped   []Skipped `json:"skipped"`
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
 
-				// elevation
-				elevation64, err := strconv.ParseInt(elev, 10, 32)
+			r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+
+			// the document is either the request body or the "file" field of
+			// a multipart form
+			var body io.Reader = r.Body
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+				file, _, err := r.FormFile("file")
 				if err != nil {
-					logger.Error("parsing elevation", "error", err)
-					continue
+					invalid := &validationError{}
+					invalid.add("file", err.Error())
+					writeInvalid(w, r, logger, invalid)
+					return
 				}
-				entry.Elevation = int32(elevation64)
+				defer file.Close()
+				body = file
+			}
 
-				// magnitude
-				re := regexp.MustCompile(`M([\d\.]+)`)
-				match := re.FindStringSubmatch(entry.Title)
-				magnitude, err := strconv.ParseFloat(match[1], 32)
-				if err != nil {
-					logger.Error("parsing magnitude", "error", err)
-					continue
+			doc, err := quakeml.Decode(body)
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					writeProblem(w, r, logger, http.StatusRequestEntityTooLarge,
+						fmt.Sprintf("documents are limited to %d bytes", maxUploadBytes))
+					return
 				}
-				entry.Magnitude = float32(magnitude)
 
-				tc := firstN(item.Content, 20)
-				t, err := time.Parse(time.RFC3339, tc)
-				if err != nil {
-					logger.Error("parsing event time", "error", err)
-					continue
+				writeInvalid(w, r, logger, fmt.Errorf("the body is not a QuakeML document: %w", err))
+				return
+			}
+
+			resp := Response{
+				Message: "Imported events",
+				Skipped: []Skipped{},
+			}
+
+			// events without what an entry needs are skipped, but values out
+			// of range reject the document before anything is stored
+			var converted []models.Entry
+			invalid := &validationError{}
+			for _, event := range doc.EventParameters.Events {
+				entry, err := event.Entry()
+				var fieldErr *quakeml.FieldError
+				switch {
+				case errors.As(err, &fieldErr):
+					invalid.add(fieldErr.Field, fmt.Sprintf("%g is out of range in event %q", fieldErr.Value, event.PublicID))
+				case err != nil:
+					resp.Skipped = append(resp.Skipped, Skipped{ID: event.PublicID, Reason: err.Error()})
+				default:
+					converted = append(converted, entry)
 				}
-				entry.Time = &t
+			}
+			if err := invalid.err(); err != nil {
+				writeInvalid(w, r, logger, err)
+				return
+			}
 
-				_, err = entryModel.Insert(entry)
+			for _, entry := range converted {
+				change, err := entryModel.Insert(entry)
 				if err != nil {
-					logger.Error("issue storing item", "error", err)
-					appState.updateFailure()
+					writeServerError(w, r, logger, err)
 					return
 				}
-				count = count + 1
+
+				switch change {
+				case models.ChangeCreated:
+					resp.Created = resp.Created + 1
+				case models.ChangeUpdated:
+					resp.Updated = resp.Updated + 1
+				default:
+					resp.Unchanged = resp.Unchanged + 1
+				}
+				resp.Imported = resp.Imported + 1
 			}
 
+			js, _ := json.Marshal(resp)
+
+			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusOK)
-			appState.updateSuccess()
+			_, err = w.Write(js)
+			if err != nil {
+				logger.Error("writing response", "error", err)
+				return
+			}
 
-			logger.Info("Update Entries",
+			logger.Info("ImportQuakeML",
 				"time_ms", time.Since(start),
-				"count", count)
+				"imported", resp.Imported,
+				"skipped", len(resp.Skipped))
+		},
+	)
+}
+
+func handleGetEntryHistory(logger *slog.Logger, entries *models.EntryModel) http.Handler {
+	type Solution struct {
+		Time                 string   `json:"time"`
+		Latitude             float32  `json:"latitude"`
+		Longitude            float32  `json:"longitude"`
+		Elevation            int32    `json:"elevation"`
+		Magnitude            float32  `json:"magnitude"`
+		MagnitudeType        string   `json:"magnitude_type"`
+		MagnitudeUncertainty *float32 `json:"magnitude_uncertainty"`
+		Place                string   `json:"place"`
+	}
+
+	type Revision struct {
+		RevisedAt time.Time  `json:"revised_at"`
+		Updated   *time.Time `json:"updated"`
+		Old       Solution   `json:"old"`
+		New       Solution   `json:"new"`
+	}
+
+	type Response struct {
+		Message   string     `json:"message"`
+		GUID      string     `json:"id"`
+		Revisions []Revision `json:"revisions"`
+		Count     int        `json:"count"`
+	}
+
+	newSolution := func(s models.Solution) Solution {
+		var t string
+		if s.Time != nil {
+			t = s.Time.Format(time.RFC3339)
+		}
+
+		return Solution{
+			Time:                 t,
+			Latitude:             s.Latitude,
+			Longitude:            s.Longitude,
+			Elevation:            s.Elevation,
+			Magnitude:            s.Magnitude,
+			MagnitudeType:        s.MagnitudeType,
+			MagnitudeUncertainty: s.MagnitudeUncertainty,
+			Place:                s.Place,
+		}
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			guid := r.PathValue("guid")
+
+			exists, err := entries.Exists(guid)
+			if err == nil && !exists {
+				writeProblem(w, r, logger, http.StatusNotFound, fmt.Sprintf("no event has the id %q", guid))
+				return
+			}
+
+			var revisions []models.EntryRevision
+			if err == nil {
+				revisions, err = entries.History(guid)
+			}
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			resp := Response{
+				Message:   "Event history",
+				GUID:      guid,
+				Revisions: []Revision{},
+				Count:     len(revisions),
+			}
+			for _, revision := range revisions {
+				resp.Revisions = append(resp.Revisions, Revision{
+					RevisedAt: revision.RevisedAt,
+					Updated:   revision.Updated,
+					Old:       newSolution(revision.Old),
+					New:       newSolution(revision.New),
+				})
+			}
+
+			js, _ := json.Marshal(resp)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err = w.Write(js)
+			if err != nil {
+				logger.Error("writing response", "error", err)
+				return
+			}
+		},
+	)
+}
+
+type ingestRun struct {
+	ID         int64      `json:"id"`
+	Source     string     `json:"source"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	Seen       int        `json:"seen"`
+	Inserted   int        `json:"inserted"`
+	Updated    int        `json:"updated"`
+	Skipped    int        `json:"skipped"`
+	Error      string     `json:"error,omitempty"`
+}
+
+func newIngestRun(run models.IngestRun) ingestRun {
+	return ingestRun{
+		ID:         run.ID,
+		Source:     run.Source,
+		StartedAt:  run.StartedAt,
+		FinishedAt: run.FinishedAt,
+		Seen:       run.Seen,
+		Inserted:   run.Inserted,
+		Updated:    run.Updated,
+		Skipped:    run.Skipped,
+		Error:      run.Error,
+	}
+}
+
+func handleListIngestRuns(logger *slog.Logger, runs *models.IngestRunModel) http.Handler {
+	type Response struct {
+		Message string      `json:"message"`
+		Data    []ingestRun `json:"data"`
+		Count   int         `json:"count"`
+		Total   int         `json:"total"`
+		Limit   int         `json:"limit"`
+		Offset  int         `json:"offset"`
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			invalid := &validationError{}
+			limit, offset := parsePage(r, 20, 100, invalid)
+			if err := invalid.err(); err != nil {
+				writeInvalid(w, r, logger, err)
+				return
+			}
+
+			results, total, err := runs.List(limit, offset)
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			resp := Response{
+				Message: "Ingest runs",
+				Data:    []ingestRun{},
+				Total:   total,
+				Limit:   limit,
+				Offset:  offset,
+			}
+			for _, run := range results {
+				resp.Data = append(resp.Data, newIngestRun(run))
+			}
+			resp.Count = len(resp.Data)
+
+			js, _ := json.Marshal(resp)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err = w.Write(js)
+			if err != nil {
+				logger.Error("writing response", "error", err)
+				return
+			}
+		},
+	)
+}
+
+func handleGetIngestRun(logger *slog.Logger, runs *models.IngestRunModel) http.Handler {
+	type Item struct {
+		GUID    string `json:"id"`
+		Title   string `json:"title"`
+		Outcome string `json:"outcome"`
+		Reason  string `json:"reason,omitempty"`
+	}
+
+	type Run struct {
+		ingestRun
+		Items []Item `json:"items"`
+	}
+
+	type Response struct {
+		Message string `json:"message"`
+		Data    Run    `json:"data"`
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			id, ok := pathID(w, r, logger, "ingest run")
+			if !ok {
+				return
+			}
+
+			run, err := runs.Get(id)
+			if err != nil {
+				writeLookupError(w, r, logger, "ingest run", id, err)
+				return
+			}
+
+			resp := Response{
+				Message: "Ingest run",
+				Data: Run{
+					ingestRun: newIngestRun(run),
+					Items:     []Item{},
+				},
+			}
+			for _, item := range run.Items {
+				resp.Data.Items = append(resp.Data.Items, Item(item))
+			}
+
+			js, _ := json.Marshal(resp)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err = w.Write(js)
+			if err != nil {
+				logger.Error("writing response", "error", err)
+				return
+			}
 		},
 	)
 }
 
-// time of event
-func firstN(s string, n int) string {
-	i := 0
-	for j := range s {
-		if i == n {
-			return s[:j]
+// nextPageURL returns the request URL continuing from the cursor next.
+func nextPageURL(r *http.Request, next string) string {
+	query := r.URL.Query()
+	query.Del("offset")
+	query.Set("cursor", next)
+
+	u := *r.URL
+	u.RawQuery = query.Encode()
+
+	return u.RequestURI()
+}
+
+// hasPoller reports whether one of pollers is named name.
+func hasPoller(pollers []*Poller, name string) bool {
+	for _, poller := range pollers {
+		if poller.Name == name {
+			return true
 		}
-		i++
 	}
-	return s
+	return false
+}
+
+// decodeBody decodes the JSON body of r into dst, which is described as what
+// in errors. It responds with a problem and returns false when the body is
+// too large, has unknown fields, or isn't JSON.
+func decodeBody(w http.ResponseWriter, r *http.Request, logger *slog.Logger, dst any, what string) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeProblem(w, r, logger, http.StatusRequestEntityTooLarge,
+				fmt.Sprintf("request bodies are limited to %d bytes", maxBodyBytes))
+			return false
+		}
+
+		writeInvalid(w, r, logger, fmt.Errorf("the body is not %s: %w", what, err))
+		return false
+	}
+
+	return true
+}
+
+// pathID reads the id path value of r, responding with a 404 problem naming
+// what when it is not one.
+func pathID(w http.ResponseWriter, r *http.Request, logger *slog.Logger, what string) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeProblem(w, r, logger, http.StatusNotFound, fmt.Sprintf("no %s has the id %q", what, r.PathValue("id")))
+		return 0, false
+	}
+	return id, true
+}
+
+// writeLookupError responds to a failure to read or change the record id,
+// described as what.
+func writeLookupError(w http.ResponseWriter, r *http.Request, logger *slog.Logger, what string, id int64, err error) {
+	if errors.Is(err, models.ErrNoRecord) {
+		writeProblem(w, r, logger, http.StatusNotFound, fmt.Sprintf("no %s has the id %d", what, id))
+		return
+	}
+	writeServerError(w, r, logger, err)
+}
+
+// writeData responds with status, message and data.
+func writeData(w http.ResponseWriter, logger *slog.Logger, status int, message string, data any) {
+	type Response struct {
+		Message string `json:"message"`
+		Data    any    `json:"data"`
+	}
+
+	js, _ := json.Marshal(Response{Message: message, Data: data})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err := w.Write(js)
+	if err != nil {
+		logger.Error("writing response", "error", err)
+	}
 }
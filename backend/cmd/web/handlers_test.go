@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+func TestHandleGetEntriesPages(t *testing.T) {
+	entries := &models.EntryModel{DB: newTestDB(t)}
+	insertTestEntries(t, entries,
+		models.Entry{GUID: "a", Latitude: 45.5, Longitude: -73.6},
+		models.Entry{GUID: "b", Latitude: 49.1, Longitude: -122.7},
+		models.Entry{GUID: "c", Latitude: 53.9, Longitude: -167.1},
+	)
+
+	handler := handleGetEntries(discardLogger(), &Config{MaxPageSize: 2}, entries)
+	reLink := regexp.MustCompile(`^<(.+)>; rel="next"$`)
+
+	var got []string
+	target := "/api/v1/?coords=-180,-90,180,90&limit=50"
+	for target != "" {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+		}
+
+		var resp struct {
+			Data []struct {
+				GUID string `json:"id"`
+			}
+			Next string
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Data) > 2 {
+			t.Fatalf("got %d events, the page size is capped at 2", len(resp.Data))
+		}
+		for _, e := range resp.Data {
+			got = append(got, e.GUID)
+		}
+
+		target = ""
+		if link := rec.Header().Get("Link"); link != "" {
+			m := reLink.FindStringSubmatch(link)
+			if m == nil {
+				t.Fatalf("got Link %q", link)
+			}
+
+			u, err := url.Parse(m[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.Query().Get("cursor") != resp.Next || u.Query().Get("coords") == "" {
+				t.Errorf("got next page %q with next %q", m[1], resp.Next)
+			}
+			target = m[1]
+		} else if resp.Next != "" {
+			t.Errorf("got next %q without a Link header", resp.Next)
+		}
+	}
+
+	if len(got) != 3 || got[0] != "c" || got[1] != "b" || got[2] != "a" {
+		t.Errorf("got %v, want [c b a]", got)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/?coords=-180,-90,180,90&cursor=nope", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got status %d for an invalid cursor", rec.Code)
+	}
+}
+
+func TestHandleUpdateEntries(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var failing atomic.Bool
+	ok := newTestPoller(func(ctx context.Context) (int, error) {
+		if failing.Load() {
+			return 0, errors.New("feed unavailable")
+		}
+		return 1, nil
+	})
+	ok.Name = "ok"
+	broken := newTestPoller(func(ctx context.Context) (int, error) {
+		return 0, errors.New("connection refused")
+	})
+	broken.Name = "broken"
+
+	for _, p := range []*Poller{ok, broken} {
+		go p.Run(ctx)
+	}
+
+	handler := handleUpdateEntries(discardLogger(), &Config{}, []*Poller{ok, broken})
+	update := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	// one source failing doesn't fail the update
+	if rec := update("/api/v1/update"); rec.Code != http.StatusOK {
+		t.Errorf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec := update("/api/v1/update?source=broken")
+	if rec.Code != http.StatusBadGateway || !strings.Contains(rec.Body.String(), "broken: connection refused") {
+		t.Errorf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	failing.Store(true)
+	if rec := update("/api/v1/update"); rec.Code != http.StatusBadGateway {
+		t.Errorf("got status %d with every source failing: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestHandleIngestRuns(t *testing.T) {
+	runs := &models.IngestRunModel{DB: newTestDB(t)}
+
+	start := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		run := models.IngestRun{Source: "nrcan", StartedAt: start.Add(time.Duration(i) * time.Minute)}
+		run.Record(models.IngestRunItem{GUID: "a", Title: "M2.6", Outcome: models.OutcomeInserted})
+		run.Record(models.IngestRunItem{GUID: "b", Outcome: models.OutcomeUnchanged})
+		if _, err := runs.Insert(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/v1/ingest/runs", handleListIngestRuns(discardLogger(), runs))
+	mux.Handle("GET /api/v1/ingest/runs/{id}", handleGetIngestRun(discardLogger(), runs))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/api/v1/ingest/runs?limit=2&offset=1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	var list struct {
+		Data []struct {
+			ID   int64
+			Seen int
+		}
+		Count int
+		Total int
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Count != 2 || list.Total != 3 || list.Data[0].ID != 2 || list.Data[1].ID != 1 || list.Data[0].Seen != 2 {
+		t.Errorf("got %+v", list)
+	}
+
+	rec = get("/api/v1/ingest/runs/1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	var detail struct {
+		Data struct {
+			ID    int64
+			Items []struct {
+				GUID    string `json:"id"`
+				Outcome string
+			}
+		}
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&detail); err != nil {
+		t.Fatal(err)
+	}
+	if len(detail.Data.Items) != 1 || detail.Data.Items[0].GUID != "a" || detail.Data.Items[0].Outcome != "inserted" {
+		t.Errorf("got %+v", detail.Data)
+	}
+
+	if rec := get("/api/v1/ingest/runs/4"); rec.Code != http.StatusNotFound {
+		t.Errorf("got status %d for a missing run, want 404", rec.Code)
+	}
+	if rec := get("/api/v1/ingest/runs/x"); rec.Code != http.StatusNotFound {
+		t.Errorf("got status %d for a bad id, want 404", rec.Code)
+	}
+}
+
+func TestHandleImportQuakeML(t *testing.T) {
+	entries := &models.EntryModel{DB: newTestDB(t)}
+	handler := handleImportQuakeML(discardLogger(), entries)
+
+	document := func(latitude string) string {
+		return `<q:quakeml xmlns:q="http://quakeml.org/xmlns/quakeml/1.2" xmlns="http://quakeml.org/xmlns/bed/1.2">
+  <eventParameters publicID="smi:test">
+    <event publicID="smi:test/event/1">
+      <origin publicID="smi:test/origin/1">
+        <time><value>2025-10-16T22:00:00Z</value></time>
+        <latitude><value>45.5</value></latitude>
+        <longitude><value>-73.6</value></longitude>
+      </origin>
+      <magnitude publicID="smi:test/magnitude/1"><mag><value>2.6</value></mag></magnitude>
+    </event>
+    <event publicID="smi:test/event/2">
+      <origin publicID="smi:test/origin/2">
+        <time><value>2025-10-16T23:00:00Z</value></time>
+        <latitude><value>` + latitude + `</value></latitude>
+        <longitude><value>-73.6</value></longitude>
+      </origin>
+      <magnitude publicID="smi:test/magnitude/2"><mag><value>3.1</value></mag></magnitude>
+    </event>
+    <event publicID="smi:test/event/3">
+      <magnitude publicID="smi:test/magnitude/3"><mag><value>3.1</value></mag></magnitude>
+    </event>
+  </eventParameters>
+</q:quakeml>`
+	}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/events.quakeml", strings.NewReader(body)))
+		return rec
+	}
+
+	// nothing of a document with a value out of range is stored
+	rec := post(document("95"))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"latitude"`) || !strings.Contains(rec.Body.String(), "smi:test/event/2") {
+		t.Errorf("got status %d: %s", rec.Code, rec.Body)
+	}
+	if results, _ := entries.Query(models.NewEntryQuery()); len(results) != 0 {
+		t.Errorf("got %d entries stored from a rejected document", len(results))
+	}
+
+	rec = post(document("46.5"))
+	var resp struct {
+		Created int
+		Skipped []struct{ ID string }
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || resp.Created != 2 || len(resp.Skipped) != 1 || resp.Skipped[0].ID != "smi:test/event/3" {
+		t.Errorf("got status %d, %+v", rec.Code, resp)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"database/sql"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+// newTestDB opens an in-memory database with the schema applied.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	schema, err := os.ReadFile("schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", "file::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: is a new database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// insertTestEntries stores entries, giving any without a time one a day
+// apart starting on 2025-10-01.
+func insertTestEntries(t *testing.T, m *models.EntryModel, entries ...models.Entry) {
+	t.Helper()
+
+	base := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	for i, e := range entries {
+		if e.Time == nil {
+			at := base.AddDate(0, 0, i)
+			e.Time = &at
+		}
+		if _, err := m.Insert(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
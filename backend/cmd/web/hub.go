@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/earthquake-service/internal/geo"
+	"github.com/earthquake-service/internal/models"
+)
+
+// eventFilter selects the changes a subscriber receives. The zero value
+// matches every event.
+type eventFilter struct {
+	box          *geo.Box
+	minMagnitude *float64
+}
+
+func (f eventFilter) matches(e models.Entry) bool {
+	if f.box != nil && !f.box.Contains(float64(e.Latitude), float64(e.Longitude)) {
+		return false
+	}
+	if f.minMagnitude != nil && float64(e.Magnitude) < *f.minMagnitude {
+		return false
+	}
+	return true
+}
+
+// Hub fans the changes made by ingestion out to subscribers. Publishing
+// never waits on a subscriber: one whose buffer is full is dropped, and its
+// channel closed, so it can resume from the last event it saw.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives the changes matching its filter on C until it is
+// dropped or unsubscribed.
+type Subscription struct {
+	C      <-chan models.EntryChange
+	c      chan models.EntryChange
+	filter eventFilter
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: map[*Subscription]struct{}{}}
+}
+
+// Subscribe adds a subscriber with room for buffer changes it hasn't read.
+func (h *Hub) Subscribe(filter eventFilter, buffer int) *Subscription {
+	c := make(chan models.EntryChange, buffer)
+	s := &Subscription{C: c, c: c, filter: filter}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subscribers[s] = struct{}{}
+
+	return s
+}
+
+// Unsubscribe removes s. It is safe to call after s was dropped.
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[s]; ok {
+		delete(h.subscribers, s)
+		close(s.c)
+	}
+}
+
+// SetFilter changes the changes s receives from now on. Changes already
+// waiting on s.C are not filtered again.
+func (h *Hub) SetFilter(s *Subscription, filter eventFilter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s.filter = filter
+}
+
+// Publish sends the change to every matching subscriber. Its signature fits
+// models.EntryModel.OnChange.
+func (h *Hub) Publish(e models.Entry, c models.Change) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subscribers {
+		if !s.filter.matches(e) {
+			continue
+		}
+
+		select {
+		case s.c <- models.EntryChange{Entry: e, Change: c}:
+		default:
+			delete(h.subscribers, s)
+			close(s.c)
+		}
+	}
+}
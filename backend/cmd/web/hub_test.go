@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/earthquake-service/internal/geo"
+	"github.com/earthquake-service/internal/models"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+
+	minMag := 3.0
+	box, err := geo.NewBox(-80, 40, -70, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := hub.Subscribe(eventFilter{}, 10)
+	filtered := hub.Subscribe(eventFilter{box: &box, minMagnitude: &minMag}, 10)
+	slow := hub.Subscribe(eventFilter{}, 1)
+
+	hub.Publish(models.Entry{GUID: "small", Latitude: 45, Longitude: -75, Magnitude: 2}, models.ChangeCreated)
+	hub.Publish(models.Entry{GUID: "far", Latitude: -45, Longitude: 170, Magnitude: 5}, models.ChangeCreated)
+	hub.Publish(models.Entry{GUID: "match", Latitude: 45, Longitude: -75, Magnitude: 4}, models.ChangeUpdated)
+
+	if len(all.C) != 3 {
+		t.Errorf("got %d changes for the unfiltered subscriber, want 3", len(all.C))
+	}
+
+	if len(filtered.C) != 1 {
+		t.Fatalf("got %d changes for the filtered subscriber, want 1", len(filtered.C))
+	}
+	if c := <-filtered.C; c.Entry.GUID != "match" || c.Change != models.ChangeUpdated {
+		t.Errorf("got %s %s, want match updated", c.Entry.GUID, c.Change)
+	}
+
+	// the slow subscriber keeps its first change and is then dropped
+	if c, ok := <-slow.C; !ok || c.Entry.GUID != "small" {
+		t.Errorf("got %s, %t, want small", c.Entry.GUID, ok)
+	}
+	if _, ok := <-slow.C; ok {
+		t.Error("got another change, want the slow subscriber dropped")
+	}
+	hub.Unsubscribe(slow)
+
+	hub.Unsubscribe(all)
+	hub.Publish(models.Entry{GUID: "late", Magnitude: 5}, models.ChangeCreated)
+	if len(hub.subscribers) != 1 {
+		t.Errorf("got %d subscribers, want 1", len(hub.subscribers))
+	}
+}
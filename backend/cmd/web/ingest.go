@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+	"github.com/earthquake-service/internal/sources"
+)
+
+// ingestSource fetches entries from source and stores each one. Every poll
+// is recorded as an ingest run counting the outcomes of its items, listing
+// those that weren't unchanged.
+func ingestSource(logger *slog.Logger, source sources.Source, entryModel *models.EntryModel, runModel *models.IngestRunModel) PollFunc {
+	return func(ctx context.Context) (count int, err error) {
+		run := models.IngestRun{
+			Source:    source.Name(),
+			StartedAt: time.Now().UTC(),
+		}
+
+		defer func() {
+			finished := time.Now().UTC()
+			run.FinishedAt = &finished
+			if err != nil {
+				run.Error = err.Error()
+			}
+
+			_, insertErr := runModel.Insert(run)
+			if insertErr != nil {
+				logger.Error("issue storing ingest run", "source", source.Name(), "error", insertErr)
+			}
+		}()
+
+		entries, skipped, err := source.Fetch(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, item := range skipped {
+			logger.Warn("skipped item",
+				"source", source.Name(),
+				"id", item.ID,
+				"title", item.Title,
+				"reason", item.Reason)
+
+			run.Record(models.IngestRunItem{
+				GUID:    item.ID,
+				Title:   item.Title,
+				Outcome: models.OutcomeSkipped,
+				Reason:  item.Reason.Error(),
+			})
+		}
+
+		for _, entry := range entries {
+			change, err := entryModel.Insert(entry)
+			if err != nil {
+				logger.Error("issue storing item", "source", source.Name(), "error", err)
+				run.Record(models.IngestRunItem{
+					GUID:    entry.GUID,
+					Title:   entry.Title,
+					Outcome: models.OutcomeFailed,
+					Reason:  err.Error(),
+				})
+				return count, err
+			}
+
+			outcome := models.OutcomeUnchanged
+			switch change {
+			case models.ChangeCreated:
+				outcome = models.OutcomeInserted
+			case models.ChangeUpdated:
+				outcome = models.OutcomeUpdated
+			}
+			run.Record(models.IngestRunItem{GUID: entry.GUID, Title: entry.Title, Outcome: outcome})
+
+			count = count + 1
+		}
+
+		return count, nil
+	}
+}
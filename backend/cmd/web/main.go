@@ -9,6 +9,9 @@ import (
 	"os/signal"
 	"sync"
 	"time"
+
+	"github.com/earthquake-service/internal/models"
+	"github.com/earthquake-service/internal/sources"
 )
 
 func Run(ctx context.Context) error {
@@ -21,11 +24,50 @@ func Run(ctx context.Context) error {
 	defer db.Close()
 	Migrate(db.Connection, config.Schema)
 
+	// changes stored by the pollers or imported are pushed to stream and
+	// WebSocket subscribers, delivered to webhooks, checked against the
+	// alert rules and regrouped into sequences
+	entries := &models.EntryModel{DB: db.Connection}
+	hub := NewHub()
+	progress := &models.ProgressModel{DB: db.Connection}
+	dispatcher, err := NewDispatcher(logger, config, &models.WebhookModel{DB: db.Connection}, entries, progress)
+	if err != nil {
+		return err
+	}
+	alerter, err := NewAlerter(logger, &models.AlertModel{DB: db.Connection}, entries, progress)
+	if err != nil {
+		return err
+	}
+	declusterer := NewDeclusterer(logger, config, entries)
+	entries.OnChange = func(e models.Entry, c models.Change) {
+		hub.Publish(e, c)
+		dispatcher.Notify(e, c)
+		alerter.Notify(e, c)
+		declusterer.Notify(e, c)
+	}
+	runs := &models.IngestRunModel{DB: db.Connection}
+
+	// events stored before sequences existed, or by an older version, are
+	// grouped before serving
+	decluster(logger, entries)
+
+	var pollers []*Poller
+	for _, sc := range config.Sources {
+		source, err := sources.New(sc.Kind, sc.Name, sc.URL)
+		if err != nil {
+			return err
+		}
+		pollers = append(pollers, NewPoller(logger, config, sc.Name, sc.Interval, ingestSource(logger, source, entries, runs)))
+	}
+
 	srv := NewServer(
 		ctx,
 		logger,
 		config,
 		db,
+		pollers,
+		hub,
+		entries,
 	)
 
 	httpServer := &http.Server{
@@ -41,6 +83,43 @@ func Run(ctx context.Context) error {
 	}()
 
 	var wg sync.WaitGroup
+	for _, poller := range pollers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			poller.Run(ctx)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		dispatcher.Run(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		alerter.Run(ctx)
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		declusterer.Run(ctx)
+	}()
+
+	if config.SMTPAddr != "" {
+		digester := NewDigester(logger, config, &models.DigestModel{DB: db.Connection}, entries)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			digester.Run(ctx)
+		}()
+	} else {
+		logger.Info("no smtp-addr, digests won't be sent")
+	}
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
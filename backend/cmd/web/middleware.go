@@ -1,6 +1,13 @@
 package main
 
-import "net/http"
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
 
 func cors(next http.Handler) http.Handler {
 	return http.HandlerFunc(
@@ -11,3 +18,56 @@ func cors(next http.Handler) http.Handler {
 		},
 	)
 }
+
+// recoverPanics turns a panic in a handler into a 500 problem response
+// instead of a dropped connection.
+func recoverPanics(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				// the server aborts the response quietly for this one
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+
+				logger.Error("handler panicked",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"panic", fmt.Sprint(v),
+					"stack", string(debug.Stack()))
+
+				w.Header().Set("Connection", "close")
+				writeProblem(w, r, logger, http.StatusInternalServerError, "the server encountered a problem")
+			}()
+
+			next.ServeHTTP(w, r)
+		},
+	)
+}
+
+// requireToken serves only requests bearing the API token in their
+// Authorization header. Without a token configured it refuses every
+// request, so the endpoints it guards are off by default.
+func requireToken(logger *slog.Logger, token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				writeProblem(w, r, logger, http.StatusForbidden, "this endpoint is disabled, the server has no API token set")
+				return
+			}
+
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeProblem(w, r, logger, http.StatusUnauthorized, "a valid API token is required as Authorization: Bearer <token>")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		},
+	)
+}
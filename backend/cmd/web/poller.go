@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+var ErrPollerStopped = errors.New("poller is not running")
+
+// PollFunc performs a single fetch of a feed and returns the number of items
+// stored.
+type PollFunc func(ctx context.Context) (int, error)
+
+// Poller runs a PollFunc on an interval until its context is cancelled.
+// Manual triggers go through the same loop so polls never overlap.
+type Poller struct {
+	Name       string
+	State      *State
+	logger     *slog.Logger
+	poll       PollFunc
+	interval   time.Duration
+	jitter     time.Duration
+	backoff    time.Duration
+	maxBackoff time.Duration
+	trigger    chan chan error
+	done       chan struct{}
+}
+
+func NewPoller(logger *slog.Logger, config *Config, name string, interval time.Duration, poll PollFunc) *Poller {
+	return &Poller{
+		Name:       name,
+		State:      &State{},
+		logger:     logger,
+		poll:       poll,
+		interval:   interval,
+		jitter:     config.PollJitter,
+		backoff:    config.PollBackoff,
+		maxBackoff: config.PollMaxBackoff,
+		trigger:    make(chan chan error),
+		done:       make(chan struct{}),
+	}
+}
+
+// Run polls immediately, then again after every interval. Consecutive
+// failures back off exponentially up to maxBackoff. Run returns once ctx is
+// cancelled.
+func (p *Poller) Run(ctx context.Context) {
+	defer close(p.done)
+
+	var failures int
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		var reply chan error
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case reply = <-p.trigger:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+
+		err := p.runOnce(ctx)
+		if reply != nil {
+			reply <- err
+		}
+
+		if err != nil {
+			failures++
+		} else {
+			failures = 0
+		}
+
+		delay := p.nextDelay(failures)
+		p.logger.Debug("next poll scheduled", "source", p.Name, "in", delay, "failures", failures)
+		timer.Reset(delay)
+	}
+}
+
+// PollNow asks the running loop to poll immediately and waits for the result.
+func (p *Poller) PollNow(ctx context.Context) error {
+	reply := make(chan error, 1)
+
+	select {
+	case p.trigger <- reply:
+	case <-p.done:
+		return ErrPollerStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Poller) runOnce(ctx context.Context) error {
+	start := time.Now()
+
+	count, err := p.poll(ctx)
+	if err != nil {
+		p.logger.Error("poll failed", "source", p.Name, "error", err)
+		p.State.updateFailure()
+		return err
+	}
+
+	p.State.updateSuccess()
+	p.logger.Info("poll complete",
+		"source", p.Name,
+		"time_ms", time.Since(start),
+		"count", count)
+
+	return nil
+}
+
+// nextDelay returns the wait before the next poll. After a failure the wait
+// doubles from backoff up to maxBackoff, otherwise it is the regular interval.
+// Both get up to jitter added so multiple instances drift apart.
+func (p *Poller) nextDelay(failures int) time.Duration {
+	delay := p.interval
+
+	if failures > 0 {
+		delay = backoffDelay(p.backoff, p.maxBackoff, failures)
+	}
+
+	if p.jitter > 0 {
+		delay += rand.N(p.jitter)
+	}
+
+	return delay
+}
+
+// backoffDelay returns the wait after consecutive failures, which doubles
+// from backoff with each one up to maxBackoff.
+func backoffDelay(backoff, maxBackoff time.Duration, failures int) time.Duration {
+	delay := backoff
+	for i := 1; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func newTestPoller(poll PollFunc) *Poller {
+	config := &Config{
+		PollBackoff:    time.Second,
+		PollMaxBackoff: 10 * time.Second,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	return NewPoller(logger, config, "test", time.Hour, poll)
+}
+
+func TestPollerNextDelay(t *testing.T) {
+	p := newTestPoller(nil)
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, time.Hour},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		got := p.nextDelay(tt.failures)
+		if got != tt.want {
+			t.Errorf("nextDelay(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestPollerPollNow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := make(chan struct{}, 10)
+	failNext := false
+	p := newTestPoller(func(ctx context.Context) (int, error) {
+		calls <- struct{}{}
+		if failNext {
+			return 0, errors.New("boom")
+		}
+		return 1, nil
+	})
+
+	go p.Run(ctx)
+
+	// initial poll happens straight away
+	<-calls
+
+	if err := p.PollNow(ctx); err != nil {
+		t.Fatalf("PollNow: unexpected error %s", err)
+	}
+	<-calls
+
+	failNext = true
+	if err := p.PollNow(ctx); err == nil {
+		t.Fatal("PollNow: expected error")
+	}
+	<-calls
+
+	cancel()
+	<-p.done
+
+	if err := p.PollNow(context.Background()); err != ErrPollerStopped {
+		t.Fatalf("PollNow after shutdown = %v, want %v", err, ErrPollerStopped)
+	}
+}
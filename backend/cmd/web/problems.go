@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// problem is an RFC 7807 problem details body. Every error response uses it,
+// with the status text as the title since the type is always about:blank.
+type problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	InvalidParams []invalidParam `json:"invalid-params,omitempty"`
+}
+
+// invalidParam is a rejected request parameter and why it was rejected.
+type invalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// validationError collects every rejected parameter of a request, so one
+// response can report all of them.
+type validationError struct {
+	params []invalidParam
+}
+
+func (e *validationError) Error() string {
+	reasons := make([]string, len(e.params))
+	for i, p := range e.params {
+		reasons[i] = p.Name + ": " + p.Reason
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// add rejects the named parameter.
+func (e *validationError) add(name, reason string) {
+	e.params = append(e.params, invalidParam{Name: name, Reason: reason})
+}
+
+// err returns e when any parameter was rejected, otherwise nil.
+func (e *validationError) err() error {
+	if len(e.params) == 0 {
+		return nil
+	}
+	return e
+}
+
+// writeProblem responds with a problem for status and detail.
+func writeProblem(w http.ResponseWriter, r *http.Request, logger *slog.Logger, status int, detail string) {
+	writeProblemBody(w, logger, problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	})
+}
+
+// writeInvalid responds with a 400 problem listing the parameters rejected by
+// err.
+func writeInvalid(w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error) {
+	logger.Info("invalid request", "path", r.URL.Path, "error", err)
+
+	p := problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(http.StatusBadRequest),
+		Status:   http.StatusBadRequest,
+		Detail:   "the request has invalid parameters",
+		Instance: r.URL.Path,
+	}
+
+	var invalid *validationError
+	if errors.As(err, &invalid) {
+		p.InvalidParams = invalid.params
+	} else {
+		p.Detail = err.Error()
+	}
+
+	writeProblemBody(w, logger, p)
+}
+
+// writeServerError logs err and responds with a 500 problem that doesn't
+// reveal it.
+func writeServerError(w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error) {
+	logger.Error("server error", "method", r.Method, "path", r.URL.Path, "error", err)
+	writeProblem(w, r, logger, http.StatusInternalServerError, "the server encountered a problem")
+}
+
+func writeProblemBody(w http.ResponseWriter, logger *slog.Logger, p problem) {
+	js, _ := json.Marshal(p)
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	_, err := w.Write(js)
+	if err != nil {
+		logger.Error("writing response", "error", err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+func TestHandleGetEntriesInvalid(t *testing.T) {
+	logger := discardLogger()
+	handler := handleGetEntries(logger, &Config{MaxPageSize: 100}, &models.EntryModel{})
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"no coords", "", []string{"coords"}},
+		{"too few coords", "coords=1,2", []string{"coords"}},
+		{"coords not numbers", "coords=a,2,3,4", []string{"coords"}},
+		{"coords south west north of north east", "coords=-10,60,10,50", []string{"coords"}},
+		{"coords and radius", "coords=-10,50,10,60&lat=1&lng=2&radiuskm=3", []string{"coords"}},
+		{"partial radius", "lat=1", []string{"lng", "radiuskm"}},
+		{"every filter", "coords=-10,50,10,60&minmag=x&maxdepth=2000&start=yesterday&limit=0&sort=size",
+			[]string{"sort", "start", "minmag", "maxdepth", "limit"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("got status %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/problem+json" {
+				t.Errorf("got content type %q", got)
+			}
+
+			var p problem
+			if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, param := range p.InvalidParams {
+				if param.Reason == "" {
+					t.Errorf("%s has no reason", param.Name)
+				}
+				got = append(got, param.Name)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got invalid params %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got invalid params %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	logger := discardLogger()
+	handler := recoverPanics(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var coords []string
+		_ = coords[3]
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+
+	var p problem
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != http.StatusInternalServerError || p.Title != "Internal Server Error" || p.Instance != "/api/v1/" {
+		t.Errorf("got problem %+v", p)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/earthquake-service/internal/geo"
+	"github.com/earthquake-service/internal/models"
+)
+
+// regionJSON is a models.Region in a request or response body. Its fields
+// are named after the query parameters selecting the same areas: coords for
+// a box, or lat, lng and radiuskm for a circle. None of them is the whole
+// world.
+type regionJSON struct {
+	Coords   string   `json:"coords,omitempty"`
+	Lat      *float64 `json:"lat,omitempty"`
+	Lng      *float64 `json:"lng,omitempty"`
+	RadiusKm *float64 `json:"radiuskm,omitempty"`
+}
+
+func newRegionJSON(r models.Region) regionJSON {
+	var j regionJSON
+
+	if r.Box != nil {
+		corners := []float64{r.Box.SWLng, r.Box.SWLat, r.Box.NELng, r.Box.NELat}
+		values := make([]string, len(corners))
+		for i, c := range corners {
+			values[i] = strconv.FormatFloat(c, 'f', -1, 64)
+		}
+		j.Coords = strings.Join(values, ",")
+	}
+
+	if r.Circle != nil {
+		j.Lat, j.Lng, j.RadiusKm = &r.Circle.Latitude, &r.Circle.Longitude, &r.Circle.RadiusKm
+	}
+
+	return j
+}
+
+// region validates j, adding the fields it rejects to invalid.
+func (j regionJSON) region(invalid *validationError) models.Region {
+	var r models.Region
+
+	hasCircle := j.Lat != nil || j.Lng != nil || j.RadiusKm != nil
+	if j.Coords != "" && hasCircle {
+		invalid.add("coords", "use either coords or lat, lng and radiuskm")
+		return r
+	}
+
+	if j.Coords != "" {
+		swlng, swlat, nelng, nelat, err := parseCoords(j.Coords)
+		if err == nil {
+			_, err = geo.NewBox(swlng, swlat, nelng, nelat)
+		}
+		if err != nil {
+			invalid.add("coords", err.Error())
+		}
+		r.Box = &models.Corners{SWLng: swlng, SWLat: swlat, NELng: nelng, NELat: nelat}
+	}
+
+	if hasCircle {
+		// half the circumference reaches every point on the globe
+		lat := requireRange(invalid, "lat", j.Lat, -90, 90)
+		lng := requireRange(invalid, "lng", j.Lng, -180, 180)
+		radiusKm := requireRange(invalid, "radiuskm", j.RadiusKm, 0, math.Pi*geo.EarthRadiusKm)
+		if j.RadiusKm != nil && radiusKm == 0 {
+			invalid.add("radiuskm", "must be greater than 0")
+		}
+		r.Circle = &models.Circle{Latitude: lat, Longitude: lng, RadiusKm: radiusKm}
+	}
+
+	return r
+}
+
+// requireRange checks that the named field is given and between lo and hi,
+// and returns its value.
+func requireRange(invalid *validationError, name string, v *float64, lo, hi float64) float64 {
+	if v == nil {
+		invalid.add(name, "is required")
+		return 0
+	}
+	checkRange(invalid, name, v, lo, hi)
+	return *v
+}
+
+// checkRange checks that the named field, when given, is between lo and hi.
+func checkRange(invalid *validationError, name string, v *float64, lo, hi float64) {
+	if v == nil {
+		return
+	}
+	if *v < lo || *v > hi {
+		invalid.add(name, fmt.Sprintf("must be between %g and %g, got %g", lo, hi, *v))
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 
@@ -8,13 +9,60 @@ import (
 )
 
 func addRoutes(
+	ctx context.Context,
 	mux *http.ServeMux,
 	logger *slog.Logger,
 	config *Config,
-	appState *State,
+	pollers []*Poller,
+	hub *Hub,
 	entries *models.EntryModel,
+	runs *models.IngestRunModel,
+	webhooks *models.WebhookModel,
+	alerts *models.AlertModel,
+	digests *models.DigestModel,
 ) {
-	mux.Handle("GET /api/v1/update", handleUpdateEntries(logger, config, appState, entries))
-	mux.Handle("GET /api/v1/", handleGetEntries(logger, entries))
-	mux.Handle("GET /", handleRoot())
+	// subscriptions send data out of the server, imports change the events
+	// and alert rules are evaluated on every change, only the holders of the
+	// API token make them
+	authorized := func(next http.Handler) http.Handler {
+		return requireToken(logger, config.APIToken, next)
+	}
+
+	mux.Handle("GET /api/v1/update", handleUpdateEntries(logger, config, pollers))
+	mux.Handle("GET /api/v1/ingest/runs", handleListIngestRuns(logger, runs))
+	mux.Handle("GET /api/v1/ingest/runs/{id}", handleGetIngestRun(logger, runs))
+	mux.Handle("GET /api/v1/events/clusters", handleGetClusters(logger, entries))
+	mux.Handle("GET /api/v1/tiles/{z}/{x}/{y}", handleGetTile(logger, entries))
+	mux.Handle("GET /api/v1/stats", handleGetStats(logger, entries))
+	mux.Handle("GET /api/v1/stats/gutenberg-richter", handleGetGutenbergRichter(logger, entries))
+	mux.Handle("GET /api/v1/stream", handleStream(ctx, logger, config, hub, entries))
+	mux.Handle("GET /api/v1/ws", handleWebSocket(ctx, logger, config, hub))
+	mux.Handle("POST /api/v1/webhooks", authorized(handleCreateWebhook(logger, webhooks)))
+	mux.Handle("GET /api/v1/webhooks", authorized(handleListWebhooks(logger, webhooks)))
+	mux.Handle("GET /api/v1/webhooks/{id}", authorized(handleGetWebhook(logger, webhooks)))
+	mux.Handle("PUT /api/v1/webhooks/{id}", authorized(handleUpdateWebhook(logger, webhooks)))
+	mux.Handle("DELETE /api/v1/webhooks/{id}", authorized(handleDeleteWebhook(logger, webhooks)))
+	mux.Handle("GET /api/v1/webhooks/{id}/deliveries", authorized(handleListWebhookDeliveries(logger, webhooks)))
+	mux.Handle("POST /api/v1/alerts/rules", authorized(handleCreateAlertRule(logger, alerts)))
+	mux.Handle("GET /api/v1/alerts/rules", handleListAlertRules(logger, alerts))
+	mux.Handle("GET /api/v1/alerts/rules/{id}", handleGetAlertRule(logger, alerts))
+	mux.Handle("DELETE /api/v1/alerts/rules/{id}", authorized(handleDeleteAlertRule(logger, alerts)))
+	mux.Handle("GET /api/v1/alerts", handleListAlerts(logger, alerts))
+	mux.Handle("GET /api/v1/alerts/{id}", handleGetAlert(logger, alerts))
+	mux.Handle("POST /api/v1/digests", authorized(handleCreateDigest(logger, digests)))
+	mux.Handle("GET /api/v1/digests", authorized(handleListDigests(logger, digests)))
+	mux.Handle("GET /api/v1/digests/{id}", authorized(handleGetDigest(logger, digests)))
+	mux.Handle("DELETE /api/v1/digests/{id}", authorized(handleDeleteDigest(logger, digests)))
+	mux.Handle("GET /api/v1/digests/unsubscribe/{token}", handleConfirmUnsubscribeDigest(logger, digests))
+	mux.Handle("POST /api/v1/digests/unsubscribe/{token}", handleUnsubscribeDigest(logger, digests))
+	mux.Handle("GET /api/v1/sequences", handleListSequences(logger, config, entries))
+	mux.Handle("GET /api/v1/sequences/{id}", handleGetSequence(logger, entries))
+	mux.Handle("GET /api/v1/events/{guid}", handleGetEntry(logger, entries))
+	mux.Handle("GET /api/v1/events/{guid}/history", handleGetEntryHistory(logger, entries))
+	mux.Handle("GET /api/v1/events.quakeml", handleExportQuakeML(logger, entries))
+	mux.Handle("POST /api/v1/events.quakeml", authorized(handleImportQuakeML(logger, entries)))
+	mux.Handle("GET /api/v1/events.csv", handleExportEntries(logger, entries, formatCSV))
+	mux.Handle("GET /api/v1/events.ndjson", handleExportEntries(logger, entries, formatNDJSON))
+	mux.Handle("GET /api/v1/", handleGetEntries(logger, config, entries))
+	mux.Handle("GET /", handleRoot(logger))
 }
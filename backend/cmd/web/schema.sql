@@ -8,11 +8,17 @@ CREATE TABLE IF NOT EXISTS entries
     updated timestamp,
     published timestamp,
     categories string,
+    place text,
     elevation integer,
     latitude real,
     longitude real,
     magnitude real,
-    time timestamp
+    magnitude_type text,
+    magnitude_uncertainty real,
+    time timestamp,
+    sequence_id integer,
+    mainshock integer,
+    change_id integer
 );
 
 CREATE UNIQUE INDEX IF NOT EXISTS idx_entries_guid
@@ -23,3 +29,196 @@ ON entries (latitude, longitude);
 
 CREATE INDEX IF NOT EXISTS idx_time
 ON entries (time);
+
+CREATE INDEX IF NOT EXISTS idx_entries_sequence_id
+ON entries (sequence_id);
+
+CREATE INDEX IF NOT EXISTS idx_entries_change_id
+ON entries (change_id);
+
+CREATE TABLE IF NOT EXISTS entry_revisions
+(
+    id integer
+        constraint entry_revisions_pk primary key,
+    guid text not null,
+    revised_at timestamp not null,
+    updated timestamp,
+    old_time timestamp,
+    old_latitude real,
+    old_longitude real,
+    old_elevation integer,
+    old_magnitude real,
+    old_magnitude_type text,
+    old_magnitude_uncertainty real,
+    old_place text,
+    new_time timestamp,
+    new_latitude real,
+    new_longitude real,
+    new_elevation integer,
+    new_magnitude real,
+    new_magnitude_type text,
+    new_magnitude_uncertainty real,
+    new_place text
+);
+
+CREATE INDEX IF NOT EXISTS idx_entry_revisions_guid
+ON entry_revisions (guid);
+
+CREATE TABLE IF NOT EXISTS ingest_runs
+(
+    id integer
+        constraint ingest_runs_pk primary key,
+    source text not null,
+    started_at timestamp not null,
+    finished_at timestamp,
+    seen integer not null default 0,
+    inserted integer not null default 0,
+    updated integer not null default 0,
+    skipped integer not null default 0,
+    error text
+);
+
+CREATE INDEX IF NOT EXISTS idx_ingest_runs_started_at
+ON ingest_runs (started_at);
+
+CREATE TABLE IF NOT EXISTS ingest_run_items
+(
+    id integer
+        constraint ingest_run_items_pk primary key,
+    run_id integer not null
+        references ingest_runs (id) on delete cascade,
+    guid text,
+    title text,
+    outcome text not null,
+    reason text
+);
+
+CREATE INDEX IF NOT EXISTS idx_ingest_run_items_run_id
+ON ingest_run_items (run_id);
+
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id integer
+        constraint webhooks_pk primary key,
+    url text not null,
+    swlng real,
+    swlat real,
+    nelng real,
+    nelat real,
+    latitude real,
+    longitude real,
+    radius_km real,
+    min_magnitude real,
+    secret text not null,
+    created_at timestamp not null,
+    updated_at timestamp not null
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id integer
+        constraint webhook_deliveries_pk primary key,
+    webhook_id integer not null
+        references webhooks (id) on delete cascade,
+    guid text not null,
+    change_id integer,
+    change text not null,
+    attempt integer not null,
+    attempted_at timestamp not null,
+    status_code integer,
+    error text
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id
+ON webhook_deliveries (webhook_id);
+
+CREATE TABLE IF NOT EXISTS webhook_pending
+(
+    id integer
+        constraint webhook_pending_pk primary key,
+    webhook_id integer not null
+        references webhooks (id) on delete cascade,
+    guid text not null,
+    change_id integer not null,
+    change text not null,
+    body blob not null,
+    attempt integer not null,
+    due_at timestamp not null
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_pending_webhook_id_change_id
+ON webhook_pending (webhook_id, change_id);
+
+CREATE TABLE IF NOT EXISTS alert_rules
+(
+    id integer
+        constraint alert_rules_pk primary key,
+    name text not null,
+    kind text not null,
+    swlng real,
+    swlat real,
+    nelng real,
+    nelat real,
+    latitude real,
+    longitude real,
+    radius_km real,
+    min_magnitude real,
+    min_count integer,
+    window_seconds integer,
+    cell_km real,
+    created_at timestamp not null
+);
+
+CREATE TABLE IF NOT EXISTS alerts
+(
+    id integer
+        constraint alerts_pk primary key,
+    rule_id integer not null
+        references alert_rules (id) on delete cascade,
+    guid text not null,
+    magnitude real,
+    event_time timestamp,
+    cell text,
+    count integer,
+    fired_at timestamp not null,
+    cleared_at timestamp
+);
+
+CREATE INDEX IF NOT EXISTS idx_alerts_rule_id_guid
+ON alerts (rule_id, guid);
+
+CREATE INDEX IF NOT EXISTS idx_alerts_rule_id_cell
+ON alerts (rule_id, cell);
+
+CREATE TABLE IF NOT EXISTS digests
+(
+    id integer
+        constraint digests_pk primary key,
+    email text not null,
+    frequency text not null,
+    swlng real,
+    swlat real,
+    nelng real,
+    nelat real,
+    latitude real,
+    longitude real,
+    radius_km real,
+    min_magnitude real,
+    created_at timestamp not null,
+    sent_until timestamp not null,
+    unsubscribe_token text
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_digests_unsubscribe_token
+ON digests (unsubscribe_token);
+
+CREATE TABLE IF NOT EXISTS change_progress
+(
+    id integer
+        constraint change_progress_pk primary key,
+    consumer text not null,
+    change_id integer not null
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_change_progress_consumer
+ON change_progress (consumer);
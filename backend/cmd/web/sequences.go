@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+// sequence is a mainshock with its foreshocks and aftershocks. ID is the
+// sequence_id shared by its events.
+type sequence struct {
+	ID        int64      `json:"id"`
+	Count     int        `json:"count"`
+	First     *time.Time `json:"first"`
+	Last      *time.Time `json:"last"`
+	Mainshock exportRow  `json:"mainshock"`
+}
+
+func newSequence(s models.Sequence) sequence {
+	return sequence{
+		ID:        s.Mainshock.ID,
+		Count:     s.Count,
+		First:     s.First,
+		Last:      s.Last,
+		Mainshock: newExportRow(s.Mainshock, false),
+	}
+}
+
+func handleListSequences(logger *slog.Logger, config *Config, entries *models.EntryModel) http.Handler {
+	type Response struct {
+		Message string     `json:"message"`
+		Data    []sequence `json:"data"`
+		Count   int        `json:"count"`
+		Limit   int        `json:"limit"`
+		Offset  int        `json:"offset"`
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			invalid := &validationError{}
+			q := parseAggregateQuery(r, invalid)
+
+			minEvents, err := parseIntParam(r.URL.Query().Get("minevents"), 1)
+			if err != nil {
+				invalid.add("minevents", err.Error())
+			}
+			if minEvents == 0 {
+				// sequences of one are events with no foreshocks or aftershocks
+				minEvents = 2
+			}
+
+			if err := invalid.err(); err != nil {
+				writeInvalid(w, r, logger, err)
+				return
+			}
+
+			limit, _ := parseIntParam(r.URL.Query().Get("limit"), 1)
+			if limit == 0 || limit > config.MaxPageSize {
+				limit = config.MaxPageSize
+			}
+			offset, _ := parseIntParam(r.URL.Query().Get("offset"), 0)
+			q.Limit(limit).Offset(offset)
+
+			results, err := entries.Sequences(q, minEvents)
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			resp := Response{
+				Message: "Sequences",
+				Data:    []sequence{},
+				Limit:   limit,
+				Offset:  offset,
+			}
+			for _, s := range results {
+				resp.Data = append(resp.Data, newSequence(s))
+			}
+			resp.Count = len(resp.Data)
+
+			js, _ := json.Marshal(resp)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err = w.Write(js)
+			if err != nil {
+				logger.Error("writing response", "error", err)
+				return
+			}
+
+			logger.Info("ListSequences",
+				"time_ms", time.Since(start),
+				"coords", r.URL.Query().Get("coords"),
+				"minevents", minEvents,
+				"count", resp.Count)
+		},
+	)
+}
+
+func handleGetSequence(logger *slog.Logger, entries *models.EntryModel) http.Handler {
+	type Sequence struct {
+		sequence
+		Events []exportRow `json:"events"`
+	}
+
+	type Response struct {
+		Message string   `json:"message"`
+		Data    Sequence `json:"data"`
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+			if err != nil {
+				writeProblem(w, r, logger, http.StatusNotFound, fmt.Sprintf("no sequence has the id %q", r.PathValue("id")))
+				return
+			}
+
+			s, events, err := entries.GetSequence(id)
+			if err != nil {
+				if errors.Is(err, models.ErrNoRecord) {
+					writeProblem(w, r, logger, http.StatusNotFound, fmt.Sprintf("no sequence has the id %d", id))
+					return
+				}
+
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			resp := Response{
+				Message: "Sequence",
+				Data: Sequence{
+					sequence: newSequence(s),
+					Events:   []exportRow{},
+				},
+			}
+			for _, e := range events {
+				resp.Data.Events = append(resp.Data.Events, newExportRow(e, false))
+			}
+
+			js, _ := json.Marshal(resp)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err = w.Write(js)
+			if err != nil {
+				logger.Error("writing response", "error", err)
+				return
+			}
+		},
+	)
+}
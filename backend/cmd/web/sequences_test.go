@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+func TestHandleSequences(t *testing.T) {
+	entries := &models.EntryModel{DB: newTestDB(t)}
+	// a day apart, so the M5 keeps the M3s and the M4 far south is alone
+	insertTestEntries(t, entries,
+		models.Entry{GUID: "main", Latitude: 40, Longitude: 140, Magnitude: 5},
+		models.Entry{GUID: "after1", Latitude: 40.1, Longitude: 140, Magnitude: 3},
+		models.Entry{GUID: "after2", Latitude: 40, Longitude: 140.1, Magnitude: 3},
+		models.Entry{GUID: "alone", Latitude: -20, Longitude: -70, Magnitude: 4},
+	)
+	if _, err := entries.Decluster(); err != nil {
+		t.Fatal(err)
+	}
+
+	list := handleListSequences(discardLogger(), &Config{MaxPageSize: 100}, entries)
+
+	rec := httptest.NewRecorder()
+	list.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/sequences?coords=-180,-90,180,90", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp struct {
+		Count int
+		Data  []struct {
+			ID        int64
+			Count     int
+			Mainshock struct {
+				ID string
+			}
+		}
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Count != 1 || resp.Data[0].Mainshock.ID != "main" || resp.Data[0].Count != 3 {
+		t.Fatalf("got %+v, want the sequence of main", resp)
+	}
+	id := resp.Data[0].ID
+
+	rec = httptest.NewRecorder()
+	list.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/sequences?coords=-180,-90,180,90&minevents=1&maxmag=4.5", nil))
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Count != 1 || resp.Data[0].Mainshock.ID != "alone" {
+		t.Errorf("got %+v, want the sequence of alone", resp)
+	}
+
+	for _, query := range []string{"", "coords=-180,-90,180,90&minevents=0"} {
+		rec := httptest.NewRecorder()
+		list.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/sequences?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: got status %d, want 400", query, rec.Code)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/v1/sequences/{id}", handleGetSequence(discardLogger(), entries))
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/sequences/%d", id), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	var detail struct {
+		Data struct {
+			ID     int64
+			Count  int
+			Events []struct {
+				ID string
+			}
+		}
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&detail); err != nil {
+		t.Fatal(err)
+	}
+	if detail.Data.ID != id || len(detail.Data.Events) != 3 || detail.Data.Events[0].ID != "main" {
+		t.Errorf("got %+v", detail.Data)
+	}
+
+	for _, path := range []string{"/api/v1/sequences/x", "/api/v1/sequences/999"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: got status %d, want 404", path, rec.Code)
+		}
+	}
+}
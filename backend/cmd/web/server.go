@@ -31,27 +31,44 @@ func (s *State) updateFailure() {
 	s.LastRun = time.Now()
 }
 
+func (s *State) lastRun() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.LastRun
+}
+
 func NewServer(
 	ctx context.Context,
 	logger *slog.Logger,
 	config *Config,
 	db *DB,
+	pollers []*Poller,
+	hub *Hub,
+	entries *models.EntryModel,
 ) http.Handler {
 	mux := http.NewServeMux()
 
-	appState := &State{}
-
-	entries := &models.EntryModel{DB: db.Connection}
+	runs := &models.IngestRunModel{DB: db.Connection}
+	webhooks := &models.WebhookModel{DB: db.Connection}
+	alerts := &models.AlertModel{DB: db.Connection, MaxRules: config.MaxAlertRules}
+	digests := &models.DigestModel{DB: db.Connection}
 
 	addRoutes(
+		ctx,
 		mux,
 		logger,
 		config,
-		appState,
+		pollers,
+		hub,
 		entries,
+		runs,
+		webhooks,
+		alerts,
+		digests,
 	)
 
 	var handler http.Handler = mux
+	handler = recoverPanics(logger, handler)
 	handler = cors(handler)
 	return handler
 }
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+// defaultBinWidth is the width of the magnitude histogram bins in magnitude
+// units when the binwidth parameter is left out.
+const defaultBinWidth = 0.5
+
+func handleGetStats(logger *slog.Logger, entries *models.EntryModel) http.Handler {
+	type Bin struct {
+		Min   float64 `json:"min"`
+		Max   float64 `json:"max"`
+		Count int     `json:"count"`
+	}
+
+	type Histogram struct {
+		BinWidth float64 `json:"bin_width"`
+		Bins     []Bin   `json:"bins"`
+	}
+
+	type Point struct {
+		Start string `json:"start"`
+		Count int    `json:"count"`
+	}
+
+	type Series struct {
+		Interval string  `json:"interval"`
+		Points   []Point `json:"points"`
+	}
+
+	type Stats struct {
+		Count        int        `json:"count"`
+		MinMagnitude *float64   `json:"min_magnitude"`
+		MaxMagnitude *float64   `json:"max_magnitude"`
+		MeanDepthKm  *float64   `json:"mean_depth_km"`
+		First        *time.Time `json:"first"`
+		Last         *time.Time `json:"last"`
+		Largest      *exportRow `json:"largest"`
+		Histogram    Histogram  `json:"histogram"`
+		Series       Series     `json:"series"`
+	}
+
+	type Response struct {
+		Message string `json:"message"`
+		Data    Stats  `json:"data"`
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			invalid := &validationError{}
+			q := parseAggregateQuery(r, invalid)
+
+			width, hasWidth, err := parseFloatParam(r.URL.Query().Get("binwidth"), 0.1, 5)
+			if err != nil {
+				invalid.add("binwidth", err.Error())
+			}
+			if !hasWidth {
+				width = defaultBinWidth
+			}
+
+			interval := r.URL.Query().Get("interval")
+			switch interval {
+			case "":
+				interval = models.IntervalDay
+			case models.IntervalDay, models.IntervalWeek:
+			default:
+				invalid.add("interval", fmt.Sprintf("must be day or week, got %q", interval))
+			}
+
+			if err := invalid.err(); err != nil {
+				writeInvalid(w, r, logger, err)
+				return
+			}
+
+			summary, err := entries.Summary(q)
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			stats := Stats{
+				Count:        summary.Count,
+				MinMagnitude: summary.MinMagnitude,
+				MaxMagnitude: summary.MaxMagnitude,
+				MeanDepthKm:  summary.MeanDepthKm,
+				First:        summary.First,
+				Last:         summary.Last,
+				Histogram:    Histogram{BinWidth: width, Bins: []Bin{}},
+				Series:       Series{Interval: interval, Points: []Point{}},
+			}
+
+			largest, err := entries.Largest(q)
+			switch {
+			case errors.Is(err, models.ErrNoRecord):
+			case err != nil:
+				writeServerError(w, r, logger, err)
+				return
+			default:
+				row := newExportRow(largest, false)
+				stats.Largest = &row
+			}
+
+			bins, err := entries.MagnitudeHistogram(q, width)
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+			for _, b := range bins {
+				stats.Histogram.Bins = append(stats.Histogram.Bins, Bin{Min: b.Min, Max: b.Max, Count: b.Count})
+			}
+
+			series, err := entries.CountSeries(q, interval)
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+			for _, p := range series {
+				stats.Series.Points = append(stats.Series.Points, Point{Start: p.Start.Format(time.DateOnly), Count: p.Count})
+			}
+
+			js, _ := json.Marshal(Response{Message: "Stats", Data: stats})
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err = w.Write(js)
+			if err != nil {
+				logger.Error("writing response", "error", err)
+				return
+			}
+
+			logger.Info("GetStats",
+				"time_ms", time.Since(start),
+				"coords", r.URL.Query().Get("coords"),
+				"interval", interval,
+				"count", stats.Count)
+		},
+	)
+}
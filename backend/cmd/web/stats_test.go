@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+func TestHandleGetStats(t *testing.T) {
+	entries := &models.EntryModel{DB: newTestDB(t)}
+	insertTestEntries(t, entries,
+		models.Entry{GUID: "a", Latitude: 49.1, Longitude: -122.7, Magnitude: 2.6, Elevation: -10000},
+		models.Entry{GUID: "b", Latitude: 48.4, Longitude: -123.4, Magnitude: 1.1, Elevation: -20000},
+		models.Entry{GUID: "c", Latitude: 45.5, Longitude: -73.6, Magnitude: 1.2, Elevation: -6000},
+		models.Entry{GUID: "d", Latitude: 53.9, Longitude: -167.1, Magnitude: 4.3, Elevation: -45000},
+	)
+	handler := handleGetStats(discardLogger(), entries)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats?coords=-141,41,-52,84&binwidth=1&end=2025-10-03", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp struct {
+		Data struct {
+			Count       int
+			MeanDepthKm float64 `json:"mean_depth_km"`
+			Largest     struct {
+				ID string
+			}
+			Histogram struct {
+				BinWidth float64 `json:"bin_width"`
+				Bins     []struct {
+					Min   float64
+					Count int
+				}
+			}
+			Series struct {
+				Interval string
+				Points   []struct {
+					Start string
+					Count int
+				}
+			}
+		}
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := resp.Data
+	if stats.Count != 3 || stats.MeanDepthKm != 12 || stats.Largest.ID != "a" {
+		t.Errorf("got %+v, want 3 events 12 km deep on average with a the largest", stats)
+	}
+	if bins := stats.Histogram.Bins; stats.Histogram.BinWidth != 1 || len(bins) != 2 || bins[0].Min != 1 || bins[0].Count != 2 || bins[1].Count != 1 {
+		t.Errorf("got histogram %+v", stats.Histogram)
+	}
+	if points := stats.Series.Points; stats.Series.Interval != "day" || len(points) != 3 || points[0].Start != "2025-10-01" {
+		t.Errorf("got series %+v", stats.Series)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats?coords=-141,41,-52,84&minmag=5", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	var empty struct {
+		Data map[string]any
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&empty); err != nil {
+		t.Fatal(err)
+	}
+	if empty.Data["count"] != 0.0 || empty.Data["largest"] != nil || empty.Data["mean_depth_km"] != nil {
+		t.Errorf("got %+v, want empty stats", empty.Data)
+	}
+
+	for _, query := range []string{
+		"",
+		"coords=-141,41,-52,84&binwidth=0",
+		"coords=-141,41,-52,84&interval=month",
+		"coords=-141,41,-52,84&lat=45&lng=-75&radiuskm=100",
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: got status %d, want 400", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+const (
+	// streamBuffer is how many changes a stream can fall behind before it is
+	// dropped.
+	streamBuffer = 64
+	// streamReplayPage is how many stored changes are read at a time when a
+	// stream resumes.
+	streamReplayPage = 500
+	// streamRetry tells clients how long to wait before reconnecting.
+	streamRetry = 5 * time.Second
+)
+
+// handleStream pushes events as Server-Sent Events when ingestion creates or
+// updates them. The id of each message is the ChangeID of the event, which a
+// reconnecting client sends back in Last-Event-ID to receive what it missed.
+// Streams end when ctx is cancelled so the server can shut down.
+func handleStream(ctx context.Context, logger *slog.Logger, config *Config, hub *Hub, entries *models.EntryModel) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			invalid := &validationError{}
+			filter := parseEventFilter(r.URL.Query(), invalid)
+
+			var lastID int64
+			resume := r.Header.Get("Last-Event-ID")
+			if resume != "" {
+				var err error
+				lastID, err = strconv.ParseInt(resume, 10, 64)
+				if err != nil || lastID < 0 {
+					invalid.add("Last-Event-ID", fmt.Sprintf("must be the id of an earlier event, got %q", resume))
+				}
+			}
+
+			if err := invalid.err(); err != nil {
+				writeInvalid(w, r, logger, err)
+				return
+			}
+
+			// subscribe before replaying so nothing stored in between is missed
+			sub := hub.Subscribe(filter, streamBuffer)
+			defer hub.Unsubscribe(sub)
+
+			rc := http.NewResponseController(w)
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("X-Accel-Buffering", "no")
+			w.WriteHeader(http.StatusOK)
+
+			var sent int
+			send := func(c models.EntryChange) error {
+				sent = sent + 1
+				return writeStreamEvent(w, c)
+			}
+
+			_, err := fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+			if err == nil && resume != "" {
+				lastID, err = replayChanges(entries, lastID, filter, send)
+			}
+			if err == nil {
+				err = rc.Flush()
+			}
+
+			heartbeat := time.NewTicker(config.StreamHeartbeat)
+			defer heartbeat.Stop()
+
+			for err == nil {
+				select {
+				case <-ctx.Done():
+					err = ctx.Err()
+				case <-r.Context().Done():
+					err = r.Context().Err()
+				case <-heartbeat.C:
+					_, err = io.WriteString(w, ": heartbeat\n\n")
+				case c, ok := <-sub.C:
+					switch {
+					case !ok:
+						err = fmt.Errorf("stream fell more than %d events behind", streamBuffer)
+					case c.Entry.ChangeID <= lastID:
+						// already sent by the replay
+						continue
+					default:
+						err = send(c)
+					}
+				}
+				if err == nil {
+					err = rc.Flush()
+				}
+			}
+
+			logger.Info("Stream",
+				"time_ms", time.Since(start),
+				"last_event_id", resume,
+				"sent", sent,
+				"reason", err)
+		},
+	)
+}
+
+// replayChanges sends the stored changes after lastID that match filter and
+// returns the ChangeID of the last change read.
+func replayChanges(entries *models.EntryModel, lastID int64, filter eventFilter, send func(models.EntryChange) error) (int64, error) {
+	for {
+		results, err := entries.ChangesSince(lastID, streamReplayPage)
+		if err != nil {
+			return lastID, err
+		}
+
+		for _, c := range results {
+			lastID = c.Entry.ChangeID
+			if !filter.matches(c.Entry) {
+				continue
+			}
+			if err := send(c); err != nil {
+				return lastID, err
+			}
+		}
+
+		if len(results) < streamReplayPage {
+			return lastID, nil
+		}
+	}
+}
+
+// writeStreamEvent writes c as one Server-Sent Event.
+func writeStreamEvent(w io.Writer, c models.EntryChange) error {
+	data, err := json.Marshal(newExportRow(c.Entry, false))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", c.Entry.ChangeID, c.Change, data)
+	return err
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+// sseMessage is one Server-Sent Events message, or a comment.
+type sseMessage struct {
+	id, event, data, comment string
+}
+
+// readSSE reads messages from body onto a channel, closing it at the end of
+// the stream.
+func readSSE(body io.Reader) <-chan sseMessage {
+	messages := make(chan sseMessage, 100)
+
+	go func() {
+		defer close(messages)
+
+		var m sseMessage
+		scanner := bufio.NewScanner(body)
+		for scanner.Scan() {
+			field, value, _ := strings.Cut(scanner.Text(), ": ")
+			switch field {
+			case "":
+				if value != "" {
+					m.comment = value
+				}
+				if m != (sseMessage{}) {
+					messages <- m
+				}
+				m = sseMessage{}
+			case "id":
+				m.id = value
+			case "event":
+				m.event = value
+			case "data":
+				m.data = value
+			}
+		}
+	}()
+
+	return messages
+}
+
+func nextEvent(t *testing.T, messages <-chan sseMessage) sseMessage {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case m, ok := <-messages:
+			if !ok {
+				t.Fatal("stream ended")
+			}
+			if m.event != "" {
+				return m
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for an event")
+		}
+	}
+}
+
+func TestHandleStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hub := NewHub()
+	entries := &models.EntryModel{DB: newTestDB(t), OnChange: hub.Publish}
+	insertTestEntries(t, entries,
+		models.Entry{GUID: "old1", Latitude: 45, Longitude: -75, Magnitude: 2},
+		models.Entry{GUID: "old2", Latitude: 45, Longitude: -75, Magnitude: 3},
+		models.Entry{GUID: "old3", Latitude: 45, Longitude: -75, Magnitude: 4},
+	)
+
+	config := &Config{StreamHeartbeat: 50 * time.Millisecond}
+	srv := httptest.NewServer(handleStream(ctx, discardLogger(), config, hub, entries))
+	defer srv.Close()
+
+	// resuming after old1 replays the later changes of M3 or more
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"?minmag=3", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if ct := res.Header.Get("Content-Type"); res.StatusCode != http.StatusOK || ct != "text/event-stream" {
+		t.Fatalf("got status %d with %s", res.StatusCode, ct)
+	}
+	messages := readSSE(res.Body)
+
+	for _, want := range []string{"2", "3"} {
+		m := nextEvent(t, messages)
+		if m.id != want || m.event != "created" {
+			t.Errorf("got %+v, want event %s replayed", m, want)
+		}
+	}
+
+	// live changes follow, filtered
+	insertTestEntries(t, entries, models.Entry{GUID: "small", Latitude: 45, Longitude: -75, Magnitude: 1})
+	at := time.Date(2025, 10, 3, 0, 0, 0, 0, time.UTC)
+	if _, err := entries.Insert(models.Entry{GUID: "old3", Latitude: 45, Longitude: -75, Magnitude: 4.5, Time: &at}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := nextEvent(t, messages)
+	if m.id != "5" || m.event != "updated" || !strings.Contains(m.data, `"id":"old3"`) || !strings.Contains(m.data, `"magnitude":4.5`) {
+		t.Errorf("got %+v, want old3 updated", m)
+	}
+
+	heartbeat := false
+	for !heartbeat {
+		select {
+		case m := <-messages:
+			heartbeat = m.comment == "heartbeat"
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for a heartbeat")
+		}
+	}
+
+	// cancelling the server's context ends the stream
+	cancel()
+	deadline := time.After(2 * time.Second)
+	for open := true; open; {
+		select {
+		case _, open = <-messages:
+		case <-deadline:
+			t.Fatal("stream still open after cancel")
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/stream?minmag=x", nil)
+	r.Header.Set("Last-Event-ID", "x")
+	handleStream(ctx, discardLogger(), config, hub, entries).ServeHTTP(rec, r)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "Last-Event-ID") {
+		t.Errorf("got status %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+	"github.com/earthquake-service/internal/mvt"
+)
+
+const (
+	// tileBuffer is how far past its edges, in tile widths, a tile carries
+	// events so symbols drawn across the edge aren't cut off.
+	tileBuffer = 64.0 / mvt.DefaultExtent
+
+	// maxTileFeatures caps the events in one tile, the newest are kept.
+	maxTileFeatures = 10000
+
+	tileLayerName = "earthquakes"
+)
+
+// tileMinMagnitudes is the smallest magnitude drawn from each zoom up, so
+// zoomed out tiles only carry the events worth seeing from that far.
+var tileMinMagnitudes = []struct {
+	zoom      int
+	magnitude float64
+}{
+	{0, 4.5},
+	{3, 3.5},
+	{5, 2.5},
+	{7, 1.5},
+	{9, -2},
+}
+
+// tileMinMagnitude returns the smallest magnitude drawn at zoom.
+func tileMinMagnitude(zoom int) float64 {
+	magnitude := tileMinMagnitudes[0].magnitude
+	for _, t := range tileMinMagnitudes {
+		if zoom >= t.zoom {
+			magnitude = t.magnitude
+		}
+	}
+	return magnitude
+}
+
+func handleGetTile(logger *slog.Logger, entries *models.EntryModel) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			invalid := &validationError{}
+
+			z, errZ := strconv.Atoi(r.PathValue("z"))
+			x, errX := strconv.Atoi(r.PathValue("x"))
+			name, ok := strings.CutSuffix(r.PathValue("y"), ".mvt")
+			y, errY := strconv.Atoi(name)
+
+			switch {
+			case !ok:
+				invalid.add("y", "tiles are only served as .mvt")
+			case errZ != nil || errX != nil || errY != nil:
+				invalid.add("tile", fmt.Sprintf("expected integers z/x/y, got %s/%s/%s", r.PathValue("z"), r.PathValue("x"), name))
+			}
+
+			var tile mvt.TileID
+			if invalid.err() == nil {
+				var err error
+				tile, err = mvt.NewTileID(z, x, y, maxZoom)
+				if err != nil {
+					invalid.add("tile", err.Error())
+				}
+			}
+
+			swlng, swlat, nelng, nelat := tile.Bounds(tileBuffer)
+			q := models.NewEntryQuery().
+				WithinBounds(swlat, nelat, swlng, nelng).
+				MinMagnitude(tileMinMagnitude(tile.Z))
+
+			parseEntryFilters(r, q, invalid)
+
+			if err := invalid.err(); err != nil {
+				writeInvalid(w, r, logger, err)
+				return
+			}
+
+			results, err := entries.Query(q.Limit(maxTileFeatures).Offset(0))
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			layer := mvt.Layer{Name: tileLayerName, Extent: mvt.DefaultExtent}
+			for _, entry := range results {
+				x, y := tile.Project(float64(entry.Latitude), float64(entry.Longitude), layer.Extent)
+
+				properties := map[string]any{
+					"id":             entry.GUID,
+					"magnitude":      models.Widen(entry.Magnitude),
+					"magnitude_type": entry.MagnitudeType,
+					"depth_km":       float64(-entry.Elevation) / 1000,
+					"place":          entry.Place,
+				}
+				if entry.Time != nil {
+					properties["time"] = entry.Time.UnixMilli()
+				}
+
+				layer.Features = append(layer.Features, mvt.Feature{
+					ID:         uint64(entry.ID),
+					X:          x,
+					Y:          y,
+					Properties: properties,
+				})
+			}
+
+			body, err := mvt.Encode(layer)
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			sum := sha256.Sum256(body)
+			etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", "public, max-age=60")
+
+			if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/vnd.mapbox-vector-tile")
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(http.StatusOK)
+			_, err = w.Write(body)
+			if err != nil {
+				logger.Error("writing response", "error", err)
+				return
+			}
+
+			logger.Info("GetTile",
+				"time_ms", time.Since(start),
+				"tile", tile.String(),
+				"count", len(layer.Features))
+		},
+	)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
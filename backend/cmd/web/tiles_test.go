@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+func TestTileMinMagnitude(t *testing.T) {
+	for zoom, want := range map[int]float64{0: 4.5, 2: 4.5, 3: 3.5, 6: 2.5, 8: 1.5, 9: -2, 22: -2} {
+		if got := tileMinMagnitude(zoom); got != want {
+			t.Errorf("tileMinMagnitude(%d) = %g, want %g", zoom, got, want)
+		}
+	}
+}
+
+func TestHandleGetTile(t *testing.T) {
+	entries := &models.EntryModel{DB: newTestDB(t)}
+	insertTestEntries(t, entries,
+		models.Entry{GUID: "small-ottawa", Latitude: 45.42, Longitude: -75.69, Magnitude: 2.6},
+		models.Entry{GUID: "large-ottawa", Latitude: 45.5, Longitude: -75.8, Magnitude: 4.8},
+		models.Entry{GUID: "vancouver", Latitude: 49.1, Longitude: -122.7, Magnitude: 4.9},
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/v1/tiles/{z}/{x}/{y}", handleGetTile(discardLogger(), entries))
+
+	get := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name    string
+		target  string
+		want    []string
+		notWant []string
+	}{
+		{"zoomed out keeps large events", "/api/v1/tiles/0/0/0.mvt", []string{"large-ottawa", "vancouver"}, []string{"small-ottawa"}},
+		{"zoomed in keeps every event", "/api/v1/tiles/10/296/366.mvt", []string{"small-ottawa", "large-ottawa"}, []string{"vancouver"}},
+		{"filters apply", "/api/v1/tiles/0/0/0.mvt?minmag=4.85", []string{"vancouver"}, []string{"large-ottawa"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(tt.target, nil)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/vnd.mapbox-vector-tile" {
+				t.Errorf("got Content-Type %q", got)
+			}
+
+			for _, guid := range tt.want {
+				if !bytes.Contains(rec.Body.Bytes(), []byte(guid)) {
+					t.Errorf("tile is missing %s", guid)
+				}
+			}
+			for _, guid := range tt.notWant {
+				if bytes.Contains(rec.Body.Bytes(), []byte(guid)) {
+					t.Errorf("tile has %s", guid)
+				}
+			}
+		})
+	}
+
+	t.Run("etag", func(t *testing.T) {
+		first := get("/api/v1/tiles/0/0/0.mvt", nil)
+		etag := first.Header().Get("ETag")
+		if etag == "" || first.Header().Get("Cache-Control") == "" {
+			t.Fatalf("got headers %v", first.Header())
+		}
+
+		again := get("/api/v1/tiles/0/0/0.mvt", http.Header{"If-None-Match": {etag}})
+		if again.Code != http.StatusNotModified || again.Body.Len() != 0 {
+			t.Errorf("got status %d with %d bytes, want 304", again.Code, again.Body.Len())
+		}
+
+		other := get("/api/v1/tiles/10/296/366.mvt", http.Header{"If-None-Match": {etag}})
+		if other.Code != http.StatusOK {
+			t.Errorf("got status %d for a different tile", other.Code)
+		}
+	})
+
+	for _, target := range []string{"/api/v1/tiles/1/2/0.mvt", "/api/v1/tiles/a/0/0.mvt", "/api/v1/tiles/0/0/0.png", "/api/v1/tiles/23/0/0.mvt"} {
+		if rec := get(target, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want 400", target, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+// minSecretLength is the shortest signing secret a subscriber may choose.
+const minSecretLength = 16
+
+// webhookRequest is the body of a request creating or replacing a webhook.
+// A missing secret is generated on create and kept on replace.
+type webhookRequest struct {
+	URL string `json:"url"`
+	regionJSON
+	MinMag *float64 `json:"minmag"`
+	Secret string   `json:"secret"`
+}
+
+// webhook is a models.Webhook in a response. The secret is only returned when
+// the webhook is created.
+type webhook struct {
+	ID  int64  `json:"id"`
+	URL string `json:"url"`
+	regionJSON
+	MinMag    *float64  `json:"minmag,omitempty"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newWebhook(w models.Webhook) webhook {
+	return webhook{
+		ID:         w.ID,
+		URL:        w.URL,
+		regionJSON: newRegionJSON(w.Region),
+		MinMag:     w.MinMagnitude,
+		CreatedAt:  w.CreatedAt,
+		UpdatedAt:  w.UpdatedAt,
+	}
+}
+
+// parseWebhookRequest decodes and validates the body of r, responding with a
+// problem when it is not a valid subscription.
+func parseWebhookRequest(w http.ResponseWriter, r *http.Request, logger *slog.Logger) (models.Webhook, bool) {
+	var req webhookRequest
+	if !decodeBody(w, r, logger, &req, "a webhook subscription") {
+		return models.Webhook{}, false
+	}
+
+	invalid := &validationError{}
+
+	u, err := url.Parse(req.URL)
+	switch {
+	case req.URL == "":
+		invalid.add("url", "is required")
+	case err != nil:
+		invalid.add("url", err.Error())
+	case u.Scheme != "http" && u.Scheme != "https" || u.Host == "":
+		invalid.add("url", fmt.Sprintf("expected an absolute http or https URL, got %q", req.URL))
+	}
+
+	region := req.region(invalid)
+	checkRange(invalid, "minmag", req.MinMag, -2, 10)
+
+	if req.Secret != "" && len(req.Secret) < minSecretLength {
+		invalid.add("secret", fmt.Sprintf("must be at least %d characters", minSecretLength))
+	}
+
+	if err := invalid.err(); err != nil {
+		writeInvalid(w, r, logger, err)
+		return models.Webhook{}, false
+	}
+
+	return models.Webhook{
+		URL:          req.URL,
+		Region:       region,
+		MinMagnitude: req.MinMag,
+		Secret:       req.Secret,
+	}, true
+}
+
+func handleCreateWebhook(logger *slog.Logger, webhooks *models.WebhookModel) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			hook, ok := parseWebhookRequest(w, r, logger)
+			if !ok {
+				return
+			}
+
+			if hook.Secret == "" {
+				secret := make([]byte, 32)
+				if _, err := rand.Read(secret); err != nil {
+					writeServerError(w, r, logger, err)
+					return
+				}
+				hook.Secret = hex.EncodeToString(secret)
+			}
+
+			id, err := webhooks.Insert(hook)
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			created, err := webhooks.Get(id)
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			data := newWebhook(created)
+			data.Secret = created.Secret
+
+			w.Header().Set("Location", fmt.Sprintf("/api/v1/webhooks/%d", id))
+			writeData(w, logger, http.StatusCreated, "Created webhook", data)
+
+			logger.Info("CreateWebhook", "time_ms", time.Since(start), "id", id)
+		},
+	)
+}
+
+func handleListWebhooks(logger *slog.Logger, webhooks *models.WebhookModel) http.Handler {
+	type Response struct {
+		Message string    `json:"message"`
+		Data    []webhook `json:"data"`
+		Count   int       `json:"count"`
+		Total   int       `json:"total"`
+		Limit   int       `json:"limit"`
+		Offset  int       `json:"offset"`
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			invalid := &validationError{}
+			limit, offset := parsePage(r, 20, 100, invalid)
+			if err := invalid.err(); err != nil {
+				writeInvalid(w, r, logger, err)
+				return
+			}
+
+			results, total, err := webhooks.List(limit, offset)
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			resp := Response{
+				Message: "Webhooks",
+				Data:    []webhook{},
+				Total:   total,
+				Limit:   limit,
+				Offset:  offset,
+			}
+			for _, hook := range results {
+				resp.Data = append(resp.Data, newWebhook(hook))
+			}
+			resp.Count = len(resp.Data)
+
+			js, _ := json.Marshal(resp)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err = w.Write(js)
+			if err != nil {
+				logger.Error("writing response", "error", err)
+				return
+			}
+		},
+	)
+}
+
+func handleGetWebhook(logger *slog.Logger, webhooks *models.WebhookModel) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			id, ok := pathID(w, r, logger, "webhook")
+			if !ok {
+				return
+			}
+
+			hook, err := webhooks.Get(id)
+			if err != nil {
+				writeLookupError(w, r, logger, "webhook", id, err)
+				return
+			}
+
+			writeData(w, logger, http.StatusOK, "Webhook", newWebhook(hook))
+		},
+	)
+}
+
+// handleUpdateWebhook replaces the subscription of a webhook. Its secret is
+// kept unless a new one is given.
+func handleUpdateWebhook(logger *slog.Logger, webhooks *models.WebhookModel) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			id, ok := pathID(w, r, logger, "webhook")
+			if !ok {
+				return
+			}
+
+			hook, ok := parseWebhookRequest(w, r, logger)
+			if !ok {
+				return
+			}
+
+			current, err := webhooks.Get(id)
+			if err != nil {
+				writeLookupError(w, r, logger, "webhook", id, err)
+				return
+			}
+
+			hook.ID = id
+			if hook.Secret == "" {
+				hook.Secret = current.Secret
+			}
+
+			if err := webhooks.Update(hook); err != nil {
+				writeLookupError(w, r, logger, "webhook", id, err)
+				return
+			}
+
+			updated, err := webhooks.Get(id)
+			if err != nil {
+				writeLookupError(w, r, logger, "webhook", id, err)
+				return
+			}
+
+			writeData(w, logger, http.StatusOK, "Updated webhook", newWebhook(updated))
+
+			logger.Info("UpdateWebhook", "time_ms", time.Since(start), "id", id)
+		},
+	)
+}
+
+func handleDeleteWebhook(logger *slog.Logger, webhooks *models.WebhookModel) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			id, ok := pathID(w, r, logger, "webhook")
+			if !ok {
+				return
+			}
+
+			if err := webhooks.Delete(id); err != nil {
+				writeLookupError(w, r, logger, "webhook", id, err)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+
+			logger.Info("DeleteWebhook", "id", id)
+		},
+	)
+}
+
+// handleListWebhookDeliveries returns the delivery log of a webhook, newest
+// attempt first.
+func handleListWebhookDeliveries(logger *slog.Logger, webhooks *models.WebhookModel) http.Handler {
+	type Delivery struct {
+		ID          int64     `json:"id"`
+		EventID     string    `json:"event_id"`
+		ChangeID    int64     `json:"change_id"`
+		Type        string    `json:"type"`
+		Attempt     int       `json:"attempt"`
+		AttemptedAt time.Time `json:"attempted_at"`
+		StatusCode  int       `json:"status_code,omitempty"`
+		Error       string    `json:"error,omitempty"`
+	}
+
+	type Response struct {
+		Message string     `json:"message"`
+		Data    []Delivery `json:"data"`
+		Count   int        `json:"count"`
+		Total   int        `json:"total"`
+		Limit   int        `json:"limit"`
+		Offset  int        `json:"offset"`
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			id, ok := pathID(w, r, logger, "webhook")
+			if !ok {
+				return
+			}
+
+			invalid := &validationError{}
+			limit, offset := parsePage(r, 20, 100, invalid)
+			if err := invalid.err(); err != nil {
+				writeInvalid(w, r, logger, err)
+				return
+			}
+
+			if _, err := webhooks.Get(id); err != nil {
+				writeLookupError(w, r, logger, "webhook", id, err)
+				return
+			}
+
+			results, total, err := webhooks.Deliveries(id, limit, offset)
+			if err != nil {
+				writeServerError(w, r, logger, err)
+				return
+			}
+
+			resp := Response{
+				Message: "Webhook deliveries",
+				Data:    []Delivery{},
+				Total:   total,
+				Limit:   limit,
+				Offset:  offset,
+			}
+			for _, d := range results {
+				resp.Data = append(resp.Data, Delivery{
+					ID:          d.ID,
+					EventID:     d.GUID,
+					ChangeID:    d.ChangeID,
+					Type:        "event." + d.Change,
+					Attempt:     d.Attempt,
+					AttemptedAt: d.AttemptedAt,
+					StatusCode:  d.StatusCode,
+					Error:       d.Error,
+				})
+			}
+			resp.Count = len(resp.Data)
+
+			js, _ := json.Marshal(resp)
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, err = w.Write(js)
+			if err != nil {
+				logger.Error("writing response", "error", err)
+				return
+			}
+		},
+	)
+}
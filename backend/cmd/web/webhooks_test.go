@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+func TestHandleWebhooks(t *testing.T) {
+	webhooks := &models.WebhookModel{DB: newTestDB(t)}
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/v1/webhooks", handleCreateWebhook(discardLogger(), webhooks))
+	mux.Handle("GET /api/v1/webhooks", handleListWebhooks(discardLogger(), webhooks))
+	mux.Handle("GET /api/v1/webhooks/{id}", handleGetWebhook(discardLogger(), webhooks))
+	mux.Handle("PUT /api/v1/webhooks/{id}", handleUpdateWebhook(discardLogger(), webhooks))
+	mux.Handle("DELETE /api/v1/webhooks/{id}", handleDeleteWebhook(discardLogger(), webhooks))
+	mux.Handle("GET /api/v1/webhooks/{id}/deliveries", handleListWebhookDeliveries(discardLogger(), webhooks))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	type Webhook struct {
+		ID       int64
+		URL      string
+		Coords   string
+		Lat      *float64
+		RadiusKm *float64
+		MinMag   *float64
+		Secret   string
+	}
+	var resp struct {
+		Data Webhook
+	}
+
+	rec := do(http.MethodPost, "/api/v1/webhooks", `{"url":"https://example.com/hook","lat":45.5,"lng":-73.6,"radiuskm":200,"minmag":4}`)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Location") != "/api/v1/webhooks/1" {
+		t.Fatalf("got status %d at %q: %s", rec.Code, rec.Header().Get("Location"), rec.Body)
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.ID != 1 || *resp.Data.RadiusKm != 200 || *resp.Data.MinMag != 4 || len(resp.Data.Secret) != 64 {
+		t.Errorf("got %+v, want the webhook with a generated secret", resp.Data)
+	}
+	secret := resp.Data.Secret
+
+	// the secret is only shown on create, and kept when not replaced
+	rec = do(http.MethodPut, "/api/v1/webhooks/1", `{"url":"https://example.com/moved","coords":"-80,40,-70,50"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", rec.Code, rec.Body)
+	}
+	resp.Data = Webhook{}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.URL != "https://example.com/moved" || resp.Data.Coords != "-80,40,-70,50" || resp.Data.Lat != nil || resp.Data.MinMag != nil || resp.Data.Secret != "" {
+		t.Errorf("got %+v after update", resp.Data)
+	}
+	if hook, _ := webhooks.Get(1); hook.Secret != secret {
+		t.Errorf("got secret %q, want it kept", hook.Secret)
+	}
+
+	rec = do(http.MethodGet, "/api/v1/webhooks", "")
+	var list struct {
+		Count int
+		Total int
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Count != 1 || list.Total != 1 {
+		t.Errorf("got %+v", list)
+	}
+
+	rec = do(http.MethodGet, "/api/v1/webhooks/1/deliveries", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"data":[]`) {
+		t.Errorf("got status %d: %s", rec.Code, rec.Body)
+	}
+
+	for _, tt := range []struct {
+		body  string
+		param string
+	}{
+		{`{"url":"ftp://example.com"}`, "url"},
+		{`{"url":"https://example.com","coords":"1,2,3"}`, "coords"},
+		{`{"url":"https://example.com","coords":"-80,40,-70,50","lat":45}`, "coords"},
+		{`{"url":"https://example.com","lat":45,"lng":-73}`, "radiuskm"},
+		{`{"url":"https://example.com","lat":45,"lng":-73,"radiuskm":0}`, "greater than 0"},
+		{`{"url":"https://example.com","minmag":12}`, "minmag"},
+		{`{"url":"https://example.com","secret":"short"}`, "secret"},
+		{`{"url":"https://example.com","min_magnitude":4}`, "unknown field"},
+	} {
+		rec := do(http.MethodPost, "/api/v1/webhooks", tt.body)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tt.param) {
+			t.Errorf("%s: got status %d: %s", tt.body, rec.Code, rec.Body)
+		}
+	}
+
+	if rec := do(http.MethodDelete, "/api/v1/webhooks/1", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("got status %d for delete", rec.Code)
+	}
+	for _, path := range []string{"/api/v1/webhooks/1", "/api/v1/webhooks/x", "/api/v1/webhooks/1/deliveries"} {
+		if rec := do(http.MethodGet, path, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: got status %d, want 404", path, rec.Code)
+		}
+	}
+	if rec := do(http.MethodDelete, "/api/v1/webhooks/1", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("got status %d for a second delete, want 404", rec.Code)
+	}
+}
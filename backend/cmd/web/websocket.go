@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// webSocketWriteTimeout is how long a client has to accept one message
+// before its connection is closed.
+const webSocketWriteTimeout = 10 * time.Second
+
+// webSocketRequest is a message from a client. The only type is
+// "subscribe", which replaces the region and magnitude the client receives
+// events for; an empty coords or minmag removes that part of the filter.
+type webSocketRequest struct {
+	Type   string   `json:"type"`
+	Coords string   `json:"coords"`
+	MinMag *float64 `json:"minmag"`
+}
+
+// webSocketMessage is a message to a client: an event that was "created" or
+// "updated", the "subscribed" reply to a request, a "heartbeat", or an
+// "error".
+type webSocketMessage struct {
+	Type          string         `json:"type"`
+	ID            int64          `json:"id,omitempty"`
+	Event         *exportRow     `json:"event,omitempty"`
+	Coords        string         `json:"coords,omitempty"`
+	MinMag        *float64       `json:"minmag,omitempty"`
+	Detail        string         `json:"detail,omitempty"`
+	InvalidParams []invalidParam `json:"invalid-params,omitempty"`
+}
+
+// handleWebSocket pushes events over a WebSocket when ingestion creates or
+// updates them, like handleStream, and lets the client change its filter
+// without reconnecting. The first filter comes from the coords and minmag
+// query parameters.
+//
+// At most config.MaxWebSockets connections are served at once. A client more
+// than config.WebSocketBuffer events behind, or that doesn't accept a message
+// within webSocketWriteTimeout, is disconnected.
+func handleWebSocket(ctx context.Context, logger *slog.Logger, config *Config, hub *Hub) http.Handler {
+	slots := make(chan struct{}, config.MaxWebSockets)
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			invalid := &validationError{}
+			filter := parseEventFilter(r.URL.Query(), invalid)
+			if err := invalid.err(); err != nil {
+				writeInvalid(w, r, logger, err)
+				return
+			}
+
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			default:
+				w.Header().Set("Retry-After", strconv.Itoa(int(streamRetry.Seconds())))
+				writeProblem(w, r, logger, http.StatusServiceUnavailable, "too many WebSocket connections, try again later")
+				return
+			}
+
+			server := websocket.Server{
+				// browsers on any origin may connect, as with the cors middleware
+				Handshake: func(*websocket.Config, *http.Request) error { return nil },
+				Handler: func(ws *websocket.Conn) {
+					sent, err := serveWebSocket(ctx, config, hub, ws, filter)
+					logger.Info("WebSocket",
+						"time_ms", time.Since(start),
+						"sent", sent,
+						"reason", err)
+				},
+			}
+			server.ServeHTTP(w, r)
+		},
+	)
+}
+
+// serveWebSocket sends the changes matching filter to ws until the client
+// goes away, falls behind or ctx is cancelled, and returns how many events
+// it sent and why it stopped.
+func serveWebSocket(ctx context.Context, config *Config, hub *Hub, ws *websocket.Conn, filter eventFilter) (sent int, err error) {
+	defer ws.Close()
+
+	sub := hub.Subscribe(filter, config.WebSocketBuffer)
+	defer hub.Unsubscribe(sub)
+
+	// requests are read here and handled below, so only one goroutine writes
+	done := make(chan struct{})
+	defer close(done)
+	requests := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			var msg []byte
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case requests <- msg:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	send := func(msg webSocketMessage) error {
+		if err := ws.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout)); err != nil {
+			return err
+		}
+		return websocket.JSON.Send(ws, msg)
+	}
+
+	heartbeat := time.NewTicker(config.StreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for err == nil {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case err = <-readErr:
+		case <-heartbeat.C:
+			err = send(webSocketMessage{Type: "heartbeat"})
+		case msg := <-requests:
+			reply := subscribe(msg, &filter)
+			if reply.Type == "subscribed" {
+				hub.SetFilter(sub, filter)
+			}
+			err = send(reply)
+		case c, ok := <-sub.C:
+			switch {
+			case !ok:
+				err = fmt.Errorf("client fell more than %d events behind", config.WebSocketBuffer)
+				// the connection closes either way, so a failed send is ignored
+				_ = send(webSocketMessage{Type: "error", Detail: err.Error()})
+			case !filter.matches(c.Entry):
+				// published before the filter changed
+				continue
+			default:
+				row := newExportRow(c.Entry, false)
+				err = send(webSocketMessage{Type: c.Change.String(), ID: c.Entry.ChangeID, Event: &row})
+				if err == nil {
+					sent = sent + 1
+				}
+			}
+		}
+	}
+
+	return sent, err
+}
+
+// subscribe replaces filter with the one requested by msg and returns the
+// reply to send. filter is left alone when msg is not a valid request.
+func subscribe(msg []byte, filter *eventFilter) webSocketMessage {
+	var req webSocketRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return webSocketMessage{Type: "error", Detail: "messages must be JSON objects: " + err.Error()}
+	}
+	if req.Type != "subscribe" {
+		return webSocketMessage{Type: "error", Detail: fmt.Sprintf("unknown message type %q", req.Type)}
+	}
+
+	query := url.Values{}
+	if req.Coords != "" {
+		query.Set("coords", req.Coords)
+	}
+	if req.MinMag != nil {
+		query.Set("minmag", strconv.FormatFloat(*req.MinMag, 'f', -1, 64))
+	}
+
+	invalid := &validationError{}
+	requested := parseEventFilter(query, invalid)
+	if err := invalid.err(); err != nil {
+		return webSocketMessage{Type: "error", Detail: "the subscription has invalid parameters", InvalidParams: invalid.params}
+	}
+
+	*filter = requested
+	return webSocketMessage{Type: "subscribed", Coords: req.Coords, MinMag: req.MinMag}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+func dialWebSocket(t *testing.T, srv *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?"+query, "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+// nextMessage returns the next message on ws that isn't a heartbeat.
+func nextMessage(t *testing.T, ws *websocket.Conn) webSocketMessage {
+	t.Helper()
+
+	if err := ws.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		var msg webSocketMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type != "heartbeat" {
+			return msg
+		}
+	}
+}
+
+func TestHandleWebSocket(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hub := NewHub()
+	entries := &models.EntryModel{DB: newTestDB(t), OnChange: hub.Publish}
+
+	config := &Config{StreamHeartbeat: 50 * time.Millisecond, MaxWebSockets: 1, WebSocketBuffer: 10}
+	srv := httptest.NewServer(handleWebSocket(ctx, discardLogger(), config, hub))
+	defer srv.Close()
+
+	ws := dialWebSocket(t, srv, "minmag=3")
+	defer ws.Close()
+
+	// the only connection allowed is taken
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got status %d for a second connection, want 503", res.StatusCode)
+	}
+
+	insertTestEntries(t, entries,
+		models.Entry{GUID: "small", Latitude: 45, Longitude: -75, Magnitude: 2},
+		models.Entry{GUID: "big", Latitude: -45, Longitude: 170, Magnitude: 4},
+	)
+	msg := nextMessage(t, ws)
+	if msg.Type != "created" || msg.ID != 2 || msg.Event == nil || msg.Event.GUID != "big" {
+		t.Errorf("got %+v, want big created", msg)
+	}
+
+	// narrowing the subscription to a region applies without reconnecting
+	if err := websocket.Message.Send(ws, `{"type":"subscribe","coords":"-80,40,-70,50"}`); err != nil {
+		t.Fatal(err)
+	}
+	if msg := nextMessage(t, ws); msg.Type != "subscribed" || msg.Coords != "-80,40,-70,50" || msg.MinMag != nil {
+		t.Errorf("got %+v, want the subscription confirmed", msg)
+	}
+
+	insertTestEntries(t, entries,
+		models.Entry{GUID: "far", Latitude: -45, Longitude: 170, Magnitude: 5},
+		models.Entry{GUID: "near", Latitude: 45, Longitude: -75, Magnitude: 1},
+	)
+	if msg := nextMessage(t, ws); msg.Type != "created" || msg.Event == nil || msg.Event.GUID != "near" {
+		t.Errorf("got %+v, want near created", msg)
+	}
+
+	// a rejected subscription keeps the current one
+	for _, req := range []string{`{"type":"subscribe","minmag":11}`, `{"type":"unsubscribe"}`, `nope`} {
+		if err := websocket.Message.Send(ws, req); err != nil {
+			t.Fatal(err)
+		}
+		if msg := nextMessage(t, ws); msg.Type != "error" {
+			t.Errorf("%s: got %+v, want an error", req, msg)
+		}
+	}
+	insertTestEntries(t, entries, models.Entry{GUID: "near2", Latitude: 46, Longitude: -74, Magnitude: 1})
+	if msg := nextMessage(t, ws); msg.Event == nil || msg.Event.GUID != "near2" {
+		t.Errorf("got %+v, want near2 created", msg)
+	}
+
+	// cancelling the server's context closes the connection
+	cancel()
+	if err := ws.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		var msg webSocketMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			if strings.Contains(err.Error(), "timeout") {
+				t.Fatal("connection still open after cancel")
+			}
+			break
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handleWebSocket(ctx, discardLogger(), config, hub).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/ws?coords=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,320 @@
+// Package feeditem turns atom feed items with georss extensions into
+// entries. Every field that can't be read is reported as a FieldError so
+// callers can say exactly why an item was skipped.
+package feeditem
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+// Fields reported in a FieldError.
+const (
+	FieldGUID      = "guid"
+	FieldTime      = "time"
+	FieldLocation  = "location"
+	FieldDepth     = "depth"
+	FieldMagnitude = "magnitude"
+)
+
+var (
+	ErrMissing    = errors.New("missing")
+	ErrMalformed  = errors.New("malformed")
+	ErrOutOfRange = errors.New("out of range")
+)
+
+var (
+	reTag       = regexp.MustCompile(`<[^>]*>`)
+	reTimestamp = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|\s?UTC|[+-]\d{2}:?\d{2})?`)
+	reDepth     = regexp.MustCompile(`(?i)depth\s*:?\s*(-?\d+(?:\.\d+)?)\s*km`)
+	rePlace     = regexp.MustCompile(`(?i)(?:region|location|place)\s*:\s*([^\n]+)`)
+
+	// M2.6, M 2.6, M2.6 ML or M2.6 (Mw)
+	reTitleMagnitude = regexp.MustCompile(`\bM\s?(\d+(?:\.\d+)?)(?:\s*\(?\s*(M[A-Za-z]{1,3}|m[bB][A-Za-z_]*)\b\)?)?`)
+
+	// Magnitude 2.6 ML, Magnitude: 2.6 (mb)
+	reContentMagnitude = regexp.MustCompile(`(?i:magnitude)\s*:?\s*(\d+(?:\.\d+)?)(?:\s*\(?\s*(M[A-Za-z]{1,3}|m[bB][A-Za-z_]*)\b\)?)?`)
+)
+
+// -----------------------------------------------------------------------------
+// Types
+// -----------------------------------------------------------------------------
+
+// FieldError describes why a field of an item could not be read.
+type FieldError struct {
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	if e.Value == "" {
+		return e.Field + ": " + e.Err.Error()
+	}
+
+	return fmt.Sprintf("%s: %s %q", e.Field, e.Err, e.Value)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Item is a parsed feed item.
+type Item struct {
+	GUID          string
+	Title         string
+	Content       string
+	Categories    string
+	Time          time.Time
+	Latitude      float64
+	Longitude     float64
+	DepthKm       float64
+	Magnitude     float64
+	MagnitudeType string
+	Place         string
+	Published     *time.Time
+	Updated       *time.Time
+}
+
+// -----------------------------------------------------------------------------
+// Public
+// -----------------------------------------------------------------------------
+
+// Parse reads an item. When fields are invalid the returned error joins a
+// FieldError for each of them.
+func Parse(item *gofeed.Item) (*Item, error) {
+	var errs []error
+
+	parsed := &Item{
+		GUID:       strings.TrimSpace(item.GUID),
+		Title:      strings.TrimSpace(item.Title),
+		Content:    item.Content,
+		Categories: strings.Join(item.Categories, ", "),
+		Published:  item.PublishedParsed,
+		Updated:    item.UpdatedParsed,
+	}
+
+	if parsed.GUID == "" {
+		errs = append(errs, &FieldError{Field: FieldGUID, Err: ErrMissing})
+	}
+
+	text := plainText(item.Content)
+	georss := item.Extensions["georss"]
+
+	var err error
+
+	parsed.Time, err = parseTime(text, parsed.Title)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	parsed.Latitude, parsed.Longitude, err = parsePoint(georss)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	parsed.DepthKm, err = parseDepth(text, georss)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	parsed.Magnitude, parsed.MagnitudeType, err = parseMagnitude(text, parsed.Title)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	parsed.Place = parsePlace(text, parsed.Title)
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return parsed, nil
+}
+
+// Entry converts the item into an entry.
+func (i *Item) Entry() models.Entry {
+	t := i.Time
+
+	return models.Entry{
+		GUID:          i.GUID,
+		Title:         i.Title,
+		Content:       i.Content,
+		Categories:    i.Categories,
+		Place:         i.Place,
+		Latitude:      float32(i.Latitude),
+		Longitude:     float32(i.Longitude),
+		Elevation:     int32(math.Round(-i.DepthKm * 1000)),
+		Magnitude:     float32(i.Magnitude),
+		MagnitudeType: i.MagnitudeType,
+		Time:          &t,
+		Published:     i.Published,
+		Updated:       i.Updated,
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Fields
+// -----------------------------------------------------------------------------
+
+// parseTime finds the first timestamp in the content, falling back to the
+// title. Timestamps without a zone are taken as UTC.
+func parseTime(text, title string) (time.Time, error) {
+	v := reTimestamp.FindString(text)
+	if v == "" {
+		v = reTimestamp.FindString(title)
+	}
+	if v == "" {
+		return time.Time{}, &FieldError{Field: FieldTime, Err: ErrMissing}
+	}
+
+	normalized := strings.Replace(v, " ", "T", 1)
+	normalized = strings.TrimSpace(strings.TrimSuffix(normalized, "UTC"))
+	if !strings.HasSuffix(normalized, "Z") && !hasOffset(normalized) {
+		normalized += "Z"
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, normalized)
+	if err != nil {
+		// numeric offsets may be written without a colon
+		t, err = time.Parse("2006-01-02T15:04:05.999999999-0700", normalized)
+	}
+	if err != nil {
+		return time.Time{}, &FieldError{Field: FieldTime, Value: v, Err: ErrMalformed}
+	}
+
+	return t.UTC(), nil
+}
+
+// parsePoint reads the georss:point "lat lng" pair.
+func parsePoint(georss map[string][]ext.Extension) (lat, lng float64, err error) {
+	if len(georss["point"]) == 0 {
+		return 0, 0, &FieldError{Field: FieldLocation, Err: ErrMissing}
+	}
+
+	v := georss["point"][0].Value
+	parts := strings.Fields(v)
+	if len(parts) != 2 {
+		return 0, 0, &FieldError{Field: FieldLocation, Value: v, Err: ErrMalformed}
+	}
+
+	lat, errLat := strconv.ParseFloat(parts[0], 64)
+	lng, errLng := strconv.ParseFloat(parts[1], 64)
+	if errLat != nil || errLng != nil {
+		return 0, 0, &FieldError{Field: FieldLocation, Value: v, Err: ErrMalformed}
+	}
+
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return 0, 0, &FieldError{Field: FieldLocation, Value: v, Err: ErrOutOfRange}
+	}
+
+	return lat, lng, nil
+}
+
+// parseDepth reads "Depth: 18.4 km" from the content, falling back to the
+// georss:elev element which is in metres above sea level.
+func parseDepth(text string, georss map[string][]ext.Extension) (float64, error) {
+	var depth float64
+
+	if match := reDepth.FindStringSubmatch(text); match != nil {
+		v, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return 0, &FieldError{Field: FieldDepth, Value: match[0], Err: ErrMalformed}
+		}
+		depth = v
+	} else if len(georss["elev"]) > 0 {
+		v := strings.TrimSpace(georss["elev"][0].Value)
+		elev, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, &FieldError{Field: FieldDepth, Value: v, Err: ErrMalformed}
+		}
+		depth = -elev / 1000
+	} else {
+		return 0, &FieldError{Field: FieldDepth, Err: ErrMissing}
+	}
+
+	// the deepest recorded earthquakes are around 750 km, events above the
+	// surface can only be a few km up a mountain
+	if depth < -10 || depth > 1000 {
+		return 0, &FieldError{Field: FieldDepth, Value: strconv.FormatFloat(depth, 'f', -1, 64), Err: ErrOutOfRange}
+	}
+
+	return depth, nil
+}
+
+// parseMagnitude reads the magnitude and its type from the content, falling
+// back to the title. The type is empty when the feed doesn't give one.
+func parseMagnitude(text, title string) (float64, string, error) {
+	match := reContentMagnitude.FindStringSubmatch(text)
+	if match == nil {
+		match = reTitleMagnitude.FindStringSubmatch(title)
+	}
+	if match == nil {
+		return 0, "", &FieldError{Field: FieldMagnitude, Value: title, Err: ErrMissing}
+	}
+
+	magnitude, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, "", &FieldError{Field: FieldMagnitude, Value: match[0], Err: ErrMalformed}
+	}
+
+	if magnitude < -2 || magnitude > 10 {
+		return 0, "", &FieldError{Field: FieldMagnitude, Value: match[1], Err: ErrOutOfRange}
+	}
+
+	magnitudeType := match[2]
+
+	// the title may carry the type when the content doesn't
+	if magnitudeType == "" {
+		if m := reTitleMagnitude.FindStringSubmatch(title); m != nil {
+			magnitudeType = m[2]
+		}
+	}
+
+	return magnitude, magnitudeType, nil
+}
+
+// parsePlace reads a labelled region from the content, or otherwise what is
+// left of the title once the time and magnitude are removed.
+func parsePlace(text, title string) string {
+	if match := rePlace.FindStringSubmatch(text); match != nil {
+		return strings.TrimSpace(match[1])
+	}
+
+	if _, after, ok := strings.Cut(title, " - "); ok {
+		return strings.TrimSpace(after)
+	}
+
+	place := reTimestamp.ReplaceAllString(title, "")
+	place = reTitleMagnitude.ReplaceAllString(place, "")
+
+	return strings.Trim(place, " -,")
+}
+
+// -----------------------------------------------------------------------------
+// Utilities
+// -----------------------------------------------------------------------------
+
+// plainText strips markup from html content and puts each line break on its
+// own line.
+func plainText(content string) string {
+	content = strings.NewReplacer("<br>", "\n", "<br/>", "\n", "<br />", "\n", "</p>", "\n").Replace(content)
+	content = reTag.ReplaceAllString(content, " ")
+
+	return html.UnescapeString(content)
+}
+
+func hasOffset(v string) bool {
+	i := strings.LastIndexAny(v, "+-")
+	return i > len("2006-01-02")
+}
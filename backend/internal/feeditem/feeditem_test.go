@@ -0,0 +1,241 @@
+package feeditem
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+func georss(point, elev string) ext.Extensions {
+	e := map[string][]ext.Extension{}
+	if point != "" {
+		e["point"] = []ext.Extension{{Name: "point", Value: point}}
+	}
+	if elev != "" {
+		e["elev"] = []ext.Extension{{Name: "elev", Value: elev}}
+	}
+
+	return ext.Extensions{"georss": e}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		item gofeed.Item
+
+		wantTime          time.Time
+		wantLatitude      float64
+		wantLongitude     float64
+		wantDepthKm       float64
+		wantMagnitude     float64
+		wantMagnitudeType string
+		wantPlace         string
+	}{
+		{
+			name: "title magnitude and georss elevation",
+			item: gofeed.Item{
+				GUID:       "tag:example,2025:1",
+				Title:      "M2.6 - 12 km NNE of Blaine, WA",
+				Content:    "2025-10-16T22:00:00Z",
+				Extensions: georss("49.1032 -122.6891", "-18400"),
+			},
+			wantTime:      time.Date(2025, 10, 16, 22, 0, 0, 0, time.UTC),
+			wantLatitude:  49.1032,
+			wantLongitude: -122.6891,
+			wantDepthKm:   18.4,
+			wantMagnitude: 2.6,
+			wantPlace:     "12 km NNE of Blaine, WA",
+		},
+		{
+			name: "labelled html content",
+			item: gofeed.Item{
+				GUID:       "tag:example,2025:2",
+				Title:      "2025-10-16 22:00:00 UTC M4.1",
+				Content:    "2025-10-16T22:00:00.5Z<br/>Magnitude: 4.1 (Mw)<br/>Depth: 33.0 km<br/>Region: Haida Gwaii, B.C. &amp; area",
+				Extensions: georss("53.1 -132.5", "-10000"),
+			},
+			wantTime:          time.Date(2025, 10, 16, 22, 0, 0, 500_000_000, time.UTC),
+			wantLatitude:      53.1,
+			wantLongitude:     -132.5,
+			wantDepthKm:       33,
+			wantMagnitude:     4.1,
+			wantMagnitudeType: "Mw",
+			wantPlace:         "Haida Gwaii, B.C. & area",
+		},
+		{
+			name: "title type and time",
+			item: gofeed.Item{
+				GUID:       "tag:example,2025:3",
+				Title:      "2025-10-16 22:00:00 UTC M 1.7 ML 16 km E of Colwood, BC",
+				Content:    "",
+				Extensions: georss("48.42 -123.28", "-5000"),
+			},
+			wantTime:          time.Date(2025, 10, 16, 22, 0, 0, 0, time.UTC),
+			wantLatitude:      48.42,
+			wantLongitude:     -123.28,
+			wantDepthKm:       5,
+			wantMagnitude:     1.7,
+			wantMagnitudeType: "ML",
+			wantPlace:         "16 km E of Colwood, BC",
+		},
+		{
+			name: "offset time and mb",
+			item: gofeed.Item{
+				GUID:       "tag:example,2025:4",
+				Title:      "M3.0 mb - Labrador Sea",
+				Content:    "Time: 2025-10-16T18:00:00-04:00 Depth 12 km",
+				Extensions: georss("58.0 -56.0", ""),
+			},
+			wantTime:          time.Date(2025, 10, 16, 22, 0, 0, 0, time.UTC),
+			wantLatitude:      58,
+			wantLongitude:     -56,
+			wantDepthKm:       12,
+			wantMagnitude:     3,
+			wantMagnitudeType: "mb",
+			wantPlace:         "Labrador Sea",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(&tt.item)
+			if err != nil {
+				t.Fatalf("Parse: unexpected error %s", err)
+			}
+
+			if !got.Time.Equal(tt.wantTime) {
+				t.Errorf("Time = %s, want %s", got.Time, tt.wantTime)
+			}
+			if got.Latitude != tt.wantLatitude || got.Longitude != tt.wantLongitude {
+				t.Errorf("Latitude, Longitude = %v, %v, want %v, %v", got.Latitude, got.Longitude, tt.wantLatitude, tt.wantLongitude)
+			}
+			if got.DepthKm != tt.wantDepthKm {
+				t.Errorf("DepthKm = %v, want %v", got.DepthKm, tt.wantDepthKm)
+			}
+			if got.Magnitude != tt.wantMagnitude {
+				t.Errorf("Magnitude = %v, want %v", got.Magnitude, tt.wantMagnitude)
+			}
+			if got.MagnitudeType != tt.wantMagnitudeType {
+				t.Errorf("MagnitudeType = %q, want %q", got.MagnitudeType, tt.wantMagnitudeType)
+			}
+			if got.Place != tt.wantPlace {
+				t.Errorf("Place = %q, want %q", got.Place, tt.wantPlace)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		item gofeed.Item
+		want map[string]error
+	}{
+		{
+			name: "no magnitude in title",
+			item: gofeed.Item{
+				GUID:       "tag:example,2025:1",
+				Title:      "12 km NNE of Blaine, WA",
+				Content:    "2025-10-16T22:00:00Z",
+				Extensions: georss("49.1 -122.6", "-18400"),
+			},
+			want: map[string]error{FieldMagnitude: ErrMissing},
+		},
+		{
+			name: "nothing to go on",
+			item: gofeed.Item{},
+			want: map[string]error{
+				FieldGUID:      ErrMissing,
+				FieldTime:      ErrMissing,
+				FieldLocation:  ErrMissing,
+				FieldDepth:     ErrMissing,
+				FieldMagnitude: ErrMissing,
+			},
+		},
+		{
+			name: "malformed values",
+			item: gofeed.Item{
+				GUID:       "tag:example,2025:3",
+				Title:      "M2.6",
+				Content:    "2025-13-45T22:00:00Z",
+				Extensions: georss("49.1,-122.6", "deep"),
+			},
+			want: map[string]error{
+				FieldTime:     ErrMalformed,
+				FieldLocation: ErrMalformed,
+				FieldDepth:    ErrMalformed,
+			},
+		},
+		{
+			name: "out of range",
+			item: gofeed.Item{
+				GUID:       "tag:example,2025:4",
+				Title:      "M12.0",
+				Content:    "2025-10-16T22:00:00Z Depth: 4000 km",
+				Extensions: georss("91 -122.6", ""),
+			},
+			want: map[string]error{
+				FieldLocation:  ErrOutOfRange,
+				FieldDepth:     ErrOutOfRange,
+				FieldMagnitude: ErrOutOfRange,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(&tt.item)
+			if err == nil {
+				t.Fatal("Parse: expected error")
+			}
+
+			got := map[string]error{}
+			for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+				var fieldErr *FieldError
+				if !errors.As(e, &fieldErr) {
+					t.Fatalf("error %v is not a FieldError", e)
+				}
+				got[fieldErr.Field] = fieldErr.Err
+			}
+
+			if len(got) != len(tt.want) {
+				t.Errorf("got errors for %v, want %v", got, tt.want)
+			}
+			for field, want := range tt.want {
+				if !errors.Is(got[field], want) {
+					t.Errorf("%s: got %v, want %v", field, got[field], want)
+				}
+			}
+		})
+	}
+}
+
+func TestEntry(t *testing.T) {
+	item, err := Parse(&gofeed.Item{
+		GUID:       "tag:example,2025:1",
+		Title:      "M2.6 ML - 12 km NNE of Blaine, WA",
+		Content:    "2025-10-16T22:00:00Z",
+		Categories: []string{"earthquake", "felt"},
+		Extensions: georss("49.1032 -122.6891", "-18420"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := item.Entry()
+	if entry.Elevation != -18420 {
+		t.Errorf("Elevation = %d, want -18420", entry.Elevation)
+	}
+	if entry.Categories != "earthquake, felt" {
+		t.Errorf("Categories = %q", entry.Categories)
+	}
+	if entry.MagnitudeType != "ML" || entry.Magnitude != 2.6 {
+		t.Errorf("Magnitude = %v %s, want 2.6 ML", entry.Magnitude, entry.MagnitudeType)
+	}
+	if entry.Time == nil || !entry.Time.Equal(item.Time) {
+		t.Errorf("Time = %v, want %v", entry.Time, item.Time)
+	}
+}
@@ -0,0 +1,111 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+)
+
+// LngRange is a span of longitudes, West <= East, within [-180, 180].
+type LngRange struct {
+	West float64
+	East float64
+}
+
+// Box is a normalized bounding box. A box crossing the antimeridian has two
+// longitude ranges, one either side of it.
+type Box struct {
+	SWLat float64
+	NELat float64
+	Lngs  []LngRange
+}
+
+// BoxError describes a bounding box value that can't be used.
+type BoxError struct {
+	Field  string
+	Value  float64
+	Reason string
+}
+
+func (e *BoxError) Error() string {
+	return fmt.Sprintf("%s: %s, got %g", e.Field, e.Reason, e.Value)
+}
+
+// NewBox normalizes a box as drawn on a web map. Maps that wrap give
+// longitudes past ±180, and a box whose swlng is greater than its nelng
+// crosses the antimeridian; both are wrapped into [-180, 180] and split in
+// two where needed. Latitudes past the poles are clamped.
+func NewBox(swlng, swlat, nelng, nelat float64) (Box, error) {
+	for _, v := range []struct {
+		field string
+		value float64
+	}{{"swlng", swlng}, {"swlat", swlat}, {"nelng", nelng}, {"nelat", nelat}} {
+		if math.IsNaN(v.value) || math.IsInf(v.value, 0) {
+			return Box{}, &BoxError{Field: v.field, Value: v.value, Reason: "must be a finite number"}
+		}
+	}
+
+	if swlat > 90 {
+		return Box{}, &BoxError{Field: "swlat", Value: swlat, Reason: "must not be north of the north pole"}
+	}
+	if nelat < -90 {
+		return Box{}, &BoxError{Field: "nelat", Value: nelat, Reason: "must not be south of the south pole"}
+	}
+	if swlat > nelat {
+		return Box{}, &BoxError{Field: "swlat", Value: swlat, Reason: fmt.Sprintf("must not be north of nelat %g", nelat)}
+	}
+
+	box := Box{
+		SWLat: math.Max(-90, swlat),
+		NELat: math.Min(90, nelat),
+	}
+
+	width := nelng - swlng
+	if width < 0 {
+		// crosses the antimeridian
+		width += 360
+	}
+	if width >= 360 {
+		box.Lngs = []LngRange{{-180, 180}}
+		return box, nil
+	}
+
+	west := wrapLng(swlng)
+	east := west + width
+	if east <= 180 {
+		box.Lngs = []LngRange{{west, east}}
+		return box, nil
+	}
+
+	box.Lngs = []LngRange{{west, 180}, {-180, east - 360}}
+	return box, nil
+}
+
+// World is the box covering the whole globe.
+func World() Box {
+	return Box{SWLat: -90, NELat: 90, Lngs: []LngRange{{-180, 180}}}
+}
+
+// Contains reports whether the point lat, lng is within the box, edges
+// included.
+func (b Box) Contains(lat, lng float64) bool {
+	if lat < b.SWLat || lat > b.NELat {
+		return false
+	}
+
+	for _, r := range b.Lngs {
+		if lng >= r.West && lng <= r.East {
+			return true
+		}
+	}
+
+	return false
+}
+
+// wrapLng wraps a longitude into (-180, 180].
+func wrapLng(lng float64) float64 {
+	lng = math.Mod(lng+180, 360)
+	if lng <= 0 {
+		lng += 360
+	}
+	return lng - 180
+}
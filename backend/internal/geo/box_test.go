@@ -0,0 +1,130 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNewBox(t *testing.T) {
+	tests := []struct {
+		name                       string
+		swlng, swlat, nelng, nelat float64
+		want                       Box
+	}{
+		{
+			name:  "inside the map",
+			swlng: -130, swlat: 40, nelng: -70, nelat: 55,
+			want: Box{SWLat: 40, NELat: 55, Lngs: []LngRange{{-130, -70}}},
+		},
+		{
+			name:  "whole world",
+			swlng: -180, swlat: -90, nelng: 180, nelat: 90,
+			want: World(),
+		},
+		{
+			name:  "sw east of ne crosses the antimeridian",
+			swlng: 170, swlat: 50, nelng: -160, nelat: 60,
+			want: Box{SWLat: 50, NELat: 60, Lngs: []LngRange{{170, 180}, {-180, -160}}},
+		},
+		{
+			name:  "map wrapped west",
+			swlng: -190, swlat: 50, nelng: -160, nelat: 60,
+			want: Box{SWLat: 50, NELat: 60, Lngs: []LngRange{{170, 180}, {-180, -160}}},
+		},
+		{
+			name:  "map wrapped east",
+			swlng: 170, swlat: 50, nelng: 200, nelat: 60,
+			want: Box{SWLat: 50, NELat: 60, Lngs: []LngRange{{170, 180}, {-180, -160}}},
+		},
+		{
+			name:  "panned a whole world west",
+			swlng: -430, swlat: 40, nelng: -370, nelat: 55,
+			want: Box{SWLat: 40, NELat: 55, Lngs: []LngRange{{-70, -10}}},
+		},
+		{
+			name:  "wider than the world",
+			swlng: -300, swlat: -10, nelng: 200, nelat: 10,
+			want: Box{SWLat: -10, NELat: 10, Lngs: []LngRange{{-180, 180}}},
+		},
+		{
+			name:  "ends on the antimeridian",
+			swlng: 170, swlat: 0, nelng: 180, nelat: 10,
+			want: Box{SWLat: 0, NELat: 10, Lngs: []LngRange{{170, 180}}},
+		},
+		{
+			name:  "latitudes clamped",
+			swlng: -10, swlat: -120, nelng: 10, nelat: 95,
+			want: Box{SWLat: -90, NELat: 90, Lngs: []LngRange{{-10, 10}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewBox(tt.swlng, tt.swlat, tt.nelng, tt.nelat)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewBoxErrors(t *testing.T) {
+	tests := []struct {
+		name                       string
+		swlng, swlat, nelng, nelat float64
+		field                      string
+	}{
+		{"sw north of ne", -10, 50, 10, 40, "swlat"},
+		{"north of the pole", -10, 91, 10, 95, "swlat"},
+		{"south of the pole", -10, -95, 10, -91, "nelat"},
+		{"not a number", math.NaN(), 0, 10, 10, "swlng"},
+		{"infinite", -10, 0, math.Inf(1), 10, "nelng"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewBox(tt.swlng, tt.swlat, tt.nelng, tt.nelat)
+
+			var boxErr *BoxError
+			if !errors.As(err, &boxErr) {
+				t.Fatalf("got %v, want a BoxError", err)
+			}
+			if boxErr.Field != tt.field {
+				t.Errorf("got field %q, want %q", boxErr.Field, tt.field)
+			}
+		})
+	}
+}
+
+func TestBoxContains(t *testing.T) {
+	across, err := NewBox(170, -50, -170, -30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		box      Box
+		lat, lng float64
+		want     bool
+	}{
+		{across, -40, 175, true},
+		{across, -40, -175, true},
+		{across, -40, 180, true},
+		{across, -40, 0, false},
+		{across, -20, 175, false},
+		{across, -50, 170, true},
+		{World(), 90, -180, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.box.Contains(tt.lat, tt.lng); got != tt.want {
+			t.Errorf("%+v contains %g, %g: got %t, want %t", tt.box, tt.lat, tt.lng, got, tt.want)
+		}
+	}
+}
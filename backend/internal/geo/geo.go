@@ -0,0 +1,85 @@
+// Package geo has the spherical geometry used to search for events.
+package geo
+
+import "math"
+
+// EarthRadiusKm is the mean radius of the earth.
+const EarthRadiusKm = 6371.0088
+
+// kmPerDegree is the length of one degree of latitude.
+const kmPerDegree = EarthRadiusKm * math.Pi / 180
+
+// DistanceKm returns the great-circle distance between two points using the
+// haversine formula.
+func DistanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	φ1 := radians(lat1)
+	φ2 := radians(lat2)
+	Δφ := radians(lat2 - lat1)
+	Δλ := radians(lng2 - lng1)
+
+	a := math.Sin(Δφ/2)*math.Sin(Δφ/2) + math.Cos(φ1)*math.Cos(φ2)*math.Sin(Δλ/2)*math.Sin(Δλ/2)
+
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// BoundsAround returns a latitude/longitude box containing every point within
+// radiusKm of lat, lng. Near the poles the box covers every longitude. The
+// longitudes are not wrapped, so swlng may be below -180 or nelng above 180
+// when the circle crosses the antimeridian.
+func BoundsAround(lat, lng, radiusKm float64) (swlat, nelat, swlng, nelng float64) {
+	Δlat := radiusKm / kmPerDegree
+
+	swlat = math.Max(-90, lat-Δlat)
+	nelat = math.Min(90, lat+Δlat)
+
+	// the circle reaches a pole, so it spans every meridian
+	if swlat == -90 || nelat == 90 {
+		return swlat, nelat, -180, 180
+	}
+
+	// the widest part of the circle in longitude is where its edge is
+	// tangent to a meridian
+	sinΔlng := math.Sin(radiusKm/EarthRadiusKm) / math.Cos(radians(lat))
+	if sinΔlng >= 1 {
+		return swlat, nelat, -180, 180
+	}
+
+	Δlng := degrees(math.Asin(sinΔlng))
+
+	return swlat, nelat, lng - Δlng, lng + Δlng
+}
+
+// Cell returns the cell of a grid of roughly sizeKm squares that contains
+// lat, lng, as its row and column and its bounds. Rows are sizeKm of latitude
+// tall. Each row is split into columns sizeKm wide at its middle latitude, so
+// cells keep their size away from the equator; the last column of a row may
+// be narrower.
+func Cell(lat, lng, sizeKm float64) (row, col int, swlat, nelat, swlng, nelng float64) {
+	Δlat := sizeKm / kmPerDegree
+
+	// the pole and the antimeridian at 180 fall in the last row and column
+	rows := int(math.Ceil(180 / Δlat))
+	row = min(int(math.Floor((lat+90)/Δlat)), rows-1)
+	swlat = -90 + float64(row)*Δlat
+	nelat = math.Min(90, swlat+Δlat)
+
+	Δlng := 360.0
+	if cos := math.Cos(radians((swlat + nelat) / 2)); Δlat < 360*cos {
+		Δlng = Δlat / cos
+	}
+
+	cols := int(math.Ceil(360 / Δlng))
+	col = min(int(math.Floor((lng+180)/Δlng)), cols-1)
+	swlng = -180 + float64(col)*Δlng
+	nelng = math.Min(180, swlng+Δlng)
+
+	return row, col, swlat, nelat, swlng, nelng
+}
+
+func radians(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+func degrees(r float64) float64 {
+	return r * 180 / math.Pi
+}
@@ -0,0 +1,112 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceKm(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		want                   float64
+	}{
+		{"same point", 45.4215, -75.6972, 45.4215, -75.6972, 0},
+		{"ottawa to montreal", 45.4215, -75.6972, 45.5019, -73.5674, 166.4},
+		{"one degree of latitude", 0, 0, 1, 0, 111.2},
+		{"across the antimeridian", 52, 179.5, 52, -179.5, 68.5},
+		{"antipodes", 0, 0, 0, 180, 20015.1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DistanceKm(tt.lat1, tt.lng1, tt.lat2, tt.lng2)
+			if math.Abs(got-tt.want) > 0.1 {
+				t.Errorf("DistanceKm = %.1f, want %.1f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBoundsAround(t *testing.T) {
+	tests := []struct {
+		name               string
+		lat, lng, radiusKm float64
+		swlat, nelat       float64
+		swlng, nelng       float64
+	}{
+		{"equator", 0, 0, 111.195, -1, 1, -1, 1},
+		{"ottawa", 45.4215, -75.6972, 150, 44.0725, 46.7705, -77.6193, -73.7751},
+		{"reaches the pole", 89.5, 0, 100, 88.6007, 90, -180, 180},
+		{"crosses the antimeridian", 52, 179.5, 100, 51.1007, 52.8993, 178.0393, 180.9607},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			swlat, nelat, swlng, nelng := BoundsAround(tt.lat, tt.lng, tt.radiusKm)
+
+			got := []float64{swlat, nelat, swlng, nelng}
+			want := []float64{tt.swlat, tt.nelat, tt.swlng, tt.nelng}
+			for i := range got {
+				if math.Abs(got[i]-want[i]) > 0.001 {
+					t.Fatalf("BoundsAround = %.4f, want %.4f", got, want)
+				}
+			}
+		})
+	}
+}
+
+// Every point on the circle must fall inside the box.
+func TestBoundsAroundContainsCircle(t *testing.T) {
+	lat, lng, radiusKm := 60.0, -135.0, 500.0
+	swlat, nelat, swlng, nelng := BoundsAround(lat, lng, radiusKm)
+
+	for bearing := 0.0; bearing < 360; bearing += 1 {
+		plat, plng := destination(lat, lng, bearing, radiusKm)
+		if plat < swlat-1e-9 || plat > nelat+1e-9 || plng < swlng-1e-9 || plng > nelng+1e-9 {
+			t.Fatalf("point %.4f, %.4f at bearing %.0f is outside %.4f", plat, plng, bearing, []float64{swlat, nelat, swlng, nelng})
+		}
+	}
+}
+
+func destination(lat, lng, bearing, distanceKm float64) (float64, float64) {
+	δ := distanceKm / EarthRadiusKm
+	θ := radians(bearing)
+	φ1 := radians(lat)
+	λ1 := radians(lng)
+
+	φ2 := math.Asin(math.Sin(φ1)*math.Cos(δ) + math.Cos(φ1)*math.Sin(δ)*math.Cos(θ))
+	λ2 := λ1 + math.Atan2(math.Sin(θ)*math.Sin(δ)*math.Cos(φ1), math.Cos(δ)-math.Sin(φ1)*math.Sin(φ2))
+
+	return degrees(φ2), degrees(λ2)
+}
+
+func TestCell(t *testing.T) {
+	for _, p := range [][2]float64{{45.5, -73.6}, {0, 0}, {-33.9, 151.2}, {89.99, 10}, {90, 180}, {-90, -180}, {64.1, -21.9}} {
+		row, col, swlat, nelat, swlng, nelng := Cell(p[0], p[1], 50)
+		if p[0] < swlat || p[0] > nelat || p[1] < swlng || p[1] > nelng {
+			t.Errorf("%v: cell %d,%d %v doesn't contain the point", p, row, col, []float64{swlat, nelat, swlng, nelng})
+		}
+
+		height := DistanceKm(swlat, swlng, nelat, swlng)
+		if nelat < 90 && math.Abs(height-50) > 1e-6 {
+			t.Errorf("%v: got a cell %.3f km tall, want 50", p, height)
+		}
+		mid := (swlat + nelat) / 2
+		// near the poles the great circle between the corners leaves the parallel
+		if width := DistanceKm(mid, swlng, mid, nelng); nelng < 180 && math.Abs(mid) < 80 && math.Abs(width-50) > 0.5 {
+			t.Errorf("%v: got a cell %.3f km wide, want 50", p, width)
+		}
+	}
+
+	// nearby points share a cell until they cross its edge
+	row1, col1, _, _, _, nelng := Cell(45.5, -73.6, 50)
+	row2, col2, _, _, _, _ := Cell(45.5, -73.59, 50)
+	row3, col3, _, _, _, _ := Cell(45.5, nelng+0.01, 50)
+	if row1 != row2 || col1 != col2 {
+		t.Errorf("got cells %d,%d and %d,%d for nearby points", row1, col1, row2, col2)
+	}
+	if row3 != row1 || col3 != col1+1 {
+		t.Errorf("got cell %d,%d east of %d,%d", row3, col3, row1, col1)
+	}
+}
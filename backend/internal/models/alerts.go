@@ -0,0 +1,324 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/earthquake-service/internal/geo"
+)
+
+// Kinds of alert rule.
+const (
+	// RuleMagnitude fires for each event of at least MinMagnitude in the
+	// region.
+	RuleMagnitude = "magnitude"
+	// RuleCount fires when MinCount events happen within Window in one cell
+	// of a CellKm grid.
+	RuleCount = "count"
+)
+
+// AlertRule describes the events that fire an alert. Both kinds only
+// consider events in Region of at least MinMagnitude, when it is set.
+type AlertRule struct {
+	ID           int64
+	Name         string
+	Kind         string
+	Region       Region
+	MinMagnitude *float64
+	MinCount     int
+	Window       time.Duration
+	CellKm       float64
+	CreatedAt    time.Time
+}
+
+// Matches reports whether e is an event the rule considers.
+func (r AlertRule) Matches(e Entry) bool {
+	if r.MinMagnitude != nil && !e.MagnitudeAtLeast(*r.MinMagnitude) {
+		return false
+	}
+	return r.Region.Contains(float64(e.Latitude), float64(e.Longitude))
+}
+
+// Cell returns the cell of a count rule's grid containing e, as a key and
+// the query selecting the events in it.
+func (r AlertRule) Cell(e Entry) (string, *EntryQuery) {
+	row, col, swlat, nelat, swlng, nelng := geo.Cell(float64(e.Latitude), float64(e.Longitude), r.CellKm)
+
+	return fmt.Sprintf("%d:%d", row, col), NewEntryQuery().WithinBounds(swlat, nelat, swlng, nelng)
+}
+
+// Alert is a firing of a rule. A magnitude rule fires for the event GUID and
+// is cleared when a revision takes the event out of the rule. A count rule
+// fires for the event GUID that brought Count events to Cell.
+type Alert struct {
+	ID        int64
+	RuleID    int64
+	RuleName  string
+	Kind      string
+	GUID      string
+	Magnitude float64
+	EventTime *time.Time
+	Cell      string
+	Count     int
+	FiredAt   time.Time
+	ClearedAt *time.Time
+}
+
+// AlertQuery selects alerts. Zero fields don't filter.
+type AlertQuery struct {
+	RuleID int64
+	Since  time.Time
+}
+
+type AlertModel struct {
+	DB *sql.DB
+	// MaxRules, when set, caps the number of rules, since each of them is
+	// evaluated on every change
+	MaxRules int
+}
+
+const ruleColumns = `id, name, kind, ` + regionColumns + `, min_magnitude, min_count, window_seconds, cell_km, created_at`
+
+func scanRule(row scanner) (r AlertRule, err error) {
+	var region regionScanner
+	var minCount, windowSeconds sql.NullInt64
+	var cellKm sql.NullFloat64
+
+	dest := []any{&r.ID, &r.Name, &r.Kind}
+	dest = append(dest, region.dest()...)
+	dest = append(dest, &r.MinMagnitude, &minCount, &windowSeconds, &cellKm, &r.CreatedAt)
+
+	if err := row.Scan(dest...); err != nil {
+		return r, err
+	}
+
+	r.Region = region.region()
+	r.MinCount = int(minCount.Int64)
+	r.Window = time.Duration(windowSeconds.Int64) * time.Second
+	r.CellKm = cellKm.Float64
+	return r, nil
+}
+
+// InsertRule stores a new rule and returns its id, or ErrLimit when there
+// are MaxRules rules already.
+func (m *AlertModel) InsertRule(r AlertRule) (int64, error) {
+	var minCount, windowSeconds, cellKm any
+	if r.Kind == RuleCount {
+		minCount, windowSeconds, cellKm = r.MinCount, int64(r.Window/time.Second), r.CellKm
+	}
+
+	args := []any{r.Name, r.Kind}
+	args = append(args, regionArgs(r.Region)...)
+	args = append(args, r.MinMagnitude, minCount, windowSeconds, cellKm, time.Now().UTC())
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if m.MaxRules > 0 {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM alert_rules`).Scan(&count); err != nil {
+			return 0, err
+		}
+		if count >= m.MaxRules {
+			return 0, ErrLimit
+		}
+	}
+
+	result, err := tx.Exec(`INSERT INTO alert_rules (
+		name, kind, `+regionColumns+`, min_magnitude, min_count, window_seconds, cell_km, created_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+func (m *AlertModel) GetRule(id int64) (AlertRule, error) {
+	r, err := scanRule(m.DB.QueryRow(`SELECT `+ruleColumns+` FROM alert_rules WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return r, ErrNoRecord
+	}
+	return r, err
+}
+
+// Rules returns every rule, oldest first.
+func (m *AlertModel) Rules() (rules []AlertRule, err error) {
+	rows, err := m.DB.Query(`SELECT ` + ruleColumns + ` FROM alert_rules ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, rows.Err()
+}
+
+// DeleteRule removes a rule and the alerts it fired.
+func (m *AlertModel) DeleteRule(id int64) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM alert_rules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if err := requireRow(result); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM alerts WHERE rule_id = ?`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Fire stores a new alert and returns its id.
+func (m *AlertModel) Fire(a Alert) (int64, error) {
+	var cell, count any
+	if a.Cell != "" {
+		cell, count = a.Cell, a.Count
+	}
+
+	result, err := m.DB.Exec(`INSERT INTO alerts (
+		rule_id,
+		guid,
+		magnitude,
+		event_time,
+		cell,
+		count,
+		fired_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		a.RuleID,
+		a.GUID,
+		a.Magnitude,
+		a.EventTime,
+		cell,
+		count,
+		a.FiredAt.UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// Clear marks an alert as no longer holding.
+func (m *AlertModel) Clear(id int64, at time.Time) error {
+	result, err := m.DB.Exec(`UPDATE alerts SET cleared_at = ? WHERE id = ?`, at.UTC(), id)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+const alertColumns = `
+	alerts.id,
+	alerts.rule_id,
+	alert_rules.name,
+	alert_rules.kind,
+	alerts.guid,
+	alerts.magnitude,
+	alerts.event_time,
+	COALESCE(alerts.cell, ''),
+	COALESCE(alerts.count, 0),
+	alerts.fired_at,
+	alerts.cleared_at`
+
+func scanAlert(row scanner) (a Alert, err error) {
+	err = row.Scan(&a.ID, &a.RuleID, &a.RuleName, &a.Kind, &a.GUID, &a.Magnitude, &a.EventTime, &a.Cell, &a.Count, &a.FiredAt, &a.ClearedAt)
+	return a, err
+}
+
+// latest returns the newest alert of a rule matching condition.
+func (m *AlertModel) latest(ruleID int64, condition string, arg any) (Alert, error) {
+	a, err := scanAlert(m.DB.QueryRow(`SELECT `+alertColumns+`
+		FROM alerts JOIN alert_rules ON alert_rules.id = alerts.rule_id
+		WHERE alerts.rule_id = ? AND `+condition+`
+		ORDER BY alerts.id DESC
+		LIMIT 1`, ruleID, arg))
+	if errors.Is(err, sql.ErrNoRows) {
+		return a, ErrNoRecord
+	}
+	return a, err
+}
+
+// LatestForEvent returns the newest alert a rule fired for the event guid.
+func (m *AlertModel) LatestForEvent(ruleID int64, guid string) (Alert, error) {
+	return m.latest(ruleID, "alerts.guid = ?", guid)
+}
+
+// LatestForCell returns the newest alert a count rule fired for cell.
+func (m *AlertModel) LatestForCell(ruleID int64, cell string) (Alert, error) {
+	return m.latest(ruleID, "alerts.cell = ?", cell)
+}
+
+func (m *AlertModel) Get(id int64) (Alert, error) {
+	a, err := scanAlert(m.DB.QueryRow(`SELECT `+alertColumns+`
+		FROM alerts JOIN alert_rules ON alert_rules.id = alerts.rule_id
+		WHERE alerts.id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return a, ErrNoRecord
+	}
+	return a, err
+}
+
+// List returns the alerts selected by q newest first and their total number.
+func (m *AlertModel) List(q AlertQuery, limit, offset int) (alerts []Alert, total int, err error) {
+	where := " WHERE 1 = 1"
+	var args []any
+	if q.RuleID != 0 {
+		where += " AND alerts.rule_id = ?"
+		args = append(args, q.RuleID)
+	}
+	if !q.Since.IsZero() {
+		where += " AND alerts.fired_at >= ?"
+		args = append(args, q.Since.UTC())
+	}
+
+	err = m.DB.QueryRow(`SELECT COUNT(*) FROM alerts JOIN alert_rules ON alert_rules.id = alerts.rule_id`+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := m.DB.Query(`SELECT `+alertColumns+`
+		FROM alerts JOIN alert_rules ON alert_rules.id = alerts.rule_id`+where+`
+		ORDER BY alerts.id DESC
+		LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		alerts = append(alerts, a)
+	}
+
+	return alerts, total, rows.Err()
+}
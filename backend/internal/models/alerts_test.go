@@ -0,0 +1,94 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAlertModel(t *testing.T) {
+	m := &AlertModel{DB: newTestDB(t)}
+
+	minMag := 4.0
+	magnitudeID, err := m.InsertRule(AlertRule{
+		Name:         "Montreal",
+		Kind:         RuleMagnitude,
+		Region:       Region{Circle: &Circle{45.5, -73.6, 200}},
+		MinMagnitude: &minMag,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	countID, err := m.InsertRule(AlertRule{Name: "Swarms", Kind: RuleCount, MinCount: 5, Window: 24 * time.Hour, CellKm: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := m.Rules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].Region.Circle == nil || *rules[0].MinMagnitude != 4 || rules[0].MinCount != 0 {
+		t.Fatalf("got %+v", rules)
+	}
+	if r := rules[1]; r.Kind != RuleCount || r.MinCount != 5 || r.Window != 24*time.Hour || r.CellKm != 50 || r.MinMagnitude != nil {
+		t.Errorf("got %+v", r)
+	}
+
+	if key, _ := rules[1].Cell(Entry{Latitude: 45.5, Longitude: -73.6}); key != "301:165" {
+		t.Errorf("got cell %s", key)
+	}
+
+	at := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	fired := at.Add(time.Minute)
+	id, err := m.Fire(Alert{RuleID: magnitudeID, GUID: "a", Magnitude: 4.2, EventTime: &at, FiredAt: fired})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Fire(Alert{RuleID: countID, GUID: "b", Magnitude: 2, EventTime: &at, Cell: "301:165", Count: 5, FiredAt: fired}); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := m.LatestForEvent(magnitudeID, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.ID != id || a.RuleName != "Montreal" || a.Kind != RuleMagnitude || a.Magnitude != 4.2 || !a.EventTime.Equal(at) || !a.FiredAt.Equal(fired) || a.ClearedAt != nil {
+		t.Errorf("got %+v", a)
+	}
+	if _, err := m.LatestForEvent(countID, "a"); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("got %v, want ErrNoRecord", err)
+	}
+	if a, err := m.LatestForCell(countID, "301:165"); err != nil || a.GUID != "b" || a.Count != 5 {
+		t.Errorf("got %+v, %v", a, err)
+	}
+
+	if err := m.Clear(id, fired.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := m.Get(id); a.ClearedAt == nil || !a.ClearedAt.Equal(fired.Add(time.Hour)) {
+		t.Errorf("got %+v after clear", a)
+	}
+
+	alerts, total, err := m.List(AlertQuery{}, 1, 0)
+	if err != nil || total != 2 || len(alerts) != 1 || alerts[0].GUID != "b" {
+		t.Errorf("got %+v of %d, %v", alerts, total, err)
+	}
+	alerts, total, err = m.List(AlertQuery{RuleID: magnitudeID, Since: at}, 10, 0)
+	if err != nil || total != 1 || alerts[0].GUID != "a" {
+		t.Errorf("got %+v of %d, %v", alerts, total, err)
+	}
+	if _, total, _ := m.List(AlertQuery{Since: fired.Add(time.Second)}, 10, 0); total != 0 {
+		t.Errorf("got %d alerts fired later, want 0", total)
+	}
+
+	if err := m.DeleteRule(magnitudeID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get(id); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("got %v for an alert of a deleted rule, want ErrNoRecord", err)
+	}
+	if err := m.DeleteRule(magnitudeID); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("got %v, want ErrNoRecord", err)
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+// EntryChange is an entry as stored by a change to it.
+type EntryChange struct {
+	Entry  Entry
+	Change Change
+}
+
+// ChangesSince returns up to limit changes after the change changeID, in the
+// order they were made. An entry changed more than once is returned once, at
+// its latest change, which is an update when it has any revisions.
+func (m *EntryModel) ChangesSince(changeID int64, limit int) (changes []EntryChange, err error) {
+	stmt := `SELECT ` + entryColumns + `,
+			EXISTS (SELECT 1 FROM entry_revisions WHERE entry_revisions.guid = entries.guid)
+		FROM entries
+		WHERE change_id > ?
+		ORDER BY change_id
+		LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, changeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var revised bool
+		e, err := scanEntry(rows, &revised)
+		if err != nil {
+			return nil, err
+		}
+
+		c := EntryChange{Entry: e, Change: ChangeCreated}
+		if revised {
+			c.Change = ChangeUpdated
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
+
+// LastChangeID returns the ChangeID of the latest change, or 0 before any.
+func (m *EntryModel) LastChangeID() (changeID int64, err error) {
+	err = m.DB.QueryRow(`SELECT COALESCE(MAX(change_id), 0) FROM entries`).Scan(&changeID)
+	return changeID, err
+}
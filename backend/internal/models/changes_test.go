@@ -0,0 +1,120 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEntryModelChanges(t *testing.T) {
+	type notice struct {
+		guid     string
+		changeID int64
+		change   Change
+	}
+	var notices []notice
+
+	m := &EntryModel{DB: newTestDB(t)}
+	m.OnChange = func(e Entry, change Change) {
+		notices = append(notices, notice{e.GUID, e.ChangeID, change})
+	}
+
+	at := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	for _, e := range []Entry{
+		{GUID: "a", Magnitude: 1.2, Time: &at},
+		{GUID: "b", Magnitude: 2.6, Time: &at},
+		{GUID: "a", Magnitude: 1.2, Time: &at},
+		{GUID: "a", Magnitude: 1.5, Time: &at},
+	} {
+		if _, err := m.Insert(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []notice{{"a", 1, ChangeCreated}, {"b", 2, ChangeCreated}, {"a", 3, ChangeUpdated}}
+	if len(notices) != len(want) {
+		t.Fatalf("got %+v, want %+v", notices, want)
+	}
+	for i := range want {
+		if notices[i] != want[i] {
+			t.Errorf("notice %d: got %+v, want %+v", i, notices[i], want[i])
+		}
+	}
+
+	last, err := m.LastChangeID()
+	if err != nil || last != 3 {
+		t.Errorf("got last change %d, %v, want 3", last, err)
+	}
+
+	changes, err := m.ChangesSince(1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("got %+v, want b then the update of a", changes)
+	}
+	if b := changes[0]; b.Entry.GUID != "b" || b.Change != ChangeCreated {
+		t.Errorf("got %s %s, want b created", b.Entry.GUID, b.Change)
+	}
+	if a := changes[1]; a.Entry.GUID != "a" || a.Entry.Magnitude != 1.5 || a.Change != ChangeUpdated {
+		t.Errorf("got %s %s at M%g, want a updated to M1.5", a.Entry.GUID, a.Change, a.Entry.Magnitude)
+	}
+
+	changes, err = m.ChangesSince(0, 1)
+	if err != nil || len(changes) != 1 || changes[0].Entry.GUID != "b" {
+		t.Errorf("got %+v, %v, want b", changes, err)
+	}
+}
+
+func TestEntryModelChangesInOrder(t *testing.T) {
+	// concurrent writers need a file shared by the connections, locked as
+	// the server opens it
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "quakes.sqlite3")+
+		"?_pragma=busy_timeout(5000)&_txlock=immediate&_pragma=journal_mode(WAL)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	schema, err := os.ReadFile("../../cmd/web/schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatal(err)
+	}
+
+	var changeIDs []int64
+	m := &EntryModel{DB: db}
+	m.OnChange = func(e Entry, change Change) {
+		changeIDs = append(changeIDs, e.ChangeID)
+	}
+
+	at := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 25 {
+				if _, err := m.Insert(Entry{GUID: fmt.Sprintf("%d-%d", w, i), Magnitude: 2, Time: &at}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(changeIDs) != 100 {
+		t.Fatalf("got %d changes, want 100", len(changeIDs))
+	}
+	for i, id := range changeIDs {
+		if id != int64(i+1) {
+			t.Fatalf("got change %d as the %dth, want the changes in order", id, i+1)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// storedTimeLayout is how the driver writes time.Time values, which is the
+// layout of time.Time.String.
+const storedTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// Cluster summarizes the entries within one cell of a latitude/longitude
+// grid.
+type Cluster struct {
+	// Column and Row number the cell eastward from -180 and northward from
+	// -90.
+	Column       int
+	Row          int
+	Count        int
+	MaxMagnitude float32
+	Latest       *time.Time
+	// Latitude and Longitude are the centroid of the cell's entries.
+	Latitude  float64
+	Longitude float64
+}
+
+// Clusters groups the entries matching q into square cells of cellDeg
+// degrees. Cells without entries are left out.
+func (m *EntryModel) Clusters(q *EntryQuery, cellDeg float64) (clusters []Cluster, err error) {
+	if cellDeg <= 0 || math.IsNaN(cellDeg) {
+		return nil, fmt.Errorf("cell size must be positive, got %g", cellDeg)
+	}
+
+	where, args := q.whereClause()
+
+	stmt := `
+		SELECT
+			CAST((longitude + 180) / ? AS INTEGER) AS col,
+			CAST((latitude + 90) / ? AS INTEGER) AS row,
+			COUNT(*),
+			MAX(magnitude),
+			MAX(time),
+			AVG(latitude),
+			AVG(longitude)
+		FROM entries` + where + `
+		GROUP BY col, row
+		ORDER BY row, col
+	`
+	args = append([]any{cellDeg, cellDeg}, args...)
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c Cluster
+		var latest *string
+
+		err := rows.Scan(&c.Column, &c.Row, &c.Count, &c.MaxMagnitude, &latest, &c.Latitude, &c.Longitude)
+		if err != nil {
+			return nil, err
+		}
+
+		// aggregates lose the column type, so the time comes back as text
+		if latest != nil {
+			t, err := time.Parse(storedTimeLayout, *latest)
+			if err != nil {
+				return nil, fmt.Errorf("reading cluster time: %w", err)
+			}
+			t = t.UTC()
+			c.Latest = &t
+		}
+
+		clusters = append(clusters, c)
+	}
+
+	return clusters, rows.Err()
+}
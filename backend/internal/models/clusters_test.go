@@ -0,0 +1,58 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEntryModelClusters(t *testing.T) {
+	m := &EntryModel{DB: newTestDB(t)}
+
+	base := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	for i, e := range []Entry{
+		{GUID: "a", Latitude: 49.1, Longitude: -122.7, Magnitude: 2.6},
+		{GUID: "b", Latitude: 48.4, Longitude: -123.4, Magnitude: 1.1},
+		{GUID: "c", Latitude: 45.5, Longitude: -73.6, Magnitude: 1.2},
+		{GUID: "d", Latitude: 53.9, Longitude: -167.1, Magnitude: 4.3},
+	} {
+		at := base.Add(time.Duration(i) * 90 * time.Minute)
+		e.Time = &at
+		if _, err := m.Insert(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clusters, err := m.Clusters(NewEntryQuery().WithinBounds(40, 60, -130, -70), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(clusters) != 2 {
+		t.Fatalf("got %d clusters, want 2: %+v", len(clusters), clusters)
+	}
+
+	// -130..-120, 40..50 holds a and b
+	c := clusters[0]
+	if c.Column != 5 || c.Row != 13 || c.Count != 2 || c.MaxMagnitude != 2.6 {
+		t.Errorf("got %+v", c)
+	}
+	if math.Abs(c.Latitude-48.75) > 1e-4 || math.Abs(c.Longitude-(-123.05)) > 1e-4 {
+		t.Errorf("got centroid %g, %g", c.Latitude, c.Longitude)
+	}
+	if want := base.Add(90 * time.Minute); c.Latest == nil || !c.Latest.Equal(want) {
+		t.Errorf("got latest %v, want %v", c.Latest, want)
+	}
+
+	if c := clusters[1]; c.Count != 1 || c.Column != 10 || c.Row != 13 {
+		t.Errorf("got %+v", c)
+	}
+
+	clusters, err = m.Clusters(NewEntryQuery().MinMagnitude(4), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 1 || clusters[0].Count != 1 || clusters[0].MaxMagnitude != 4.3 {
+		t.Errorf("got %+v, want only d", clusters)
+	}
+}
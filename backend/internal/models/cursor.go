@@ -0,0 +1,95 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks where the next page of a query starts. Pages ordered by time
+// continue after the (time, id) of the last entry, which stays correct as
+// new entries arrive. Pages ordered by distance have no such key and
+// continue from an offset instead.
+type Cursor struct {
+	Time   time.Time
+	ID     int64
+	Offset int
+}
+
+// String encodes the cursor as an opaque URL-safe token.
+func (c Cursor) String() string {
+	var v string
+	if c.Offset > 0 {
+		v = "o:" + strconv.Itoa(c.Offset)
+	} else {
+		v = fmt.Sprintf("k:%d:%d", c.Time.UnixNano(), c.ID)
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(v))
+}
+
+// ParseCursor decodes a token made by Cursor.String.
+func ParseCursor(token string) (Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(b), ":")
+	switch {
+	case len(parts) == 2 && parts[0] == "o":
+		offset, err := strconv.Atoi(parts[1])
+		if err != nil || offset < 1 {
+			return Cursor{}, ErrInvalidCursor
+		}
+		return Cursor{Offset: offset}, nil
+	case len(parts) == 3 && parts[0] == "k":
+		nanos, errTime := strconv.ParseInt(parts[1], 10, 64)
+		id, errID := strconv.ParseInt(parts[2], 10, 64)
+		if errTime != nil || errID != nil {
+			return Cursor{}, ErrInvalidCursor
+		}
+		return Cursor{Time: time.Unix(0, nanos).UTC(), ID: id}, nil
+	default:
+		return Cursor{}, ErrInvalidCursor
+	}
+}
+
+// keyset reports whether the cursor continues after a (time, id) key.
+func (c Cursor) keyset() bool {
+	return c.Offset == 0
+}
+
+// Page returns up to the query's limit of entries and the cursor of the page
+// after them, which is nil on the last page. The query needs a limit.
+func (m *EntryModel) Page(q *EntryQuery) (entries []Entry, next *Cursor, err error) {
+	// one extra entry tells whether there is another page
+	probe := *q
+	probe.limit = q.limit + 1
+
+	entries, err = m.Query(&probe)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(entries) <= q.limit {
+		return entries, nil, nil
+	}
+	entries = entries[:q.limit]
+
+	if q.byDistance && q.near != nil {
+		return entries, &Cursor{Offset: q.offset + len(entries)}, nil
+	}
+
+	last := entries[len(entries)-1]
+	if last.Time == nil {
+		// entries without a time sort last, so there is no key to continue from
+		return entries, nil, nil
+	}
+
+	return entries, &Cursor{Time: *last.Time, ID: last.ID}, nil
+}
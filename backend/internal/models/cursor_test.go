@@ -0,0 +1,100 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCursorString(t *testing.T) {
+	tests := []Cursor{
+		{Time: time.Date(2025, 10, 16, 22, 0, 0, 500000000, time.UTC), ID: 42},
+		{Offset: 100},
+	}
+
+	for _, want := range tests {
+		got, err := ParseCursor(want.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Time.Equal(want.Time) || got.ID != want.ID || got.Offset != want.Offset {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	}
+
+	for _, token := range []string{"", "not base64!", "eDox", "azox"} {
+		if _, err := ParseCursor(token); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("ParseCursor(%q) = %v, want ErrInvalidCursor", token, err)
+		}
+	}
+}
+
+func TestEntryModelPage(t *testing.T) {
+	m := &EntryModel{DB: newTestDB(t)}
+
+	// b and c share a time, so the id decides their order
+	base := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	for i, e := range []Entry{
+		{GUID: "a", Latitude: 45.5, Longitude: -73.6},
+		{GUID: "b", Latitude: 49.1, Longitude: -122.7},
+		{GUID: "c", Latitude: 53.9, Longitude: -167.1},
+		{GUID: "d", Latitude: 62.0, Longitude: -140.0},
+		{GUID: "e", Latitude: 48.4, Longitude: -123.4},
+	} {
+		at := base.AddDate(0, 0, min(i, 1)+max(i-2, 0))
+		e.Time = &at
+		if _, err := m.Insert(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query func() *EntryQuery
+		want  []string
+	}{
+		{"by time", func() *EntryQuery { return NewEntryQuery().Limit(2) }, []string{"ed", "cb", "a"}},
+		{"exact pages", func() *EntryQuery { return NewEntryQuery().Limit(5) }, []string{"edcba"}},
+		{"near", func() *EntryQuery { return NewEntryQuery().Near(50, -125, 800).Limit(1) }, []string{"e", "b"}},
+		{"near by distance", func() *EntryQuery { return NewEntryQuery().Near(50, -125, 800).OrderByDistance().Limit(1) }, []string{"b", "e"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			var cursor *Cursor
+
+			for range 10 {
+				q := tt.query()
+				if cursor != nil {
+					q.After(*cursor)
+				}
+
+				entries, next, err := m.Page(q)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var page string
+				for _, e := range entries {
+					page += e.GUID
+				}
+				got = append(got, page)
+
+				if next == nil {
+					break
+				}
+				cursor = next
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got pages %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got pages %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
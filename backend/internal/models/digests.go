@@ -0,0 +1,228 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/earthquake-service/internal/geo"
+)
+
+// Frequencies of digests.
+const (
+	// DigestDaily covers a UTC day.
+	DigestDaily = "daily"
+	// DigestWeekly covers a week from Monday 00:00 UTC.
+	DigestWeekly = "weekly"
+)
+
+// Digest is a subscription to an email summarising the events in a region
+// every day or week. SentUntil is the end of the last period sent, or of the
+// period the subscription was made in until its first digest.
+// UnsubscribeToken lets the recipient cancel the subscription from a link in
+// the digest.
+type Digest struct {
+	ID               int64
+	Email            string
+	Frequency        string
+	Region           Region
+	MinMagnitude     *float64
+	CreatedAt        time.Time
+	SentUntil        time.Time
+	UnsubscribeToken string
+}
+
+// Matches reports whether e is in the digest's region and of at least its
+// minimum magnitude.
+func (d Digest) Matches(e Entry) bool {
+	if d.MinMagnitude != nil && !e.MagnitudeAtLeast(*d.MinMagnitude) {
+		return false
+	}
+	return d.Region.Contains(float64(e.Latitude), float64(e.Longitude))
+}
+
+// Period returns the last whole period of the digest's frequency ended at or
+// before t.
+func (d Digest) Period(t time.Time) (from, until time.Time) {
+	t = t.UTC()
+	until = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	if d.Frequency == DigestWeekly {
+		// Sunday is 0, so it is six days after Monday
+		until = until.AddDate(0, 0, -(int(until.Weekday())+6)%7)
+		return until.AddDate(0, 0, -7), until
+	}
+
+	return until.AddDate(0, 0, -1), until
+}
+
+// Due reports whether a period has ended at t since the last digest was
+// sent.
+func (d Digest) Due(t time.Time) bool {
+	_, until := d.Period(t)
+	return until.After(d.SentUntil)
+}
+
+// Query selects the candidates for the digest's events from..until, which
+// are checked with Matches. Events at until belong to the next period.
+func (d Digest) Query(from, until time.Time) *EntryQuery {
+	q := NewEntryQuery().Since(from).Until(until)
+
+	if d.Region.Box != nil {
+		b := d.Region.Box
+		if box, err := geo.NewBox(b.SWLng, b.SWLat, b.NELng, b.NELat); err == nil {
+			q.WithinBox(box)
+		}
+	}
+	if d.Region.Circle != nil {
+		c := d.Region.Circle
+		q.Near(c.Latitude, c.Longitude, c.RadiusKm)
+	}
+
+	return q
+}
+
+type DigestModel struct {
+	DB *sql.DB
+}
+
+const digestColumns = `id, email, frequency, ` + regionColumns + `, min_magnitude, created_at, sent_until, COALESCE(unsubscribe_token, '')`
+
+func scanDigest(row scanner) (d Digest, err error) {
+	var region regionScanner
+
+	dest := []any{&d.ID, &d.Email, &d.Frequency}
+	dest = append(dest, region.dest()...)
+	dest = append(dest, &d.MinMagnitude, &d.CreatedAt, &d.SentUntil, &d.UnsubscribeToken)
+
+	if err := row.Scan(dest...); err != nil {
+		return d, err
+	}
+
+	d.Region = region.region()
+	return d, nil
+}
+
+// Insert stores a new subscription with a new unsubscribe token and returns
+// its id. Its first digest covers the first whole period after it is made.
+func (m *DigestModel) Insert(d Digest) (int64, error) {
+	now := time.Now().UTC()
+	_, sentUntil := d.Period(now)
+
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return 0, err
+	}
+
+	args := []any{d.Email, d.Frequency}
+	args = append(args, regionArgs(d.Region)...)
+	args = append(args, d.MinMagnitude, now, sentUntil, hex.EncodeToString(token))
+
+	result, err := m.DB.Exec(`INSERT INTO digests (
+		email, frequency, `+regionColumns+`, min_magnitude, created_at, sent_until, unsubscribe_token
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+func (m *DigestModel) Get(id int64) (Digest, error) {
+	d, err := scanDigest(m.DB.QueryRow(`SELECT `+digestColumns+` FROM digests WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return d, ErrNoRecord
+	}
+	return d, err
+}
+
+// List returns subscriptions oldest first and the total number of
+// subscriptions.
+func (m *DigestModel) List(limit, offset int) (digests []Digest, total int, err error) {
+	err = m.DB.QueryRow(`SELECT COUNT(*) FROM digests`).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := m.DB.Query(`SELECT `+digestColumns+` FROM digests ORDER BY id LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d, err := scanDigest(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		digests = append(digests, d)
+	}
+
+	return digests, total, rows.Err()
+}
+
+// Due returns the subscriptions with a digest to send at t.
+func (m *DigestModel) Due(t time.Time) (digests []Digest, err error) {
+	rows, err := m.DB.Query(`SELECT ` + digestColumns + ` FROM digests ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d, err := scanDigest(rows)
+		if err != nil {
+			return nil, err
+		}
+		if d.Due(t) {
+			digests = append(digests, d)
+		}
+	}
+
+	return digests, rows.Err()
+}
+
+// MarkSent records that the digest of the period ending at until was sent.
+func (m *DigestModel) MarkSent(id int64, until time.Time) error {
+	result, err := m.DB.Exec(`UPDATE digests SET sent_until = ? WHERE id = ?`, until.UTC(), id)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+func (m *DigestModel) Delete(id int64) error {
+	result, err := m.DB.Exec(`DELETE FROM digests WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// GetByToken returns the subscription with the unsubscribe token token.
+func (m *DigestModel) GetByToken(token string) (Digest, error) {
+	if token == "" {
+		return Digest{}, ErrNoRecord
+	}
+
+	d, err := scanDigest(m.DB.QueryRow(`SELECT `+digestColumns+` FROM digests WHERE unsubscribe_token = ?`, token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return d, ErrNoRecord
+	}
+	return d, err
+}
+
+// DeleteByToken deletes the subscription with the unsubscribe token token.
+func (m *DigestModel) DeleteByToken(token string) error {
+	if token == "" {
+		return ErrNoRecord
+	}
+
+	result, err := m.DB.Exec(`DELETE FROM digests WHERE unsubscribe_token = ?`, token)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
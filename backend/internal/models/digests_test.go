@@ -0,0 +1,122 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDigestPeriod(t *testing.T) {
+	date := func(day, hour int) time.Time {
+		return time.Date(2025, 10, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	for _, tt := range []struct {
+		frequency string
+		at        time.Time
+		from      time.Time
+		until     time.Time
+	}{
+		{DigestDaily, date(16, 12), date(15, 0), date(16, 0)},
+		{DigestDaily, date(16, 0), date(15, 0), date(16, 0)},
+		// 2025-10-13 is a Monday
+		{DigestWeekly, date(16, 12), date(6, 0), date(13, 0)},
+		{DigestWeekly, date(13, 0), date(6, 0), date(13, 0)},
+		{DigestWeekly, date(19, 23), date(6, 0), date(13, 0)},
+		{DigestWeekly, date(20, 1), date(13, 0), date(20, 0)},
+		// local times are taken in UTC
+		{DigestDaily, time.Date(2025, 10, 16, 22, 0, 0, 0, time.FixedZone("EDT", -4*3600)), date(16, 0), date(17, 0)},
+	} {
+		from, until := Digest{Frequency: tt.frequency}.Period(tt.at)
+		if !from.Equal(tt.from) || !until.Equal(tt.until) {
+			t.Errorf("%s at %s: got %s to %s, want %s to %s", tt.frequency, tt.at, from, until, tt.from, tt.until)
+		}
+	}
+}
+
+func TestDigestModel(t *testing.T) {
+	m := &DigestModel{DB: newTestDB(t)}
+
+	minMag := 2.5
+	id, err := m.Insert(Digest{
+		Email:        "ops@example.com",
+		Frequency:    DigestDaily,
+		Region:       Region{Box: &Corners{-80, 40, -70, 50}},
+		MinMagnitude: &minMag,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Insert(Digest{Email: "all@example.com", Frequency: DigestWeekly}); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Email != "ops@example.com" || d.Region.Box == nil || d.Region.Circle != nil || *d.MinMagnitude != 2.5 {
+		t.Errorf("got %+v", d)
+	}
+
+	// nothing is due until the period the subscription was made in ends
+	now := time.Now()
+	if _, until := d.Period(now); !d.SentUntil.Equal(until) {
+		t.Errorf("got sent until %s, want %s", d.SentUntil, until)
+	}
+	if due, err := m.Due(now); err != nil || len(due) != 0 {
+		t.Errorf("got %+v, %v due now", due, err)
+	}
+
+	due, err := m.Due(now.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 1 || due[0].ID != id {
+		t.Fatalf("got %+v due tomorrow, want the daily digest", due)
+	}
+
+	_, until := d.Period(now.AddDate(0, 0, 1))
+	if err := m.MarkSent(id, until); err != nil {
+		t.Fatal(err)
+	}
+	if due, _ := m.Due(now.AddDate(0, 0, 1)); len(due) != 0 {
+		t.Errorf("got %+v due after sending", due)
+	}
+	if due, _ := m.Due(now.AddDate(0, 0, 8)); len(due) != 2 {
+		t.Errorf("got %d digests due next week, want 2", len(due))
+	}
+
+	digests, total, err := m.List(1, 1)
+	if err != nil || total != 2 || len(digests) != 1 || digests[0].Email != "all@example.com" {
+		t.Errorf("got %+v of %d, %v", digests, total, err)
+	}
+
+	if len(d.UnsubscribeToken) != 64 {
+		t.Errorf("got unsubscribe token %q", d.UnsubscribeToken)
+	}
+	for _, token := range []string{"", "guess"} {
+		if err := m.DeleteByToken(token); !errors.Is(err, ErrNoRecord) {
+			t.Errorf("unsubscribing with %q: got %v, want ErrNoRecord", token, err)
+		}
+	}
+	if d, err := m.GetByToken(digests[0].UnsubscribeToken); err != nil || d.ID != digests[0].ID {
+		t.Errorf("got %+v, %v", d, err)
+	}
+	if err := m.DeleteByToken(digests[0].UnsubscribeToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, _ := m.List(10, 0); total != 1 {
+		t.Errorf("got %d digests after unsubscribing one, want 1", total)
+	}
+
+	if err := m.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get(id); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("got %v, want ErrNoRecord", err)
+	}
+	if err := m.MarkSent(id, now); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("got %v, want ErrNoRecord", err)
+	}
+}
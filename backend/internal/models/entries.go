@@ -2,123 +2,374 @@ package models
 
 import (
 	"database/sql"
-	"fmt"
+	"errors"
+	"strconv"
+	"sync"
 	"time"
 )
 
 type EntryModelInterface interface {
-	Insert(item Entry) (int, error)
+	Insert(item Entry) (Change, error)
 }
 
 type Entry struct {
-	GUID       string
-	Title      string
-	Content    string
-	Categories string
-	Elevation  int32
-	Latitude   float32
-	Longitude  float32
-	Magnitude  float32
-	Updated    *time.Time
-	Published  *time.Time
-	Time       *time.Time
+	ID                   int64
+	GUID                 string
+	Title                string
+	Content              string
+	Categories           string
+	Place                string
+	Elevation            int32
+	Latitude             float32
+	Longitude            float32
+	Magnitude            float32
+	MagnitudeType        string
+	MagnitudeUncertainty *float32
+	Updated              *time.Time
+	Published            *time.Time
+	Time                 *time.Time
+	// SequenceID is the ID of the mainshock of the entry's sequence, nil
+	// until the entries are declustered
+	SequenceID *int64
+	Mainshock  bool
+	// ChangeID orders the changes to entries, each insert or update takes
+	// the next one
+	ChangeID int64
+	// DistanceKm is set by queries using Near
+	DistanceKm float64
+}
+
+// Widen converts f, such as a coordinate or magnitude read from its 32-bit
+// column, to the float64 with the same shortest decimal form, so 2.6 is
+// written as 2.6 rather than 2.5999999046325684.
+func Widen(f float32) float64 {
+	v, _ := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'g', -1, 32), 64)
+	return v
+}
+
+// MagnitudeAtLeast reports whether e is of magnitude m or more. Magnitudes
+// are compared as stored, in single precision, so an M4.1 is at least 4.1.
+func (e Entry) MagnitudeAtLeast(m float64) bool {
+	return e.Magnitude >= float32(m)
 }
 
 type EntryModel struct {
 	DB *sql.DB
+	// OnChange, when set, is called with the stored entry after Insert
+	// creates or updates it, in the order of their ChangeIDs. It must not
+	// block.
+	OnChange func(Entry, Change)
+
+	// publish makes committing a change and passing it to OnChange one step,
+	// so a later change can't be passed on before an earlier one
+	publish sync.Mutex
+	// declustering runs one Decluster at a time
+	declustering sync.Mutex
 }
 
-func (m *EntryModel) Insert(item Entry) (int, error) {
+// Insert stores the entry, or updates the stored entry with the same GUID.
+// When an update changes the stored solution the previous values are kept in
+// entry_revisions.
+func (m *EntryModel) Insert(item Entry) (Change, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return ChangeNone, err
+	}
+	defer tx.Rollback()
+
+	var current Entry
+	err = tx.QueryRow(`
+		SELECT
+			guid,
+			content,
+			COALESCE(place, ''),
+			time,
+			elevation,
+			latitude,
+			longitude,
+			magnitude,
+			COALESCE(magnitude_type, ''),
+			magnitude_uncertainty,
+			updated
+		FROM entries WHERE guid = ?
+	`, item.GUID).Scan(
+		&current.GUID,
+		&current.Content,
+		&current.Place,
+		&current.Time,
+		&current.Elevation,
+		&current.Latitude,
+		&current.Longitude,
+		&current.Magnitude,
+		&current.MagnitudeType,
+		&current.MagnitudeUncertainty,
+		&current.Updated,
+	)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = m.create(tx, item)
+		if err != nil {
+			return ChangeNone, err
+		}
+		return m.commit(tx, item.GUID, ChangeCreated)
+
+	case err != nil:
+		return ChangeNone, err
+	}
+
+	if !current.revisedBy(item) {
+		return ChangeNone, nil
+	}
+
+	err = m.update(tx, item)
+	if err != nil {
+		return ChangeNone, err
+	}
+
+	err = m.addRevision(tx, current, item)
+	if err != nil {
+		return ChangeNone, err
+	}
+
+	return m.commit(tx, item.GUID, ChangeUpdated)
+}
+
+// commit commits the change to the entry with the GUID guid and passes the
+// stored entry on to OnChange.
+func (m *EntryModel) commit(tx *sql.Tx, guid string, change Change) (Change, error) {
+	if m.OnChange == nil {
+		return change, tx.Commit()
+	}
+
+	stored, err := scanEntry(tx.QueryRow(`SELECT `+entryColumns+` FROM entries WHERE guid = ?`, guid))
+	if err != nil {
+		return ChangeNone, err
+	}
+
+	m.publish.Lock()
+	defer m.publish.Unlock()
+
+	if err := tx.Commit(); err != nil {
+		return ChangeNone, err
+	}
+	m.OnChange(stored, change)
+
+	return change, nil
+}
+
+// nextChangeID is the ChangeID of the next insert or update.
+const nextChangeID = `(SELECT COALESCE(MAX(change_id), 0) + 1 FROM entries)`
+
+func (m *EntryModel) create(tx *sql.Tx, item Entry) error {
 	stmt := `INSERT INTO entries (
 		guid, 
 		title, 
 		content, 
 		categories, 
+		place,
 		elevation, 
 		latitude, 
 		longitude, 
 		magnitude,
+		magnitude_type,
+		magnitude_uncertainty,
 		updated, 
 		published,
-		time
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) 
-	ON CONFLICT (guid) 
-	DO UPDATE SET 
-		latitude=?, 
-		longitude=?, 
-		elevation=?, 
-		updated=?, 
-		magnitude=?, 
-		content=?, 
-		time=?;
+		time,
+		change_id
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ` + nextChangeID + `)
 	`
 
-	result, err := m.DB.Exec(
+	_, err := tx.Exec(
 		stmt,
 		item.GUID,
 		item.Title,
 		item.Content,
 		item.Categories,
+		item.Place,
 		item.Elevation,
 		item.Latitude,
 		item.Longitude,
 		item.Magnitude,
+		item.MagnitudeType,
+		item.MagnitudeUncertainty,
 		item.Updated,
 		item.Published,
 		item.Time,
-		// -- on updat values
+	)
+	return err
+}
+
+func (m *EntryModel) update(tx *sql.Tx, item Entry) error {
+	stmt := `UPDATE entries SET 
+		latitude=?, 
+		longitude=?, 
+		elevation=?, 
+		updated=?, 
+		magnitude=?, 
+		magnitude_type=?,
+		magnitude_uncertainty=?,
+		content=?, 
+		place=?,
+		time=?,
+		change_id=` + nextChangeID + `
+	WHERE guid = ?
+	`
+
+	_, err := tx.Exec(
+		stmt,
 		item.Latitude,
 		item.Longitude,
 		item.Elevation,
 		item.Updated,
 		item.Magnitude,
+		item.MagnitudeType,
+		item.MagnitudeUncertainty,
 		item.Content,
+		item.Place,
 		item.Time,
+		item.GUID,
 	)
+	return err
+}
+
+// entryColumns are selected by queries returning whole entries, in the order
+// scanEntry reads them.
+const entryColumns = `
+	guid, 
+	title, 
+	content, 
+	categories, 
+	COALESCE(place, ''),
+	time,
+	elevation, 
+	latitude, 
+	longitude, 
+	magnitude,
+	COALESCE(magnitude_type, ''),
+	magnitude_uncertainty,
+	updated,
+	published,
+	id,
+	sequence_id,
+	COALESCE(mainshock, 0),
+	COALESCE(change_id, 0)
+`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanEntry reads the entryColumns of row, followed by any extra columns into
+// extra.
+func scanEntry(row scanner, extra ...any) (e Entry, err error) {
+	dest := []any{&e.GUID, &e.Title, &e.Content, &e.Categories, &e.Place, &e.Time, &e.Elevation, &e.Latitude, &e.Longitude, &e.Magnitude, &e.MagnitudeType, &e.MagnitudeUncertainty, &e.Updated, &e.Published, &e.ID, &e.SequenceID, &e.Mainshock, &e.ChangeID}
+	err = row.Scan(append(dest, extra...)...)
+	return e, err
+}
+
+// Query returns the entries matching q.
+func (m *EntryModel) Query(q *EntryQuery) (results []Entry, err error) {
+	stmt, args := q.SQL()
+
+	rows, err := m.DB.Query(stmt, args...)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	defer rows.Close()
 
-	// Use the LastInsertId() method on the result to get the id of our
-	// newly inserted record in the snippets table.
-	id, err := result.LastInsertId()
-	if err != nil {
-		return 0, nil
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
 
-	// The ID returned has the type int64, so we convert it to an int type
-	// before returning.
-	return int(id), nil
+	return q.refine(results), nil
 }
 
-func (m *EntryModel) QueryWithBounds(lat1, lat2, lng1, lng2 float64) (results []Entry) {
-	stmt := `
-		SELECT 
-			guid, 
-			title, 
-			content, 
-			categories, 
-			time,
-			elevation, 
-			latitude, 
-			longitude, 
-			magnitude
-		 FROM entries WHERE latitude >= ? AND latitude <= ? AND longitude >= ? AND longitude <= ?
-		 ORDER BY time DESC
-	`
-	rows, _ := m.DB.Query(stmt, lat1, lat2, lng1, lng2)
+// Each calls fn with each entry matching q as its row is read, so large
+// results are never held in memory. Ordering by distance needs every
+// candidate before the closest is known, so those queries are read in full
+// first. Each stops at the first error returned by fn.
+func (m *EntryModel) Each(q *EntryQuery, fn func(Entry) error) error {
+	if q.near != nil && q.byDistance {
+		results, err := m.Query(q)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range results {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	stmt, args := q.SQL()
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return err
+	}
 	defer rows.Close()
 
+	var skipped, count int
 	for rows.Next() {
-		var e Entry
-
-		err := rows.Scan(&e.GUID, &e.Title, &e.Content, &e.Categories, &e.Time, &e.Elevation, &e.Latitude, &e.Longitude, &e.Magnitude)
+		e, err := scanEntry(rows)
 		if err != nil {
-			fmt.Println(err)
+			return err
 		}
 
-		results = append(results, e)
+		// the paging of a Near query is left out of its SQL
+		if q.near != nil {
+			if !q.near.contains(&e) {
+				continue
+			}
+			if skipped < q.offset {
+				skipped = skipped + 1
+				continue
+			}
+			if q.limit > 0 && count >= q.limit {
+				break
+			}
+		}
+
+		if err := fn(e); err != nil {
+			return err
+		}
+		count = count + 1
+	}
+
+	return rows.Err()
+}
+
+// Get returns the entry with the guid, or ErrNoRecord.
+func (m *EntryModel) Get(guid string) (Entry, error) {
+	stmt := `SELECT ` + entryColumns + ` FROM entries WHERE guid = ?`
+
+	e, err := scanEntry(m.DB.QueryRow(stmt, guid))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Entry{}, ErrNoRecord
+		}
+		return Entry{}, err
 	}
 
-	return results
+	return e, nil
+}
+
+// Exists reports whether an entry with the guid is stored.
+func (m *EntryModel) Exists(guid string) (bool, error) {
+	var exists bool
+
+	err := m.DB.QueryRow(`SELECT EXISTS(SELECT true FROM entries WHERE guid = ?)`, guid).Scan(&exists)
+	return exists, err
 }
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	schema, err := os.ReadFile("../../cmd/web/schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", "file::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: is a new database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestEntryModelInsert(t *testing.T) {
+	m := &EntryModel{DB: newTestDB(t)}
+
+	t1 := time.Date(2025, 10, 16, 22, 0, 0, 0, time.UTC)
+	entry := Entry{
+		GUID:          "usgs:uw62100001",
+		Title:         "M 2.6 - 12 km NNE of Blaine, Washington",
+		Place:         "12 km NNE of Blaine, Washington",
+		Latitude:      49.1032,
+		Longitude:     -122.6891,
+		Elevation:     -18420,
+		Magnitude:     2.6,
+		MagnitudeType: "ml",
+		Time:          &t1,
+		Updated:       &t1,
+	}
+
+	change, err := m.Insert(entry)
+	if err != nil || change != ChangeCreated {
+		t.Fatalf("first Insert = %s, %v, want created", change, err)
+	}
+
+	change, err = m.Insert(entry)
+	if err != nil || change != ChangeNone {
+		t.Fatalf("repeated Insert = %s, %v, want unchanged", change, err)
+	}
+
+	revised := entry
+	t2 := t1.Add(time.Hour)
+	revised.Updated = &t2
+	revised.Magnitude = 2.9
+	revised.MagnitudeType = "mw"
+
+	change, err = m.Insert(revised)
+	if err != nil || change != ChangeUpdated {
+		t.Fatalf("revised Insert = %s, %v, want updated", change, err)
+	}
+
+	history, err := m.History(entry.GUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("got %d revisions, want 1", len(history))
+	}
+
+	got := history[0]
+	if got.Old.Magnitude != 2.6 || got.Old.MagnitudeType != "ml" {
+		t.Errorf("Old magnitude = %v %s, want 2.6 ml", got.Old.Magnitude, got.Old.MagnitudeType)
+	}
+	if got.New.Magnitude != 2.9 || got.New.MagnitudeType != "mw" {
+		t.Errorf("New magnitude = %v %s, want 2.9 mw", got.New.Magnitude, got.New.MagnitudeType)
+	}
+	if got.Updated == nil || !got.Updated.Equal(t2) {
+		t.Errorf("Updated = %v, want %v", got.Updated, t2)
+	}
+	if got.Old.Time == nil || !got.Old.Time.Equal(t1) {
+		t.Errorf("Old.Time = %v, want %v", got.Old.Time, t1)
+	}
+}
+
+func TestEntryModelGet(t *testing.T) {
+	m := &EntryModel{DB: newTestDB(t)}
+
+	published := time.Date(2025, 10, 16, 22, 5, 0, 0, time.UTC)
+	_, err := m.Insert(Entry{GUID: "usgs:uw62100001", Magnitude: 2.6, Published: &published})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := m.Get("usgs:uw62100001")
+	if err != nil {
+		t.Fatalf("Get: unexpected error %s", err)
+	}
+	if got.Magnitude != 2.6 || got.Published == nil || !got.Published.Equal(published) {
+		t.Errorf("Get = %+v", got)
+	}
+
+	_, err = m.Get("usgs:missing")
+	if !errors.Is(err, ErrNoRecord) {
+		t.Errorf("Get missing: got error %v, want %v", err, ErrNoRecord)
+	}
+}
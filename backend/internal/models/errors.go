@@ -0,0 +1,10 @@
+package models
+
+import "errors"
+
+var (
+	ErrNoRecord = errors.New("models: no matching record found")
+	// ErrLimit is returned when storing a record would exceed a limit on
+	// their number.
+	ErrLimit = errors.New("models: limit reached")
+)
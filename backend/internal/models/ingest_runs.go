@@ -0,0 +1,207 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Outcomes of a feed item during an ingest run.
+const (
+	OutcomeInserted  = "inserted"
+	OutcomeUpdated   = "updated"
+	OutcomeUnchanged = "unchanged"
+	OutcomeSkipped   = "skipped"
+	OutcomeFailed    = "failed"
+)
+
+type IngestRun struct {
+	ID         int64
+	Source     string
+	StartedAt  time.Time
+	FinishedAt *time.Time
+	Seen       int
+	Inserted   int
+	Updated    int
+	Skipped    int
+	Error      string
+	Items      []IngestRunItem
+}
+
+type IngestRunItem struct {
+	GUID    string
+	Title   string
+	Outcome string
+	Reason  string
+}
+
+// Record updates the counters with an item outcome and adds the item to the
+// run unless it was unchanged. Most items of a feed are unchanged on every
+// poll, so keeping them would grow ingest_run_items without bound.
+func (r *IngestRun) Record(item IngestRunItem) {
+	if item.Outcome != OutcomeUnchanged {
+		r.Items = append(r.Items, item)
+	}
+	r.Seen = r.Seen + 1
+
+	switch item.Outcome {
+	case OutcomeInserted:
+		r.Inserted = r.Inserted + 1
+	case OutcomeUpdated:
+		r.Updated = r.Updated + 1
+	case OutcomeSkipped, OutcomeFailed:
+		r.Skipped = r.Skipped + 1
+	}
+}
+
+type IngestRunModel struct {
+	DB *sql.DB
+}
+
+// Insert stores the run and its items.
+func (m *IngestRunModel) Insert(run IngestRun) (int64, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO ingest_runs (
+		source,
+		started_at,
+		finished_at,
+		seen,
+		inserted,
+		updated,
+		skipped,
+		error
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := tx.Exec(
+		stmt,
+		run.Source,
+		run.StartedAt,
+		run.FinishedAt,
+		run.Seen,
+		run.Inserted,
+		run.Updated,
+		run.Skipped,
+		run.Error,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, item := range run.Items {
+		_, err = tx.Exec(
+			`INSERT INTO ingest_run_items (run_id, guid, title, outcome, reason) VALUES (?, ?, ?, ?, ?)`,
+			id,
+			item.GUID,
+			item.Title,
+			item.Outcome,
+			item.Reason,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return id, tx.Commit()
+}
+
+// List returns runs newest first, without their items, and the total number
+// of runs.
+func (m *IngestRunModel) List(limit, offset int) (runs []IngestRun, total int, err error) {
+	err = m.DB.QueryRow(`SELECT COUNT(*) FROM ingest_runs`).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `
+		SELECT
+			id,
+			source,
+			started_at,
+			finished_at,
+			seen,
+			inserted,
+			updated,
+			skipped,
+			COALESCE(error, '')
+		FROM ingest_runs
+		ORDER BY started_at DESC, id DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := m.DB.Query(stmt, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r IngestRun
+
+		err := rows.Scan(&r.ID, &r.Source, &r.StartedAt, &r.FinishedAt, &r.Seen, &r.Inserted, &r.Updated, &r.Skipped, &r.Error)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		runs = append(runs, r)
+	}
+
+	return runs, total, rows.Err()
+}
+
+// Get returns a run with its items.
+func (m *IngestRunModel) Get(id int64) (run IngestRun, err error) {
+	stmt := `
+		SELECT
+			id,
+			source,
+			started_at,
+			finished_at,
+			seen,
+			inserted,
+			updated,
+			skipped,
+			COALESCE(error, '')
+		FROM ingest_runs
+		WHERE id = ?
+	`
+	err = m.DB.QueryRow(stmt, id).Scan(&run.ID, &run.Source, &run.StartedAt, &run.FinishedAt, &run.Seen, &run.Inserted, &run.Updated, &run.Skipped, &run.Error)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return run, ErrNoRecord
+		}
+		return run, err
+	}
+
+	rows, err := m.DB.Query(`
+		SELECT COALESCE(guid, ''), COALESCE(title, ''), outcome, COALESCE(reason, '')
+		FROM ingest_run_items
+		WHERE run_id = ?
+		ORDER BY id
+	`, id)
+	if err != nil {
+		return run, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item IngestRunItem
+
+		err := rows.Scan(&item.GUID, &item.Title, &item.Outcome, &item.Reason)
+		if err != nil {
+			return run, err
+		}
+
+		run.Items = append(run.Items, item)
+	}
+
+	return run, rows.Err()
+}
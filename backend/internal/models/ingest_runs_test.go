@@ -0,0 +1,77 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIngestRunRecord(t *testing.T) {
+	var run IngestRun
+	for _, outcome := range []string{OutcomeInserted, OutcomeUpdated, OutcomeUnchanged, OutcomeUnchanged, OutcomeSkipped, OutcomeFailed} {
+		run.Record(IngestRunItem{GUID: outcome, Outcome: outcome})
+	}
+
+	if run.Seen != 6 || run.Inserted != 1 || run.Updated != 1 || run.Skipped != 2 {
+		t.Errorf("got %+v", run)
+	}
+	if len(run.Items) != 4 {
+		t.Errorf("got %d items, want the 4 that weren't unchanged", len(run.Items))
+	}
+	for _, item := range run.Items {
+		if item.Outcome == OutcomeUnchanged {
+			t.Errorf("got unchanged item %+v", item)
+		}
+	}
+}
+
+func TestIngestRunModel(t *testing.T) {
+	m := &IngestRunModel{DB: newTestDB(t)}
+
+	start := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	for i, source := range []string{"nrcan", "usgs", "nrcan"} {
+		started := start.Add(time.Duration(i) * time.Minute)
+		finished := started.Add(time.Second)
+		run := IngestRun{Source: source, StartedAt: started, FinishedAt: &finished}
+		if i == 1 {
+			run.Error = "feed unavailable"
+		}
+		run.Record(IngestRunItem{GUID: "a", Title: "M2.6", Outcome: OutcomeInserted})
+		run.Record(IngestRunItem{GUID: "b", Title: "M1.0", Outcome: OutcomeSkipped, Reason: "no magnitude"})
+
+		if _, err := m.Insert(run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs, total, err := m.List(2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(runs) != 2 || runs[0].ID != 3 || runs[1].ID != 2 {
+		t.Fatalf("got %+v of %d, want runs 3 and 2", runs, total)
+	}
+	if r := runs[1]; r.Source != "usgs" || r.Error != "feed unavailable" || r.Seen != 2 || r.Inserted != 1 || r.Skipped != 1 || r.Items != nil {
+		t.Errorf("got %+v", r)
+	}
+
+	runs, _, err = m.List(2, 2)
+	if err != nil || len(runs) != 1 || runs[0].ID != 1 {
+		t.Errorf("got %+v, %v for the second page", runs, err)
+	}
+
+	run, err := m.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !run.StartedAt.Equal(start) || run.FinishedAt == nil || len(run.Items) != 2 {
+		t.Fatalf("got %+v", run)
+	}
+	if item := run.Items[1]; item.GUID != "b" || item.Outcome != OutcomeSkipped || item.Reason != "no magnitude" {
+		t.Errorf("got item %+v", item)
+	}
+
+	if _, err := m.Get(4); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("got %v, want ErrNoRecord", err)
+	}
+}
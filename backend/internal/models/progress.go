@@ -0,0 +1,32 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// ProgressModel stores the last change each consumer of the entry changes
+// has processed, so it can carry on from there after falling behind or a
+// restart.
+type ProgressModel struct {
+	DB *sql.DB
+}
+
+// Get returns the ChangeID of the last change consumer processed, or
+// ErrNoRecord before it stored any.
+func (m *ProgressModel) Get(consumer string) (changeID int64, err error) {
+	err = m.DB.QueryRow(`SELECT change_id FROM change_progress WHERE consumer = ?`, consumer).Scan(&changeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNoRecord
+	}
+	return changeID, err
+}
+
+// Set stores changeID as the last change consumer processed.
+func (m *ProgressModel) Set(consumer string, changeID int64) error {
+	_, err := m.DB.Exec(`
+		INSERT INTO change_progress (consumer, change_id) VALUES (?, ?)
+		ON CONFLICT (consumer) DO UPDATE SET change_id = excluded.change_id
+	`, consumer, changeID)
+	return err
+}
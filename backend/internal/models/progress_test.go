@@ -0,0 +1,30 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProgressModel(t *testing.T) {
+	m := &ProgressModel{DB: newTestDB(t)}
+
+	if _, err := m.Get("alerts"); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("got %v, want ErrNoRecord", err)
+	}
+
+	for _, id := range []int64{3, 7} {
+		if err := m.Set("alerts", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Set("webhooks", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if id, err := m.Get("alerts"); err != nil || id != 7 {
+		t.Errorf("got %d, %v, want 7", id, err)
+	}
+	if id, err := m.Get("webhooks"); err != nil || id != 1 {
+		t.Errorf("got %d, %v, want 1", id, err)
+	}
+}
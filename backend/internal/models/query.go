@@ -0,0 +1,211 @@
+package models
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/earthquake-service/internal/geo"
+)
+
+// EntryQuery builds the WHERE clause of an entries query from optional
+// filters. Each filter is a plain range on a stored column so SQLite can use
+// idx_time and idx_entries_latlng to satisfy it.
+type EntryQuery struct {
+	conditions []string
+	args       []any
+	limit      int
+	offset     int
+	near       *circle
+	byDistance bool
+}
+
+type circle struct {
+	lat      float64
+	lng      float64
+	radiusKm float64
+}
+
+// contains sets the distance of e from the centre and reports whether it is
+// within the circle.
+func (c *circle) contains(e *Entry) bool {
+	e.DistanceKm = geo.DistanceKm(c.lat, c.lng, float64(e.Latitude), float64(e.Longitude))
+	return e.DistanceKm <= c.radiusKm
+}
+
+func NewEntryQuery() *EntryQuery {
+	return &EntryQuery{}
+}
+
+func (q *EntryQuery) where(condition string, args ...any) *EntryQuery {
+	q.conditions = append(q.conditions, condition)
+	q.args = append(q.args, args...)
+	return q
+}
+
+// WithinBounds limits entries to a latitude/longitude box. The longitudes
+// are taken as given, use WithinBox for boxes that may cross the antimeridian.
+func (q *EntryQuery) WithinBounds(swlat, nelat, swlng, nelng float64) *EntryQuery {
+	return q.where("latitude >= ? AND latitude <= ? AND longitude >= ? AND longitude <= ?", swlat, nelat, swlng, nelng)
+}
+
+// WithinBox limits entries to a normalized box, matching either of its
+// longitude ranges when it crosses the antimeridian.
+func (q *EntryQuery) WithinBox(b geo.Box) *EntryQuery {
+	if len(b.Lngs) == 1 {
+		return q.WithinBounds(b.SWLat, b.NELat, b.Lngs[0].West, b.Lngs[0].East)
+	}
+
+	ranges := make([]string, len(b.Lngs))
+	args := []any{b.SWLat, b.NELat}
+	for i, r := range b.Lngs {
+		ranges[i] = "longitude >= ? AND longitude <= ?"
+		args = append(args, r.West, r.East)
+	}
+
+	return q.where("latitude >= ? AND latitude <= ? AND ("+strings.Join(ranges, " OR ")+")", args...)
+}
+
+// Since limits entries to events at or after t.
+func (q *EntryQuery) Since(t time.Time) *EntryQuery {
+	return q.where("time >= ?", t.UTC())
+}
+
+// Until limits entries to events at or before t.
+func (q *EntryQuery) Until(t time.Time) *EntryQuery {
+	return q.where("time <= ?", t.UTC())
+}
+
+func (q *EntryQuery) MinMagnitude(m float64) *EntryQuery {
+	return q.where("magnitude >= ?", m)
+}
+
+func (q *EntryQuery) MaxMagnitude(m float64) *EntryQuery {
+	return q.where("magnitude <= ?", m)
+}
+
+// MinDepth limits entries to events at least km below the surface. Depth is
+// stored as elevation in metres, so deeper events have smaller elevations.
+func (q *EntryQuery) MinDepth(km float64) *EntryQuery {
+	return q.where("elevation <= ?", -km*1000)
+}
+
+// MaxDepth limits entries to events at most km below the surface.
+func (q *EntryQuery) MaxDepth(km float64) *EntryQuery {
+	return q.where("elevation >= ?", -km*1000)
+}
+
+// Near limits entries to events within radiusKm of lat, lng and sets their
+// DistanceKm. The bounding box of the circle is filtered in SQL, through
+// idx_entries_latlng, then each candidate's great-circle distance is checked.
+func (q *EntryQuery) Near(lat, lng, radiusKm float64) *EntryQuery {
+	swlat, nelat, swlng, nelng := geo.BoundsAround(lat, lng, radiusKm)
+
+	// the circle's bounds are always valid, so the box can't fail
+	box, _ := geo.NewBox(swlng, swlat, nelng, nelat)
+
+	q.near = &circle{lat: lat, lng: lng, radiusKm: radiusKm}
+	return q.WithinBox(box)
+}
+
+// OrderByDistance returns the closest events first instead of the newest. It
+// only applies to queries using Near.
+func (q *EntryQuery) OrderByDistance() *EntryQuery {
+	q.byDistance = true
+	return q
+}
+
+// After continues the query from the page ending at c.
+func (q *EntryQuery) After(c Cursor) *EntryQuery {
+	if !c.keyset() {
+		q.offset = c.Offset
+		return q
+	}
+
+	t := c.Time.UTC()
+	return q.where("(time < ? OR (time = ? AND id < ?))", t, t, c.ID)
+}
+
+// Limit caps the number of entries returned, zero means no limit.
+func (q *EntryQuery) Limit(n int) *EntryQuery {
+	q.limit = n
+	return q
+}
+
+func (q *EntryQuery) Offset(n int) *EntryQuery {
+	q.offset = n
+	return q
+}
+
+// whereClause returns the WHERE clause, including the keyword, and its
+// arguments.
+func (q *EntryQuery) whereClause() (string, []any) {
+	if len(q.conditions) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(q.conditions, " AND "), append([]any{}, q.args...)
+}
+
+// SQL returns the full select statement for the query, newest events first
+// with ties broken by id so pages have a stable order.
+// Queries using Near are paged after their distances are checked, so the
+// statement has no LIMIT.
+func (q *EntryQuery) SQL() (string, []any) {
+	where, args := q.whereClause()
+
+	stmt := `SELECT ` + entryColumns + ` FROM entries` + where + ` ORDER BY time DESC, id DESC`
+
+	if q.near != nil {
+		return stmt, args
+	}
+
+	if q.limit > 0 {
+		stmt += ` LIMIT ?`
+		args = append(args, q.limit)
+
+		if q.offset > 0 {
+			stmt += ` OFFSET ?`
+			args = append(args, q.offset)
+		}
+	} else if q.offset > 0 {
+		stmt += ` LIMIT -1 OFFSET ?`
+		args = append(args, q.offset)
+	}
+
+	return stmt, args
+}
+
+// refine drops entries outside the circle given to Near, sets the distance
+// of the rest and applies the order and paging SQL left out.
+func (q *EntryQuery) refine(entries []Entry) []Entry {
+	if q.near == nil {
+		return entries
+	}
+
+	var results []Entry
+	for _, e := range entries {
+		if q.near.contains(&e) {
+			results = append(results, e)
+		}
+	}
+
+	if q.byDistance {
+		// stable, so equally distant events stay newest first
+		slices.SortStableFunc(results, func(a, b Entry) int {
+			return cmp.Compare(a.DistanceKm, b.DistanceKm)
+		})
+	}
+
+	if q.offset >= len(results) {
+		return nil
+	}
+	results = results[q.offset:]
+
+	if q.limit > 0 && q.limit < len(results) {
+		results = results[:q.limit]
+	}
+
+	return results
+}
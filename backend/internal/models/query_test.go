@@ -0,0 +1,199 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/earthquake-service/internal/geo"
+)
+
+func TestEntryQuery(t *testing.T) {
+	m := &EntryModel{DB: newTestDB(t)}
+
+	base := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	for i, e := range []Entry{
+		{GUID: "a", Latitude: 45.5, Longitude: -73.6, Magnitude: 1.2, Elevation: -5000},
+		{GUID: "b", Latitude: 49.1, Longitude: -122.7, Magnitude: 2.6, Elevation: -18400},
+		{GUID: "c", Latitude: 53.9, Longitude: -167.1, Magnitude: 4.3, Elevation: -45100},
+		{GUID: "d", Latitude: 62.0, Longitude: -140.0, Magnitude: 3.1, Elevation: -2000},
+	} {
+		at := base.AddDate(0, 0, i)
+		e.Time = &at
+		if _, err := m.Insert(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query *EntryQuery
+		want  string
+	}{
+		{"all newest first", NewEntryQuery(), "dcba"},
+		{"bounds", NewEntryQuery().WithinBounds(40, 55, -130, -70), "ba"},
+		{"since", NewEntryQuery().Since(base.AddDate(0, 0, 2)), "dc"},
+		{"until", NewEntryQuery().Until(base.AddDate(0, 0, 1)), "ba"},
+		{"magnitude", NewEntryQuery().MinMagnitude(2).MaxMagnitude(4), "db"},
+		{"depth", NewEntryQuery().MinDepth(5).MaxDepth(20), "ba"},
+		{"combined", NewEntryQuery().WithinBounds(40, 70, -180, -100).MinMagnitude(3), "dc"},
+		{"limit", NewEntryQuery().Limit(2), "dc"},
+		{"limit offset", NewEntryQuery().Limit(2).Offset(1), "cb"},
+		{"offset", NewEntryQuery().Offset(3), "a"},
+		{"box", NewEntryQuery().WithinBox(box(t, -130, 40, -70, 55)), "ba"},
+		{"box across the antimeridian", NewEntryQuery().WithinBox(box(t, 170, 50, -150, 65)), "c"},
+		{"box wrapped west", NewEntryQuery().WithinBox(box(t, -190, 50, -135, 65)), "dc"},
+		{"near", NewEntryQuery().Near(56, -160, 1500), "dc"},
+		{"near across the antimeridian", NewEntryQuery().Near(54, 178, 1000), "c"},
+		{"near excludes box corners", NewEntryQuery().Near(50, -120, 500), "b"},
+		{"near by distance", NewEntryQuery().Near(56, -160, 1500).OrderByDistance(), "cd"},
+		{"near by distance limit", NewEntryQuery().Near(56, -160, 1500).OrderByDistance().Limit(1), "c"},
+		{"near offset", NewEntryQuery().Near(56, -160, 1500).Offset(1), "c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := m.Query(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got string
+			for _, e := range results {
+				got += e.GUID
+			}
+
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntryQueryUsesIndices(t *testing.T) {
+	db := newTestDB(t)
+
+	tests := []struct {
+		name  string
+		query *EntryQuery
+		index string
+	}{
+		{"time", NewEntryQuery().Since(time.Now()).Until(time.Now()), "idx_time"},
+		{"bounds", NewEntryQuery().WithinBounds(40, 55, -130, -70), "idx_entries_latlng"},
+		{"near", NewEntryQuery().Near(45.4, -75.7, 100), "idx_entries_latlng"},
+		{"box across the antimeridian", NewEntryQuery().WithinBox(box(t, 170, 50, -150, 65)), "idx_entries_latlng"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, args := tt.query.SQL()
+
+			rows, err := db.Query("EXPLAIN QUERY PLAN "+stmt, args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+
+			var plan []string
+			for rows.Next() {
+				var id, parent, notused int
+				var detail string
+				if err := rows.Scan(&id, &parent, &notused, &detail); err != nil {
+					t.Fatal(err)
+				}
+				plan = append(plan, detail)
+			}
+
+			if !strings.Contains(strings.Join(plan, "\n"), tt.index) {
+				t.Errorf("query plan %q does not use %s", plan, tt.index)
+			}
+		})
+	}
+}
+
+func TestEntryQueryNearDistance(t *testing.T) {
+	m := &EntryModel{DB: newTestDB(t)}
+
+	at := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	_, err := m.Insert(Entry{GUID: "a", Latitude: 45.5, Longitude: -73.6, Time: &at})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ottawa to Montreal is about 165 km
+	results, err := m.Query(NewEntryQuery().Near(45.4, -75.7, 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	if d := results[0].DistanceKm; d < 160 || d > 170 {
+		t.Errorf("got distance %g km, want about 165", d)
+	}
+}
+
+func box(t *testing.T, swlng, swlat, nelng, nelat float64) geo.Box {
+	t.Helper()
+
+	b, err := geo.NewBox(swlng, swlat, nelng, nelat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestEntryModelEach(t *testing.T) {
+	m := &EntryModel{DB: newTestDB(t)}
+
+	base := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	for i, e := range []Entry{
+		{GUID: "a", Latitude: 45.5, Longitude: -73.6},
+		{GUID: "b", Latitude: 49.1, Longitude: -122.7},
+		{GUID: "c", Latitude: 53.9, Longitude: -167.1},
+		{GUID: "d", Latitude: 62.0, Longitude: -140.0},
+	} {
+		at := base.AddDate(0, 0, i)
+		e.Time = &at
+		if _, err := m.Insert(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each gives the same entries as Query, including the paging and
+	// ordering of Near queries
+	queries := map[string]func() *EntryQuery{
+		"all":              func() *EntryQuery { return NewEntryQuery() },
+		"limit offset":     func() *EntryQuery { return NewEntryQuery().Limit(2).Offset(1) },
+		"near":             func() *EntryQuery { return NewEntryQuery().Near(56, -160, 1500) },
+		"near offset":      func() *EntryQuery { return NewEntryQuery().Near(56, -160, 1500).Offset(1) },
+		"near limit":       func() *EntryQuery { return NewEntryQuery().Near(56, -160, 1500).Limit(1) },
+		"near by distance": func() *EntryQuery { return NewEntryQuery().Near(56, -160, 1500).OrderByDistance() },
+	}
+
+	for name, query := range queries {
+		t.Run(name, func(t *testing.T) {
+			results, err := m.Query(query())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var want, got string
+			for _, e := range results {
+				want += e.GUID
+			}
+
+			err = m.Each(query(), func(e Entry) error {
+				got += e.GUID
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package models
+
+import "github.com/earthquake-service/internal/geo"
+
+// Corners are the corners of a box as drawn on a web map, in the order of
+// the coords query parameter. See geo.NewBox for how they are read.
+type Corners struct {
+	SWLng float64
+	SWLat float64
+	NELng float64
+	NELat float64
+}
+
+// Circle is the area within RadiusKm of a point.
+type Circle struct {
+	Latitude  float64
+	Longitude float64
+	RadiusKm  float64
+}
+
+// Region is the area watched by a subscription: a box, a circle, or the whole
+// world when both are nil.
+type Region struct {
+	Box    *Corners
+	Circle *Circle
+}
+
+// Contains reports whether the point is in r.
+func (r Region) Contains(lat, lng float64) bool {
+	if r.Box != nil {
+		box, err := geo.NewBox(r.Box.SWLng, r.Box.SWLat, r.Box.NELng, r.Box.NELat)
+		if err != nil || !box.Contains(lat, lng) {
+			return false
+		}
+	}
+	if r.Circle != nil && geo.DistanceKm(r.Circle.Latitude, r.Circle.Longitude, lat, lng) > r.Circle.RadiusKm {
+		return false
+	}
+	return true
+}
+
+// regionColumns are the columns a Region is stored in, in the order of
+// regionArgs.
+const regionColumns = `swlng, swlat, nelng, nelat, latitude, longitude, radius_km`
+
+// regionArgs returns the values of the regionColumns for r.
+func regionArgs(r Region) []any {
+	args := make([]any, 7)
+	if r.Box != nil {
+		args[0], args[1], args[2], args[3] = r.Box.SWLng, r.Box.SWLat, r.Box.NELng, r.Box.NELat
+	}
+	if r.Circle != nil {
+		args[4], args[5], args[6] = r.Circle.Latitude, r.Circle.Longitude, r.Circle.RadiusKm
+	}
+	return args
+}
+
+// regionScanner reads the regionColumns back into a Region.
+type regionScanner struct {
+	box    [4]*float64
+	circle [3]*float64
+}
+
+func (s *regionScanner) dest() []any {
+	return []any{&s.box[0], &s.box[1], &s.box[2], &s.box[3], &s.circle[0], &s.circle[1], &s.circle[2]}
+}
+
+func (s *regionScanner) region() Region {
+	var r Region
+	if s.box[0] != nil && s.box[1] != nil && s.box[2] != nil && s.box[3] != nil {
+		r.Box = &Corners{SWLng: *s.box[0], SWLat: *s.box[1], NELng: *s.box[2], NELat: *s.box[3]}
+	}
+	if s.circle[0] != nil && s.circle[1] != nil && s.circle[2] != nil {
+		r.Circle = &Circle{Latitude: *s.circle[0], Longitude: *s.circle[1], RadiusKm: *s.circle[2]}
+	}
+	return r
+}
@@ -0,0 +1,207 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Change is what Insert did with an entry.
+type Change int
+
+const (
+	ChangeNone Change = iota
+	ChangeCreated
+	ChangeUpdated
+)
+
+func (c Change) String() string {
+	switch c {
+	case ChangeCreated:
+		return "created"
+	case ChangeUpdated:
+		return "updated"
+	default:
+		return "unchanged"
+	}
+}
+
+// Solution is the location, time and size of an event as given by one
+// revision of the feed.
+type Solution struct {
+	Time                 *time.Time
+	Latitude             float32
+	Longitude            float32
+	Elevation            int32
+	Magnitude            float32
+	MagnitudeType        string
+	MagnitudeUncertainty *float32
+	Place                string
+}
+
+// EntryRevision records a change to a stored entry.
+type EntryRevision struct {
+	GUID      string
+	RevisedAt time.Time
+	Updated   *time.Time
+	Old       Solution
+	New       Solution
+}
+
+func (e Entry) solution() Solution {
+	return Solution{
+		Time:                 e.Time,
+		Latitude:             e.Latitude,
+		Longitude:            e.Longitude,
+		Elevation:            e.Elevation,
+		Magnitude:            e.Magnitude,
+		MagnitudeType:        e.MagnitudeType,
+		MagnitudeUncertainty: e.MagnitudeUncertainty,
+		Place:                e.Place,
+	}
+}
+
+func (s Solution) equal(o Solution) bool {
+	return equalTime(s.Time, o.Time) &&
+		s.Latitude == o.Latitude &&
+		s.Longitude == o.Longitude &&
+		s.Elevation == o.Elevation &&
+		s.Magnitude == o.Magnitude &&
+		s.MagnitudeType == o.MagnitudeType &&
+		equalFloat(s.MagnitudeUncertainty, o.MagnitudeUncertainty) &&
+		s.Place == o.Place
+}
+
+// revisedBy reports whether storing item would change the stored entry.
+func (e Entry) revisedBy(item Entry) bool {
+	return !e.solution().equal(item.solution()) || e.Content != item.Content
+}
+
+func (m *EntryModel) addRevision(tx *sql.Tx, old, new Entry) error {
+	stmt := `INSERT INTO entry_revisions (
+		guid,
+		revised_at,
+		updated,
+		old_time,
+		old_latitude,
+		old_longitude,
+		old_elevation,
+		old_magnitude,
+		old_magnitude_type,
+		old_magnitude_uncertainty,
+		old_place,
+		new_time,
+		new_latitude,
+		new_longitude,
+		new_elevation,
+		new_magnitude,
+		new_magnitude_type,
+		new_magnitude_uncertainty,
+		new_place
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := tx.Exec(
+		stmt,
+		new.GUID,
+		time.Now().UTC(),
+		new.Updated,
+		old.Time,
+		old.Latitude,
+		old.Longitude,
+		old.Elevation,
+		old.Magnitude,
+		old.MagnitudeType,
+		old.MagnitudeUncertainty,
+		old.Place,
+		new.Time,
+		new.Latitude,
+		new.Longitude,
+		new.Elevation,
+		new.Magnitude,
+		new.MagnitudeType,
+		new.MagnitudeUncertainty,
+		new.Place,
+	)
+	return err
+}
+
+// History returns the revisions of an entry, oldest first.
+func (m *EntryModel) History(guid string) (revisions []EntryRevision, err error) {
+	stmt := `
+		SELECT
+			guid,
+			revised_at,
+			updated,
+			old_time,
+			old_latitude,
+			old_longitude,
+			old_elevation,
+			old_magnitude,
+			COALESCE(old_magnitude_type, ''),
+			old_magnitude_uncertainty,
+			COALESCE(old_place, ''),
+			new_time,
+			new_latitude,
+			new_longitude,
+			new_elevation,
+			new_magnitude,
+			COALESCE(new_magnitude_type, ''),
+			new_magnitude_uncertainty,
+			COALESCE(new_place, '')
+		FROM entry_revisions
+		WHERE guid = ?
+		ORDER BY revised_at, id
+	`
+	rows, err := m.DB.Query(stmt, guid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r EntryRevision
+
+		err := rows.Scan(
+			&r.GUID,
+			&r.RevisedAt,
+			&r.Updated,
+			&r.Old.Time,
+			&r.Old.Latitude,
+			&r.Old.Longitude,
+			&r.Old.Elevation,
+			&r.Old.Magnitude,
+			&r.Old.MagnitudeType,
+			&r.Old.MagnitudeUncertainty,
+			&r.Old.Place,
+			&r.New.Time,
+			&r.New.Latitude,
+			&r.New.Longitude,
+			&r.New.Elevation,
+			&r.New.Magnitude,
+			&r.New.MagnitudeType,
+			&r.New.MagnitudeUncertainty,
+			&r.New.Place,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, r)
+	}
+
+	return revisions, rows.Err()
+}
+
+func equalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func equalFloat(a, b *float32) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/earthquake-service/internal/seismicity"
+)
+
+// Sequence is a mainshock with its foreshocks and aftershocks.
+type Sequence struct {
+	Mainshock Entry
+	// Count includes the mainshock.
+	Count int
+	First *time.Time
+	Last  *time.Time
+}
+
+// Decluster assigns every entry with a time to a sequence using
+// Gardner-Knopoff windows, and returns how many entries changed sequence.
+// Each pass covers every stored entry, since a new large event can take over
+// the aftershocks of earlier ones.
+//
+// The entries are read and grouped outside a transaction, so ingestion isn't
+// held up while the whole table is declustered, and only the entries whose
+// sequence changed are written. Passes run one at a time, and an entry
+// stored during a pass is grouped by the pass that follows its insert.
+func (m *EntryModel) Decluster() (changed int, err error) {
+	m.declustering.Lock()
+	defer m.declustering.Unlock()
+
+	rows, err := m.DB.Query(`
+		SELECT id, time, latitude, longitude, magnitude, sequence_id, COALESCE(mainshock, 0)
+		FROM entries
+		WHERE time IS NOT NULL
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var events []seismicity.Event
+	current := map[int64]seismicity.Assignment{}
+	for rows.Next() {
+		var id int64
+		var at time.Time
+		var lat, lng, magnitude float32
+		var sequenceID *int64
+		var mainshock bool
+
+		err := rows.Scan(&id, &at, &lat, &lng, &magnitude, &sequenceID, &mainshock)
+		if err != nil {
+			return 0, err
+		}
+
+		events = append(events, seismicity.Event{
+			ID:        id,
+			Time:      at,
+			Latitude:  float64(lat),
+			Longitude: float64(lng),
+			Magnitude: float64(magnitude),
+		})
+		if sequenceID != nil {
+			current[id] = seismicity.Assignment{SequenceID: *sequenceID, Mainshock: mainshock}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	assignments := seismicity.Decluster(events)
+	for id, a := range assignments {
+		if c, ok := current[id]; ok && c == a {
+			delete(assignments, id)
+		}
+	}
+	if len(assignments) == 0 {
+		return 0, nil
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`UPDATE entries SET sequence_id = ?, mainshock = ? WHERE id = ?`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for id, a := range assignments {
+		_, err := stmt.Exec(a.SequenceID, a.Mainshock, id)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(assignments), tx.Commit()
+}
+
+// sequenceColumns follow entryColumns in queries returning sequences, in the
+// order scanSequence reads them.
+const sequenceColumns = `
+	(SELECT COUNT(*) FROM entries AS s WHERE s.sequence_id = entries.id),
+	(SELECT MIN(s.time) FROM entries AS s WHERE s.sequence_id = entries.id),
+	(SELECT MAX(s.time) FROM entries AS s WHERE s.sequence_id = entries.id)
+`
+
+func scanSequence(row scanner) (s Sequence, err error) {
+	var first, last *string
+
+	s.Mainshock, err = scanEntry(row, &s.Count, &first, &last)
+	if err != nil {
+		return Sequence{}, err
+	}
+
+	// aggregates lose the column type, so the times come back as text
+	if s.First, err = parseStoredTime(first); err != nil {
+		return Sequence{}, err
+	}
+	if s.Last, err = parseStoredTime(last); err != nil {
+		return Sequence{}, err
+	}
+
+	return s, nil
+}
+
+// Sequences returns the sequences of at least minCount entries whose
+// mainshock matches q, newest mainshock first. The query's limit and offset
+// page through the sequences.
+func (m *EntryModel) Sequences(q *EntryQuery, minCount int) (sequences []Sequence, err error) {
+	where, args := q.whereClause()
+	if where == "" {
+		where = " WHERE "
+	} else {
+		where = where + " AND "
+	}
+	where = where + "mainshock = 1 AND (SELECT COUNT(*) FROM entries AS s WHERE s.sequence_id = entries.id) >= ?"
+	args = append(args, minCount)
+
+	stmt := `SELECT ` + entryColumns + `, ` + sequenceColumns + ` FROM entries` + where + `
+		ORDER BY time DESC, id DESC`
+	if q.limit > 0 {
+		stmt = stmt + ` LIMIT ? OFFSET ?`
+		args = append(args, q.limit, q.offset)
+	}
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s, err := scanSequence(rows)
+		if err != nil {
+			return nil, err
+		}
+		sequences = append(sequences, s)
+	}
+
+	return sequences, rows.Err()
+}
+
+// GetSequence returns the sequence whose mainshock has the ID id and its
+// entries in time order. It returns ErrNoRecord when there is no such
+// sequence.
+func (m *EntryModel) GetSequence(id int64) (Sequence, []Entry, error) {
+	stmt := `SELECT ` + entryColumns + `, ` + sequenceColumns + ` FROM entries WHERE id = ? AND mainshock = 1`
+
+	s, err := scanSequence(m.DB.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Sequence{}, nil, ErrNoRecord
+		}
+		return Sequence{}, nil, err
+	}
+
+	rows, err := m.DB.Query(`SELECT `+entryColumns+` FROM entries WHERE sequence_id = ? ORDER BY time, id`, id)
+	if err != nil {
+		return Sequence{}, nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return Sequence{}, nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return s, entries, rows.Err()
+}
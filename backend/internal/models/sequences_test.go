@@ -0,0 +1,109 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEntryModelDecluster(t *testing.T) {
+	m := &EntryModel{DB: newTestDB(t)}
+
+	t0 := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	insert := func(e Entry, after time.Duration) {
+		t.Helper()
+		at := t0.Add(after)
+		e.Time = &at
+		if _, err := m.Insert(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	insert(Entry{GUID: "fore", Latitude: 40.05, Longitude: 140, Magnitude: 3}, -time.Hour)
+	insert(Entry{GUID: "main", Latitude: 40, Longitude: 140, Magnitude: 5.5}, 0)
+	insert(Entry{GUID: "after", Latitude: 40.1, Longitude: 140.1, Magnitude: 4}, 24*time.Hour)
+	insert(Entry{GUID: "alone", Latitude: -20, Longitude: -70, Magnitude: 4.5}, 48*time.Hour)
+
+	changed, err := m.Decluster()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 4 {
+		t.Errorf("got %d changed, want 4", changed)
+	}
+
+	main, err := m.Get("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if main.SequenceID == nil || *main.SequenceID != main.ID || !main.Mainshock {
+		t.Fatalf("got sequence %v mainshock %t, want its own sequence", main.SequenceID, main.Mainshock)
+	}
+	for _, guid := range []string{"fore", "after"} {
+		e, err := m.Get(guid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.SequenceID == nil || *e.SequenceID != main.ID || e.Mainshock {
+			t.Errorf("%s: got sequence %v mainshock %t, want in the sequence of main", guid, e.SequenceID, e.Mainshock)
+		}
+	}
+
+	changed, err = m.Decluster()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 0 {
+		t.Errorf("got %d changed on a second pass, want 0", changed)
+	}
+
+	// a larger event takes the sequence over
+	insert(Entry{GUID: "bigger", Latitude: 40.02, Longitude: 140, Magnitude: 6.5}, 2*time.Hour)
+	changed, err = m.Decluster()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 4 {
+		t.Errorf("got %d changed, want 4", changed)
+	}
+
+	sequences, err := m.Sequences(NewEntryQuery(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sequences) != 1 {
+		t.Fatalf("got %d sequences, want 1: %+v", len(sequences), sequences)
+	}
+	s := sequences[0]
+	if s.Mainshock.GUID != "bigger" || s.Count != 4 {
+		t.Errorf("got mainshock %s with %d events, want bigger with 4", s.Mainshock.GUID, s.Count)
+	}
+	if s.First == nil || !s.First.Equal(t0.Add(-time.Hour)) || s.Last == nil || !s.Last.Equal(t0.Add(24*time.Hour)) {
+		t.Errorf("got %v to %v", s.First, s.Last)
+	}
+
+	sequences, err = m.Sequences(NewEntryQuery().MaxMagnitude(6), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sequences) != 1 || sequences[0].Mainshock.GUID != "alone" || sequences[0].Count != 1 {
+		t.Errorf("got %+v, want alone", sequences)
+	}
+
+	s, entries, err := m.GetSequence(s.Mainshock.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	for _, e := range entries {
+		got = got + e.GUID + " "
+	}
+	if s.Count != 4 || got != "fore main bigger after " {
+		t.Errorf("got %d events %q", s.Count, got)
+	}
+
+	main, _ = m.Get("main")
+	if _, _, err := m.GetSequence(main.ID); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("got %v for an aftershock, want ErrNoRecord", err)
+	}
+}
@@ -0,0 +1,265 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Summary holds the totals of the entries matching a query.
+type Summary struct {
+	Count        int
+	MinMagnitude *float64
+	MaxMagnitude *float64
+	// MeanDepthKm is the mean depth below the surface, nil without entries.
+	MeanDepthKm *float64
+	First       *time.Time
+	Last        *time.Time
+}
+
+// Summary returns the totals of the entries matching q.
+func (m *EntryModel) Summary(q *EntryQuery) (Summary, error) {
+	where, args := q.whereClause()
+
+	stmt := `
+		SELECT
+			COUNT(*),
+			MIN(magnitude),
+			MAX(magnitude),
+			AVG(elevation),
+			MIN(time),
+			MAX(time)
+		FROM entries` + where
+
+	var s Summary
+	var meanElevation *float64
+	var first, last *string
+
+	err := m.DB.QueryRow(stmt, args...).Scan(&s.Count, &s.MinMagnitude, &s.MaxMagnitude, &meanElevation, &first, &last)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	// the extremes are read back from the 32-bit magnitude column
+	for _, m := range []*float64{s.MinMagnitude, s.MaxMagnitude} {
+		if m != nil {
+			*m = Widen(float32(*m))
+		}
+	}
+
+	if meanElevation != nil {
+		depth := -*meanElevation / 1000
+		s.MeanDepthKm = &depth
+	}
+	if s.First, err = parseStoredTime(first); err != nil {
+		return Summary{}, err
+	}
+	if s.Last, err = parseStoredTime(last); err != nil {
+		return Summary{}, err
+	}
+
+	return s, nil
+}
+
+// Largest returns the entry with the greatest magnitude matching q, the most
+// recent one on a tie. It returns ErrNoRecord when nothing matches.
+func (m *EntryModel) Largest(q *EntryQuery) (Entry, error) {
+	where, args := q.whereClause()
+
+	stmt := `SELECT ` + entryColumns + ` FROM entries` + where + `
+		ORDER BY magnitude DESC, time DESC, id DESC
+		LIMIT 1`
+
+	e, err := scanEntry(m.DB.QueryRow(stmt, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Entry{}, ErrNoRecord
+		}
+		return Entry{}, err
+	}
+
+	return e, nil
+}
+
+// MagnitudeBin counts the entries with Min <= magnitude < Max.
+type MagnitudeBin struct {
+	Min   float64
+	Max   float64
+	Count int
+}
+
+// lowestMagnitude is the bottom of the magnitude scale accepted by the
+// filters, below which no bin starts.
+const lowestMagnitude = -2
+
+// MagnitudeHistogram counts the entries matching q in bins of width
+// magnitude units. Bins are aligned to multiples of width and run without
+// gaps from the lowest to the highest magnitude found.
+func (m *EntryModel) MagnitudeHistogram(q *EntryQuery, width float64) (bins []MagnitudeBin, err error) {
+	if width <= 0 || math.IsNaN(width) || math.IsInf(width, 0) {
+		return nil, fmt.Errorf("bin width must be positive, got %g", width)
+	}
+
+	// bins are numbered up from origin so the index is never negative and
+	// truncating it is the same as taking the floor
+	origin := math.Floor(lowestMagnitude/width) * width
+
+	where, args := q.whereClause()
+
+	// magnitudes are stored as 32-bit floats, the small nudge keeps 2.6
+	// (2.5999999) in the bin starting at 2.6
+	stmt := `
+		SELECT CAST((magnitude - ?) / ? + 1e-6 AS INTEGER) AS bin, COUNT(*)
+		FROM entries` + where + `
+		GROUP BY bin
+		ORDER BY bin
+	`
+	args = append([]any{origin, width}, args...)
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var last int
+	for rows.Next() {
+		var bin, count int
+		if err := rows.Scan(&bin, &count); err != nil {
+			return nil, err
+		}
+
+		// fill the empty bins since the previous one
+		if len(bins) == 0 {
+			last = bin - 1
+		}
+		for i := last + 1; i <= bin; i++ {
+			bins = append(bins, MagnitudeBin{
+				Min: binEdge(origin, width, i),
+				Max: binEdge(origin, width, i+1),
+			})
+		}
+		bins[len(bins)-1].Count = count
+		last = bin
+	}
+
+	return bins, rows.Err()
+}
+
+// binEdge returns the start of bin i, rounded so a width of 0.1 gives 2.6
+// rather than 2.6000000000000005.
+func binEdge(origin, width float64, i int) float64 {
+	return math.Round((origin+float64(i)*width)*1e9) / 1e9
+}
+
+// Intervals of a count series.
+const (
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
+// SeriesPoint counts the entries in the interval beginning at Start.
+type SeriesPoint struct {
+	Start time.Time
+	Count int
+}
+
+// CountSeries counts the entries matching q per UTC day, or per week
+// starting on Monday. Intervals run without gaps from the first entry to the
+// last, and entries without a time are left out.
+func (m *EntryModel) CountSeries(q *EntryQuery, interval string) (series []SeriesPoint, err error) {
+	var bucket string
+	var step int
+	switch interval {
+	case IntervalDay:
+		bucket, step = `substr(time, 1, 10)`, 1
+	case IntervalWeek:
+		// the next Sunday, or the day itself, less six days is the Monday
+		bucket, step = `date(substr(time, 1, 10), 'weekday 0', '-6 days')`, 7
+	default:
+		return nil, fmt.Errorf("unknown interval %q", interval)
+	}
+
+	where, args := q.whereClause()
+	if where == "" {
+		where = " WHERE time IS NOT NULL"
+	} else {
+		where = where + " AND time IS NOT NULL"
+	}
+
+	// times are stored as text beginning with the UTC date
+	stmt := `
+		SELECT ` + bucket + ` AS bucket, COUNT(*)
+		FROM entries` + where + `
+		GROUP BY bucket
+		ORDER BY bucket
+	`
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day string
+		var count int
+		if err := rows.Scan(&day, &count); err != nil {
+			return nil, err
+		}
+
+		start, err := time.Parse(time.DateOnly, day)
+		if err != nil {
+			return nil, fmt.Errorf("reading series interval: %w", err)
+		}
+
+		if len(series) > 0 {
+			for t := series[len(series)-1].Start.AddDate(0, 0, step); t.Before(start); t = t.AddDate(0, 0, step) {
+				series = append(series, SeriesPoint{Start: t})
+			}
+		}
+		series = append(series, SeriesPoint{Start: start, Count: count})
+	}
+
+	return series, rows.Err()
+}
+
+// parseStoredTime reads a time aggregated from the time column, where it
+// comes back as text.
+func parseStoredTime(v *string) (*time.Time, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	t, err := time.Parse(storedTimeLayout, *v)
+	if err != nil {
+		return nil, fmt.Errorf("reading stored time: %w", err)
+	}
+	t = t.UTC()
+
+	return &t, nil
+}
+
+// Magnitudes returns the magnitude of every entry matching q, in no
+// particular order.
+func (m *EntryModel) Magnitudes(q *EntryQuery) (magnitudes []float64, err error) {
+	where, args := q.whereClause()
+
+	rows, err := m.DB.Query(`SELECT magnitude FROM entries`+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var magnitude float32
+		if err := rows.Scan(&magnitude); err != nil {
+			return nil, err
+		}
+		magnitudes = append(magnitudes, float64(magnitude))
+	}
+
+	return magnitudes, rows.Err()
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func insertStatsEntries(t *testing.T, m *EntryModel) {
+	t.Helper()
+
+	// 2025-10-01 is a Wednesday
+	for _, e := range []struct {
+		Entry
+		day int
+	}{
+		{Entry{GUID: "a", Latitude: 45.5, Longitude: -73.6, Magnitude: 1.2, Elevation: -5000}, 1},
+		{Entry{GUID: "b", Latitude: 49.1, Longitude: -122.7, Magnitude: 2.6, Elevation: -18400}, 2},
+		{Entry{GUID: "c", Latitude: 53.9, Longitude: -167.1, Magnitude: 4.3, Elevation: -45100}, 5},
+		{Entry{GUID: "d", Latitude: 62.0, Longitude: -140.0, Magnitude: 2.6, Elevation: -2000}, 6},
+		{Entry{GUID: "e", Latitude: 48.4, Longitude: -123.4, Magnitude: 0.4, Elevation: -10000}, 14},
+	} {
+		at := time.Date(2025, 10, e.day, 12, 30, 0, 0, time.UTC)
+		e.Time = &at
+		if _, err := m.Insert(e.Entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEntryModelSummary(t *testing.T) {
+	m := &EntryModel{DB: newTestDB(t)}
+	insertStatsEntries(t, m)
+
+	s, err := m.Summary(NewEntryQuery())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Count != 5 {
+		t.Errorf("got count %d, want 5", s.Count)
+	}
+	if s.MinMagnitude == nil || math.Abs(*s.MinMagnitude-0.4) > 1e-6 || s.MaxMagnitude == nil || math.Abs(*s.MaxMagnitude-4.3) > 1e-6 {
+		t.Errorf("got magnitudes %v to %v, want 0.4 to 4.3", s.MinMagnitude, s.MaxMagnitude)
+	}
+	if s.MeanDepthKm == nil || math.Abs(*s.MeanDepthKm-16.1) > 1e-9 {
+		t.Errorf("got mean depth %v, want 16.1", s.MeanDepthKm)
+	}
+	if want := time.Date(2025, 10, 1, 12, 30, 0, 0, time.UTC); s.First == nil || !s.First.Equal(want) {
+		t.Errorf("got first %v, want %v", s.First, want)
+	}
+	if want := time.Date(2025, 10, 14, 12, 30, 0, 0, time.UTC); s.Last == nil || !s.Last.Equal(want) {
+		t.Errorf("got last %v, want %v", s.Last, want)
+	}
+
+	s, err = m.Summary(NewEntryQuery().MinMagnitude(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Count != 0 || s.MeanDepthKm != nil || s.MaxMagnitude != nil || s.First != nil {
+		t.Errorf("got %+v, want an empty summary", s)
+	}
+}
+
+func TestEntryModelLargest(t *testing.T) {
+	m := &EntryModel{DB: newTestDB(t)}
+	insertStatsEntries(t, m)
+
+	tests := []struct {
+		name  string
+		query *EntryQuery
+		want  string
+	}{
+		{"all", NewEntryQuery(), "c"},
+		{"tie goes to the latest", NewEntryQuery().MaxMagnitude(3), "d"},
+		{"bounds", NewEntryQuery().WithinBounds(40, 50, -130, -70), "b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := m.Largest(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.GUID != tt.want {
+				t.Errorf("got %s, want %s", e.GUID, tt.want)
+			}
+		})
+	}
+
+	_, err := m.Largest(NewEntryQuery().MinMagnitude(5))
+	if !errors.Is(err, ErrNoRecord) {
+		t.Errorf("got %v, want ErrNoRecord", err)
+	}
+}
+
+func TestEntryModelMagnitudeHistogram(t *testing.T) {
+	m := &EntryModel{DB: newTestDB(t)}
+	insertStatsEntries(t, m)
+
+	bins, err := m.MagnitudeHistogram(NewEntryQuery(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []MagnitudeBin{
+		{Min: 0, Max: 1, Count: 1},
+		{Min: 1, Max: 2, Count: 1},
+		{Min: 2, Max: 3, Count: 2},
+		{Min: 3, Max: 4, Count: 0},
+		{Min: 4, Max: 5, Count: 1},
+	}
+	if len(bins) != len(want) {
+		t.Fatalf("got %+v, want %+v", bins, want)
+	}
+	for i := range want {
+		if bins[i] != want[i] {
+			t.Errorf("bin %d: got %+v, want %+v", i, bins[i], want[i])
+		}
+	}
+
+	// 2.6 is stored as 2.5999999 but belongs to the bin starting at 2.6
+	bins, err = m.MagnitudeHistogram(NewEntryQuery().MinMagnitude(2).MaxMagnitude(3), 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bins) != 1 || bins[0].Min != 2.6 || bins[0].Max != 2.7 || bins[0].Count != 2 {
+		t.Errorf("got %+v, want one bin from 2.6", bins)
+	}
+
+	bins, err = m.MagnitudeHistogram(NewEntryQuery().MinMagnitude(5), 1)
+	if err != nil || len(bins) != 0 {
+		t.Errorf("got %+v, %v, want no bins", bins, err)
+	}
+
+	if _, err := m.MagnitudeHistogram(NewEntryQuery(), 0); err == nil {
+		t.Error("got no error for a zero bin width")
+	}
+}
+
+func TestEntryModelCountSeries(t *testing.T) {
+	m := &EntryModel{DB: newTestDB(t)}
+	insertStatsEntries(t, m)
+
+	series, err := m.CountSeries(NewEntryQuery(), IntervalDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 14 {
+		t.Fatalf("got %d days, want 14: %+v", len(series), series)
+	}
+	counts := map[int]int{1: 1, 2: 1, 5: 1, 6: 1, 14: 1}
+	for i, p := range series {
+		day := time.Date(2025, 10, i+1, 0, 0, 0, 0, time.UTC)
+		if !p.Start.Equal(day) || p.Count != counts[i+1] {
+			t.Errorf("got %+v, want %d on %v", p, counts[i+1], day)
+		}
+	}
+
+	series, err = m.CountSeries(NewEntryQuery(), IntervalWeek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SeriesPoint{
+		{Start: time.Date(2025, 9, 29, 0, 0, 0, 0, time.UTC), Count: 3},
+		{Start: time.Date(2025, 10, 6, 0, 0, 0, 0, time.UTC), Count: 1},
+		{Start: time.Date(2025, 10, 13, 0, 0, 0, 0, time.UTC), Count: 1},
+	}
+	if len(series) != len(want) {
+		t.Fatalf("got %+v, want %+v", series, want)
+	}
+	for i := range want {
+		if !series[i].Start.Equal(want[i].Start) || series[i].Count != want[i].Count {
+			t.Errorf("week %d: got %+v, want %+v", i, series[i], want[i])
+		}
+	}
+
+	if _, err := m.CountSeries(NewEntryQuery(), "month"); err == nil {
+		t.Error("got no error for an unknown interval")
+	}
+}
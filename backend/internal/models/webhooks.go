@@ -0,0 +1,365 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Webhook is a subscription to events in a region, delivered by POSTing
+// them to URL signed with Secret.
+type Webhook struct {
+	ID           int64
+	URL          string
+	Region       Region
+	MinMagnitude *float64
+	Secret       string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Matches reports whether e is in the webhook's region and of at least its
+// minimum magnitude.
+func (w Webhook) Matches(e Entry) bool {
+	if w.MinMagnitude != nil && !e.MagnitudeAtLeast(*w.MinMagnitude) {
+		return false
+	}
+	return w.Region.Contains(float64(e.Latitude), float64(e.Longitude))
+}
+
+// WebhookDelivery is one attempt to deliver a change to a webhook.
+// StatusCode is 0 when no response was received.
+type WebhookDelivery struct {
+	ID          int64
+	WebhookID   int64
+	GUID        string
+	ChangeID    int64
+	Change      string
+	Attempt     int
+	AttemptedAt time.Time
+	StatusCode  int
+	Error       string
+}
+
+// PendingDelivery is the delivery of a change to a webhook waiting for its
+// next attempt, numbered Attempt and due at DueAt. Body is the payload,
+// encoded when the change was dispatched.
+type PendingDelivery struct {
+	ID        int64
+	WebhookID int64
+	GUID      string
+	ChangeID  int64
+	Change    string
+	Body      []byte
+	Attempt   int
+	DueAt     time.Time
+}
+
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+const webhookColumns = `id, url, ` + regionColumns + `, min_magnitude, secret, created_at, updated_at`
+
+func scanWebhook(row scanner) (w Webhook, err error) {
+	var region regionScanner
+
+	dest := []any{&w.ID, &w.URL}
+	dest = append(dest, region.dest()...)
+	dest = append(dest, &w.MinMagnitude, &w.Secret, &w.CreatedAt, &w.UpdatedAt)
+
+	if err := row.Scan(dest...); err != nil {
+		return w, err
+	}
+
+	w.Region = region.region()
+	return w, nil
+}
+
+// Insert stores a new webhook and returns its id.
+func (m *WebhookModel) Insert(w Webhook) (int64, error) {
+	now := time.Now().UTC()
+
+	args := []any{w.URL}
+	args = append(args, regionArgs(w.Region)...)
+	args = append(args, w.MinMagnitude, w.Secret, now, now)
+
+	result, err := m.DB.Exec(`INSERT INTO webhooks (
+		url, `+regionColumns+`, min_magnitude, secret, created_at, updated_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+func (m *WebhookModel) Get(id int64) (Webhook, error) {
+	w, err := scanWebhook(m.DB.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return w, ErrNoRecord
+	}
+	return w, err
+}
+
+// List returns webhooks oldest first and the total number of webhooks.
+func (m *WebhookModel) List(limit, offset int) (webhooks []Webhook, total int, err error) {
+	err = m.DB.QueryRow(`SELECT COUNT(*) FROM webhooks`).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := m.DB.Query(`SELECT `+webhookColumns+` FROM webhooks ORDER BY id LIMIT ? OFFSET ?`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	return webhooks, total, rows.Err()
+}
+
+// Matching returns the webhooks e should be delivered to.
+func (m *WebhookModel) Matching(e Entry) (webhooks []Webhook, err error) {
+	rows, err := m.DB.Query(`SELECT ` + webhookColumns + ` FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		if w.Matches(e) {
+			webhooks = append(webhooks, w)
+		}
+	}
+
+	return webhooks, rows.Err()
+}
+
+// Update replaces the subscription of the webhook w.ID.
+func (m *WebhookModel) Update(w Webhook) error {
+	args := []any{w.URL}
+	args = append(args, regionArgs(w.Region)...)
+	args = append(args, w.MinMagnitude, w.Secret, time.Now().UTC(), w.ID)
+
+	result, err := m.DB.Exec(`UPDATE webhooks SET
+		url = ?,
+		swlng = ?, swlat = ?, nelng = ?, nelat = ?,
+		latitude = ?, longitude = ?, radius_km = ?,
+		min_magnitude = ?,
+		secret = ?,
+		updated_at = ?
+	WHERE id = ?`, args...)
+	if err != nil {
+		return err
+	}
+
+	return requireRow(result)
+}
+
+// Delete removes a webhook, its delivery log and its pending deliveries.
+func (m *WebhookModel) Delete(id int64) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if err := requireRow(result); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM webhook_pending WHERE webhook_id = ?`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RecordDelivery adds an attempt to the delivery log.
+func (m *WebhookModel) RecordDelivery(d WebhookDelivery) (int64, error) {
+	result, err := m.DB.Exec(`INSERT INTO webhook_deliveries (
+		webhook_id,
+		guid,
+		change_id,
+		change,
+		attempt,
+		attempted_at,
+		status_code,
+		error
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.WebhookID,
+		d.GUID,
+		d.ChangeID,
+		d.Change,
+		d.Attempt,
+		d.AttemptedAt.UTC(),
+		d.StatusCode,
+		d.Error,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// Deliveries returns the delivery attempts of a webhook newest first and the
+// total number of attempts.
+func (m *WebhookModel) Deliveries(webhookID int64, limit, offset int) (deliveries []WebhookDelivery, total int, err error) {
+	err = m.DB.QueryRow(`SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = ?`, webhookID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `
+		SELECT
+			id,
+			webhook_id,
+			guid,
+			COALESCE(change_id, 0),
+			change,
+			attempt,
+			attempted_at,
+			COALESCE(status_code, 0),
+			COALESCE(error, '')
+		FROM webhook_deliveries
+		WHERE webhook_id = ?
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`
+	rows, err := m.DB.Query(stmt, webhookID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d WebhookDelivery
+
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.GUID, &d.ChangeID, &d.Change, &d.Attempt, &d.AttemptedAt, &d.StatusCode, &d.Error)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, total, rows.Err()
+}
+
+// InsertPending stores a delivery to attempt and returns its id, or 0 when
+// the change is pending for the webhook already.
+func (m *WebhookModel) InsertPending(p PendingDelivery) (int64, error) {
+	result, err := m.DB.Exec(`INSERT INTO webhook_pending (
+		webhook_id,
+		guid,
+		change_id,
+		change,
+		body,
+		attempt,
+		due_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (webhook_id, change_id) DO NOTHING`,
+		p.WebhookID,
+		p.GUID,
+		p.ChangeID,
+		p.Change,
+		p.Body,
+		p.Attempt,
+		p.DueAt.UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := requireRow(result); errors.Is(err, ErrNoRecord) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+const pendingColumns = `id, webhook_id, guid, change_id, change, body, attempt, due_at`
+
+func scanPending(row scanner) (p PendingDelivery, err error) {
+	err = row.Scan(&p.ID, &p.WebhookID, &p.GUID, &p.ChangeID, &p.Change, &p.Body, &p.Attempt, &p.DueAt)
+	return p, err
+}
+
+func (m *WebhookModel) GetPending(id int64) (PendingDelivery, error) {
+	p, err := scanPending(m.DB.QueryRow(`SELECT `+pendingColumns+` FROM webhook_pending WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, ErrNoRecord
+	}
+	return p, err
+}
+
+// Pending returns every pending delivery, the soonest due first.
+func (m *WebhookModel) Pending() (pending []PendingDelivery, err error) {
+	rows, err := m.DB.Query(`SELECT ` + pendingColumns + ` FROM webhook_pending ORDER BY due_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanPending(rows)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, p)
+	}
+
+	return pending, rows.Err()
+}
+
+// ReschedulePending sets the number and due time of the next attempt of a
+// pending delivery.
+func (m *WebhookModel) ReschedulePending(id int64, attempt int, dueAt time.Time) error {
+	result, err := m.DB.Exec(`UPDATE webhook_pending SET attempt = ?, due_at = ? WHERE id = ?`, attempt, dueAt.UTC(), id)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// DeletePending removes a delivery that needs no further attempts.
+func (m *WebhookModel) DeletePending(id int64) error {
+	result, err := m.DB.Exec(`DELETE FROM webhook_pending WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// requireRow returns ErrNoRecord when result affected no rows.
+func requireRow(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegionContains(t *testing.T) {
+	tests := []struct {
+		name     string
+		region   Region
+		lat, lng float64
+		want     bool
+	}{
+		{"world", Region{}, -60, 170, true},
+		{"in box", Region{Box: &Corners{-80, 40, -70, 50}}, 45, -75, true},
+		{"outside box", Region{Box: &Corners{-80, 40, -70, 50}}, 45, -60, false},
+		{"across antimeridian", Region{Box: &Corners{170, -50, -170, -30}}, -40, 179, true},
+		{"in circle", Region{Circle: &Circle{45.5, -73.6, 200}}, 46.35, -72.55, true},
+		{"outside circle", Region{Circle: &Circle{45.5, -73.6, 200}}, 43.7, -79.4, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.region.Contains(tt.lat, tt.lng); got != tt.want {
+			t.Errorf("%s: got %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookModel(t *testing.T) {
+	m := &WebhookModel{DB: newTestDB(t)}
+
+	minMag := 4.1
+	id, err := m.Insert(Webhook{
+		URL:          "https://example.com/hook",
+		Region:       Region{Circle: &Circle{45.5, -73.6, 200}},
+		MinMagnitude: &minMag,
+		Secret:       "0123456789abcdef",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Insert(Webhook{URL: "https://example.com/all", Region: Region{Box: &Corners{-180, -90, 180, 90}}, Secret: "s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.URL != "https://example.com/hook" || w.Region.Circle == nil || w.Region.Circle.RadiusKm != 200 || w.Region.Box != nil || *w.MinMagnitude != 4.1 || w.CreatedAt.IsZero() {
+		t.Errorf("got %+v", w)
+	}
+
+	// an M4.1 is at least 4.1 though its magnitude is stored in single precision
+	matching, err := m.Matching(Entry{Latitude: 46.35, Longitude: -72.55, Magnitude: 4.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matching) != 2 {
+		t.Errorf("got %d matching webhooks, want 2", len(matching))
+	}
+	matching, err = m.Matching(Entry{Latitude: 46.35, Longitude: -72.55, Magnitude: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matching) != 1 || matching[0].URL != "https://example.com/all" {
+		t.Errorf("got %+v, want only the webhook without a minimum", matching)
+	}
+
+	w.URL = "https://example.com/moved"
+	w.Region = Region{}
+	if err := m.Update(w); err != nil {
+		t.Fatal(err)
+	}
+	if w, err = m.Get(id); err != nil || w.URL != "https://example.com/moved" || w.Region.Circle != nil {
+		t.Errorf("got %+v, %v after update", w, err)
+	}
+
+	at := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	for attempt := 1; attempt <= 2; attempt++ {
+		if _, err := m.RecordDelivery(WebhookDelivery{WebhookID: id, GUID: "a", ChangeID: 1, Change: "created", Attempt: attempt, AttemptedAt: at, StatusCode: 500}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deliveries, total, err := m.Deliveries(id, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(deliveries) != 1 || deliveries[0].Attempt != 2 || deliveries[0].StatusCode != 500 || !deliveries[0].AttemptedAt.Equal(at) {
+		t.Errorf("got %+v of %d deliveries", deliveries, total)
+	}
+
+	webhooks, total, err := m.List(10, 0)
+	if err != nil || total != 2 || len(webhooks) != 2 {
+		t.Errorf("got %d of %d webhooks, %v", len(webhooks), total, err)
+	}
+
+	// a change is pending once for each webhook
+	pending := PendingDelivery{WebhookID: id, GUID: "a", ChangeID: 1, Change: "created", Body: []byte("{}"), Attempt: 1, DueAt: at}
+	pendingID, err := m.InsertPending(pending)
+	if err != nil || pendingID == 0 {
+		t.Fatalf("got id %d, %v", pendingID, err)
+	}
+	if again, err := m.InsertPending(pending); err != nil || again != 0 {
+		t.Errorf("got id %d, %v for a change pending already, want 0", again, err)
+	}
+	pending.ChangeID = 2
+	pending.DueAt = at.Add(-time.Minute)
+	if _, err := m.InsertPending(pending); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ReschedulePending(pendingID, 2, at.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	all, err := m.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].ID != pendingID || all[0].Attempt != 2 || string(all[0].Body) != "{}" || all[1].ChangeID != 2 {
+		t.Errorf("got %+v, want the rescheduled delivery first", all)
+	}
+	if err := m.DeletePending(pendingID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetPending(pendingID); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("got %v, want ErrNoRecord", err)
+	}
+
+	if err := m.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, _ := m.Deliveries(id, 10, 0); total != 0 {
+		t.Errorf("got %d deliveries after delete, want 0", total)
+	}
+	if all, _ := m.Pending(); len(all) != 0 {
+		t.Errorf("got %d pending deliveries after delete, want 0", len(all))
+	}
+	for _, err := range []error{m.Delete(id), m.Update(Webhook{ID: id})} {
+		if !errors.Is(err, ErrNoRecord) {
+			t.Errorf("got %v, want ErrNoRecord", err)
+		}
+	}
+	if _, err := m.Get(id); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("got %v, want ErrNoRecord", err)
+	}
+}
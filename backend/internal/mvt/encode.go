@@ -0,0 +1,213 @@
+package mvt
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// DefaultExtent is the number of units across a tile.
+const DefaultExtent = 4096
+
+// Layer is a named set of point features.
+type Layer struct {
+	Name     string
+	Extent   uint32
+	Features []Feature
+}
+
+// Feature is a point within a tile. Property values may be strings, bools,
+// ints, int64s, uint64s, float32s or float64s; any other type is an error.
+type Feature struct {
+	ID         uint64
+	X          int32
+	Y          int32
+	Properties map[string]any
+}
+
+// Field numbers and wire types of the vector tile protobuf schema.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+
+	tileLayers = 3
+
+	layerVersion  = 15
+	layerName     = 1
+	layerFeatures = 2
+	layerKeys     = 3
+	layerValues   = 4
+	layerExtent   = 5
+
+	featureID       = 1
+	featureTags     = 2
+	featureType     = 3
+	featureGeometry = 4
+
+	valueString = 1
+	valueFloat  = 2
+	valueDouble = 3
+	valueInt    = 4
+	valueUint   = 5
+	valueBool   = 7
+
+	geomTypePoint = 1
+	commandMoveTo = 1
+)
+
+// Encode returns the vector tile holding layers.
+func Encode(layers ...Layer) ([]byte, error) {
+	var tile []byte
+
+	for _, layer := range layers {
+		b, err := layer.encode()
+		if err != nil {
+			return nil, fmt.Errorf("layer %s: %w", layer.Name, err)
+		}
+		tile = appendBytes(tile, tileLayers, b)
+	}
+
+	return tile, nil
+}
+
+func (l Layer) encode() ([]byte, error) {
+	extent := l.Extent
+	if extent == 0 {
+		extent = DefaultExtent
+	}
+
+	// keys and values are shared by every feature of the layer and
+	// referenced by index from the feature tags
+	var keys []string
+	keyIndex := map[string]uint32{}
+	var values [][]byte
+	valueIndex := map[string]uint32{}
+
+	var b []byte
+	b = appendVarintField(b, layerVersion, 2)
+	b = appendBytes(b, layerName, []byte(l.Name))
+
+	for _, f := range l.Features {
+		var tags []uint32
+
+		// sorted so the same features always give the same bytes
+		names := make([]string, 0, len(f.Properties))
+		for name := range f.Properties {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		for _, name := range names {
+			value, err := encodeValue(f.Properties[name])
+			if err != nil {
+				return nil, fmt.Errorf("property %s: %w", name, err)
+			}
+
+			k, ok := keyIndex[name]
+			if !ok {
+				k = uint32(len(keys))
+				keyIndex[name] = k
+				keys = append(keys, name)
+			}
+
+			v, ok := valueIndex[string(value)]
+			if !ok {
+				v = uint32(len(values))
+				valueIndex[string(value)] = v
+				values = append(values, value)
+			}
+
+			tags = append(tags, k, v)
+		}
+
+		var fb []byte
+		if f.ID != 0 {
+			fb = appendVarintField(fb, featureID, f.ID)
+		}
+		if len(tags) > 0 {
+			fb = appendPacked(fb, featureTags, tags)
+		}
+		fb = appendVarintField(fb, featureType, geomTypePoint)
+		fb = appendPacked(fb, featureGeometry, []uint32{command(commandMoveTo, 1), zigzag(f.X), zigzag(f.Y)})
+
+		b = appendBytes(b, layerFeatures, fb)
+	}
+
+	for _, key := range keys {
+		b = appendBytes(b, layerKeys, []byte(key))
+	}
+	for _, value := range values {
+		b = appendBytes(b, layerValues, value)
+	}
+	b = appendVarintField(b, layerExtent, uint64(extent))
+
+	return b, nil
+}
+
+// encodeValue returns the Value message for v.
+func encodeValue(v any) ([]byte, error) {
+	var b []byte
+
+	switch v := v.(type) {
+	case string:
+		b = appendBytes(b, valueString, []byte(v))
+	case bool:
+		var n uint64
+		if v {
+			n = 1
+		}
+		b = appendVarintField(b, valueBool, n)
+	case int:
+		b = appendVarintField(b, valueInt, uint64(int64(v)))
+	case int64:
+		b = appendVarintField(b, valueInt, uint64(v))
+	case uint64:
+		b = appendVarintField(b, valueUint, v)
+	case float32:
+		b = appendTag(b, valueFloat, wireFixed32)
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+	case float64:
+		b = appendTag(b, valueDouble, wireFixed64)
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", v)
+	}
+
+	return b, nil
+}
+
+// command returns a geometry command integer.
+func command(id, count uint32) uint32 {
+	return id&0x7 | count<<3
+}
+
+// zigzag maps signed parameters to unsigned so small magnitudes stay small.
+func zigzag(n int32) uint32 {
+	return uint32((n << 1) ^ (n >> 31))
+}
+
+func appendTag(b []byte, field, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wire))
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	b = appendTag(b, field, wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendBytes(b []byte, field int, v []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendPacked(b []byte, field int, vs []uint32) []byte {
+	var packed []byte
+	for _, v := range vs {
+		packed = binary.AppendUvarint(packed, uint64(v))
+	}
+	return appendBytes(b, field, packed)
+}
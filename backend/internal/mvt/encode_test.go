@@ -0,0 +1,198 @@
+package mvt
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// field is one decoded protobuf field. Varints and fixed values are in n,
+// length delimited values in b.
+type field struct {
+	num int
+	n   uint64
+	b   []byte
+}
+
+func decode(t *testing.T, b []byte) []field {
+	t.Helper()
+
+	var fields []field
+	for len(b) > 0 {
+		tag, k := binary.Uvarint(b)
+		if k <= 0 {
+			t.Fatalf("bad tag in % x", b)
+		}
+		b = b[k:]
+
+		f := field{num: int(tag >> 3)}
+		switch tag & 0x7 {
+		case wireVarint:
+			f.n, k = binary.Uvarint(b)
+			b = b[k:]
+		case wireFixed64:
+			f.n = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case wireFixed32:
+			f.n = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		case wireBytes:
+			n, k := binary.Uvarint(b)
+			f.b = b[k : k+int(n)]
+			b = b[k+int(n):]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&0x7)
+		}
+		fields = append(fields, f)
+	}
+
+	return fields
+}
+
+func packed(t *testing.T, b []byte) []uint32 {
+	t.Helper()
+
+	var vs []uint32
+	for len(b) > 0 {
+		v, k := binary.Uvarint(b)
+		vs = append(vs, uint32(v))
+		b = b[k:]
+	}
+	return vs
+}
+
+func TestEncode(t *testing.T) {
+	b, err := Encode(Layer{
+		Name: "earthquakes",
+		Features: []Feature{
+			{ID: 7, X: 10, Y: -3, Properties: map[string]any{"mag": 2.5, "place": "Ottawa", "felt": true}},
+			{ID: 8, X: 4096, Y: 0, Properties: map[string]any{"mag": 2.5, "depth": int64(-12)}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tile := decode(t, b)
+	if len(tile) != 1 || tile[0].num != tileLayers {
+		t.Fatalf("got tile fields %+v", tile)
+	}
+
+	var version, extent uint64
+	var name string
+	var keys []string
+	var values []field
+	var features [][]field
+	for _, f := range decode(t, tile[0].b) {
+		switch f.num {
+		case layerVersion:
+			version = f.n
+		case layerName:
+			name = string(f.b)
+		case layerExtent:
+			extent = f.n
+		case layerKeys:
+			keys = append(keys, string(f.b))
+		case layerValues:
+			values = append(values, decode(t, f.b)[0])
+		case layerFeatures:
+			features = append(features, decode(t, f.b))
+		}
+	}
+
+	if version != 2 || name != "earthquakes" || extent != DefaultExtent {
+		t.Errorf("got version %d, name %q, extent %d", version, name, extent)
+	}
+	if want := []string{"felt", "mag", "place", "depth"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("got keys %v, want %v", keys, want)
+	}
+
+	// the shared 2.5 magnitude is stored once
+	if len(values) != 4 {
+		t.Fatalf("got %d values, want 4", len(values))
+	}
+	if v := values[0]; v.num != valueBool || v.n != 1 {
+		t.Errorf("got felt %+v", v)
+	}
+	if v := values[1]; v.num != valueDouble || math.Float64frombits(v.n) != 2.5 {
+		t.Errorf("got mag %+v", v)
+	}
+	if v := values[2]; v.num != valueString || string(v.b) != "Ottawa" {
+		t.Errorf("got place %+v", v)
+	}
+	if v := values[3]; v.num != valueInt || int64(v.n) != -12 {
+		t.Errorf("got depth %+v", v)
+	}
+
+	if len(features) != 2 {
+		t.Fatalf("got %d features, want 2", len(features))
+	}
+
+	tests := []struct {
+		id       uint64
+		tags     []uint32
+		geometry []uint32
+	}{
+		{7, []uint32{0, 0, 1, 1, 2, 2}, []uint32{9, 20, 5}},
+		{8, []uint32{3, 3, 1, 1}, []uint32{9, 8192, 0}},
+	}
+	for i, tt := range tests {
+		var id, geomType uint64
+		var tags, geometry []uint32
+		for _, f := range features[i] {
+			switch f.num {
+			case featureID:
+				id = f.n
+			case featureType:
+				geomType = f.n
+			case featureTags:
+				tags = packed(t, f.b)
+			case featureGeometry:
+				geometry = packed(t, f.b)
+			}
+		}
+
+		if id != tt.id || geomType != geomTypePoint {
+			t.Errorf("feature %d: got id %d, type %d", i, id, geomType)
+		}
+		if !reflect.DeepEqual(tags, tt.tags) {
+			t.Errorf("feature %d: got tags %v, want %v", i, tags, tt.tags)
+		}
+		if !reflect.DeepEqual(geometry, tt.geometry) {
+			t.Errorf("feature %d: got geometry %v, want %v", i, geometry, tt.geometry)
+		}
+	}
+}
+
+func TestEncodeDeterministic(t *testing.T) {
+	layer := Layer{Name: "l", Features: []Feature{
+		{X: 1, Y: 2, Properties: map[string]any{"a": 1, "b": "x", "c": float32(1.5), "d": uint64(3)}},
+	}}
+
+	first, err := Encode(layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 20 {
+		b, _ := Encode(layer)
+		if !reflect.DeepEqual(b, first) {
+			t.Fatal("encoding the same layer gave different bytes")
+		}
+	}
+}
+
+func TestEncodeUnsupportedValue(t *testing.T) {
+	_, err := Encode(Layer{Name: "l", Features: []Feature{{Properties: map[string]any{"a": []int{1}}}}})
+	if err == nil {
+		t.Error("got no error for a slice property")
+	}
+}
+
+func TestZigzag(t *testing.T) {
+	for n, want := range map[int32]uint32{0: 0, -1: 1, 1: 2, -2: 3, 2147483647: 4294967294, -2147483648: 4294967295} {
+		if got := zigzag(n); got != want {
+			t.Errorf("zigzag(%d) = %d, want %d", n, got, want)
+		}
+	}
+}
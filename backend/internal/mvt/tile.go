@@ -0,0 +1,78 @@
+// Package mvt encodes points as Mapbox Vector Tiles (version 2.1) and does
+// the web mercator tile math needed to place them.
+package mvt
+
+import (
+	"fmt"
+	"math"
+)
+
+// MaxLatitude is the latitude where web mercator tiles end.
+const MaxLatitude = 85.05112877980659
+
+// TileID is a web mercator tile, numbered from the north west.
+type TileID struct {
+	Z int
+	X int
+	Y int
+}
+
+// NewTileID checks that x and y fall within the tiles of zoom z.
+func NewTileID(z, x, y, maxZoom int) (TileID, error) {
+	if z < 0 || z > maxZoom {
+		return TileID{}, fmt.Errorf("zoom must be between 0 and %d, got %d", maxZoom, z)
+	}
+
+	n := 1 << z
+	if x < 0 || x >= n || y < 0 || y >= n {
+		return TileID{}, fmt.Errorf("x and y must be between 0 and %d at zoom %d, got %d/%d", n-1, z, x, y)
+	}
+
+	return TileID{Z: z, X: x, Y: y}, nil
+}
+
+func (t TileID) String() string {
+	return fmt.Sprintf("%d/%d/%d", t.Z, t.X, t.Y)
+}
+
+// Bounds returns the longitudes and latitudes of the tile's edges, grown by
+// buffer tile widths on every side.
+func (t TileID) Bounds(buffer float64) (swlng, swlat, nelng, nelat float64) {
+	n := math.Exp2(float64(t.Z))
+
+	swlng = tileLng(float64(t.X)-buffer, n)
+	nelng = tileLng(float64(t.X+1)+buffer, n)
+	swlat = tileLat(float64(t.Y+1)+buffer, n)
+	nelat = tileLat(float64(t.Y)-buffer, n)
+
+	return swlng, swlat, nelng, nelat
+}
+
+// Project returns the position of a point within the tile, in a grid of
+// extent units per side with the origin in the top left corner. Points off
+// the tile fall outside 0..extent.
+func (t TileID) Project(lat, lng float64, extent uint32) (x, y int32) {
+	n := math.Exp2(float64(t.Z))
+
+	lat = math.Max(-MaxLatitude, math.Min(MaxLatitude, lat))
+	φ := lat * math.Pi / 180
+
+	wx := (lng + 180) / 360 * n
+	wy := (1 - math.Log(math.Tan(φ)+1/math.Cos(φ))/math.Pi) / 2 * n
+
+	x = int32(math.Round((wx - float64(t.X)) * float64(extent)))
+	y = int32(math.Round((wy - float64(t.Y)) * float64(extent)))
+
+	return x, y
+}
+
+// tileLng returns the longitude of the western edge of tile column x.
+func tileLng(x, n float64) float64 {
+	return math.Max(-180, math.Min(180, x/n*360-180))
+}
+
+// tileLat returns the latitude of the northern edge of tile row y.
+func tileLat(y, n float64) float64 {
+	y = math.Max(0, math.Min(n, y))
+	return math.Atan(math.Sinh(math.Pi*(1-2*y/n))) * 180 / math.Pi
+}
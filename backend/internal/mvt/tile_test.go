@@ -0,0 +1,83 @@
+package mvt
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewTileID(t *testing.T) {
+	tests := []struct {
+		z, x, y int
+		ok      bool
+	}{
+		{0, 0, 0, true},
+		{3, 7, 7, true},
+		{3, 8, 0, false},
+		{3, 0, -1, false},
+		{-1, 0, 0, false},
+		{23, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		_, err := NewTileID(tt.z, tt.x, tt.y, 22)
+		if (err == nil) != tt.ok {
+			t.Errorf("NewTileID(%d, %d, %d) = %v, want ok %v", tt.z, tt.x, tt.y, err, tt.ok)
+		}
+	}
+}
+
+func TestTileBounds(t *testing.T) {
+	tests := []struct {
+		name                       string
+		tile                       TileID
+		buffer                     float64
+		swlng, swlat, nelng, nelat float64
+	}{
+		{"world", TileID{0, 0, 0}, 0, -180, -MaxLatitude, 180, MaxLatitude},
+		{"north west quarter", TileID{1, 0, 0}, 0, -180, 0, 0, MaxLatitude},
+		{"south east quarter", TileID{1, 1, 1}, 0, 0, -MaxLatitude, 180, 0},
+		{"ottawa", TileID{6, 18, 22}, 0, -78.75, 45.0890356, -73.125, 48.9224993},
+		{"buffer", TileID{2, 1, 1}, 0.5, -135, -40.9798981, 45, 79.1713346},
+		{"buffer clamped at the edges", TileID{1, 0, 0}, 0.5, -180, -66.5132604, 90, MaxLatitude},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			swlng, swlat, nelng, nelat := tt.tile.Bounds(tt.buffer)
+
+			got := []float64{swlng, swlat, nelng, nelat}
+			want := []float64{tt.swlng, tt.swlat, tt.nelng, tt.nelat}
+			for i := range got {
+				if math.Abs(got[i]-want[i]) > 1e-6 {
+					t.Errorf("got %v, want %v", got, want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestTileProject(t *testing.T) {
+	tests := []struct {
+		name     string
+		tile     TileID
+		lat, lng float64
+		x, y     int32
+	}{
+		{"world centre", TileID{0, 0, 0}, 0, 0, 2048, 2048},
+		{"north west corner", TileID{0, 0, 0}, MaxLatitude, -180, 0, 0},
+		{"south east corner", TileID{1, 1, 1}, -MaxLatitude, 180, 4096, 4096},
+		{"tile corner", TileID{1, 1, 1}, 0, 0, 0, 0},
+		{"off the tile", TileID{1, 1, 1}, 10, -10, -228, -229},
+		{"clamped past the pole", TileID{0, 0, 0}, 90, 0, 2048, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x, y := tt.tile.Project(tt.lat, tt.lng, DefaultExtent)
+			if x != tt.x || y != tt.y {
+				t.Errorf("got %d, %d, want %d, %d", x, y, tt.x, tt.y)
+			}
+		})
+	}
+}
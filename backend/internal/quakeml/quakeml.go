@@ -0,0 +1,358 @@
+// Package quakeml reads and writes QuakeML 1.2 event parameters.
+// https://quake.ethz.ch/quakeml/
+package quakeml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+// publicIDPrefix is used for resource identifiers of exported entries.
+const publicIDPrefix = "smi:local/"
+
+var (
+	ErrNoOrigin    = errors.New("event has no origin")
+	ErrNoMagnitude = errors.New("event has no magnitude")
+	ErrNoPublicID  = errors.New("event has no publicID")
+	ErrOutOfRange  = errors.New("out of range")
+)
+
+// Fields reported in a FieldError.
+const (
+	FieldLatitude  = "latitude"
+	FieldLongitude = "longitude"
+	FieldMagnitude = "magnitude"
+)
+
+// -----------------------------------------------------------------------------
+// Types
+// -----------------------------------------------------------------------------
+
+// FieldError describes a value of an event that can't be stored.
+type FieldError struct {
+	Field string
+	Value float64
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s %g", e.Field, e.Err, e.Value)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+type Document struct {
+	XMLName         xml.Name        `xml:"http://quakeml.org/xmlns/quakeml/1.2 quakeml"`
+	EventParameters EventParameters `xml:"http://quakeml.org/xmlns/bed/1.2 eventParameters"`
+}
+
+type EventParameters struct {
+	PublicID string  `xml:"publicID,attr"`
+	Events   []Event `xml:"event"`
+}
+
+type Event struct {
+	PublicID             string        `xml:"publicID,attr"`
+	PreferredOriginID    string        `xml:"preferredOriginID,omitempty"`
+	PreferredMagnitudeID string        `xml:"preferredMagnitudeID,omitempty"`
+	Type                 string        `xml:"type,omitempty"`
+	Descriptions         []Description `xml:"description"`
+	CreationInfo         *CreationInfo `xml:"creationInfo"`
+	Origins              []Origin      `xml:"origin"`
+	Magnitudes           []Magnitude   `xml:"magnitude"`
+}
+
+type Description struct {
+	Text string `xml:"text"`
+	Type string `xml:"type,omitempty"`
+}
+
+type CreationInfo struct {
+	AgencyID     string `xml:"agencyID,omitempty"`
+	CreationTime string `xml:"creationTime,omitempty"`
+}
+
+type Origin struct {
+	PublicID  string        `xml:"publicID,attr"`
+	Time      *TimeQuantity `xml:"time"`
+	Latitude  RealQuantity  `xml:"latitude"`
+	Longitude RealQuantity  `xml:"longitude"`
+	Depth     *RealQuantity `xml:"depth"`
+}
+
+type Magnitude struct {
+	PublicID string       `xml:"publicID,attr"`
+	Mag      RealQuantity `xml:"mag"`
+	Type     string       `xml:"type,omitempty"`
+	OriginID string       `xml:"originID,omitempty"`
+}
+
+type TimeQuantity struct {
+	Value string `xml:"value"`
+}
+
+type RealQuantity struct {
+	Value       float64  `xml:"value"`
+	Uncertainty *float64 `xml:"uncertainty,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// Public
+// -----------------------------------------------------------------------------
+
+// Decode reads a QuakeML document from r.
+func Decode(r io.Reader) (*Document, error) {
+	var doc Document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decoding quakeml: %w", err)
+	}
+
+	return &doc, nil
+}
+
+// Encode writes entries to w as a QuakeML document.
+func Encode(w io.Writer, entries []models.Entry) error {
+	doc := Document{
+		EventParameters: EventParameters{
+			PublicID: publicIDPrefix + "eventParameters",
+		},
+	}
+
+	for _, entry := range entries {
+		doc.EventParameters.Events = append(doc.EventParameters.Events, FromEntry(entry))
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Entry converts the preferred origin and magnitude of the event into an
+// entry. A latitude, longitude or magnitude out of range is reported as a
+// FieldError.
+func (e Event) Entry() (entry models.Entry, err error) {
+	if e.PublicID == "" {
+		return entry, ErrNoPublicID
+	}
+
+	origin := e.preferredOrigin()
+	if origin == nil {
+		return entry, ErrNoOrigin
+	}
+
+	magnitude := e.preferredMagnitude()
+	if magnitude == nil {
+		return entry, ErrNoMagnitude
+	}
+
+	if origin.Time == nil {
+		return entry, errors.New("origin has no time")
+	}
+	t, err := parseTime(origin.Time.Value)
+	if err != nil {
+		return entry, fmt.Errorf("origin time: %w", err)
+	}
+
+	// the same ranges as the feeds and the query parameters
+	for _, v := range []struct {
+		field  string
+		value  float64
+		lo, hi float64
+	}{
+		{FieldLatitude, origin.Latitude.Value, -90, 90},
+		{FieldLongitude, origin.Longitude.Value, -180, 180},
+		{FieldMagnitude, magnitude.Mag.Value, -2, 10},
+	} {
+		if !(v.value >= v.lo && v.value <= v.hi) {
+			return entry, &FieldError{Field: v.field, Value: v.value, Err: ErrOutOfRange}
+		}
+	}
+
+	entry = models.Entry{
+		GUID:          guid(e.PublicID),
+		Content:       e.region(),
+		Place:         e.region(),
+		Categories:    e.Type,
+		Latitude:      float32(origin.Latitude.Value),
+		Longitude:     float32(origin.Longitude.Value),
+		Magnitude:     float32(magnitude.Mag.Value),
+		MagnitudeType: magnitude.Type,
+		Time:          &t,
+	}
+
+	entry.Title = strings.TrimSuffix(fmt.Sprintf("M%.1f - %s", magnitude.Mag.Value, entry.Place), " - ")
+
+	if magnitude.Mag.Uncertainty != nil {
+		uncertainty := float32(*magnitude.Mag.Uncertainty)
+		entry.MagnitudeUncertainty = &uncertainty
+	}
+
+	// depth is in metres below the surface
+	if origin.Depth != nil {
+		entry.Elevation = int32(math.Round(-origin.Depth.Value))
+	}
+
+	if e.CreationInfo != nil && e.CreationInfo.CreationTime != "" {
+		created, err := parseTime(e.CreationInfo.CreationTime)
+		if err == nil {
+			entry.Published = &created
+			entry.Updated = &created
+		}
+	}
+
+	return entry, nil
+}
+
+// FromEntry converts an entry into an event with a single origin and
+// magnitude.
+func FromEntry(entry models.Entry) Event {
+	id := url.PathEscape(entry.GUID)
+	originID := publicIDPrefix + "origin/" + id
+	magnitudeID := publicIDPrefix + "magnitude/" + id
+
+	event := Event{
+		PublicID:             eventID(entry.GUID),
+		PreferredOriginID:    originID,
+		PreferredMagnitudeID: magnitudeID,
+		Type:                 "earthquake",
+		Origins: []Origin{{
+			PublicID:  originID,
+			Latitude:  RealQuantity{Value: models.Widen(entry.Latitude)},
+			Longitude: RealQuantity{Value: models.Widen(entry.Longitude)},
+			Depth:     &RealQuantity{Value: -float64(entry.Elevation)},
+		}},
+		Magnitudes: []Magnitude{{
+			PublicID: magnitudeID,
+			Mag:      RealQuantity{Value: models.Widen(entry.Magnitude)},
+			Type:     entry.MagnitudeType,
+			OriginID: originID,
+		}},
+	}
+
+	// without a time the element is left out rather than left empty
+	if entry.Time != nil {
+		event.Origins[0].Time = &TimeQuantity{Value: entry.Time.UTC().Format(time.RFC3339Nano)}
+	}
+
+	if entry.MagnitudeUncertainty != nil {
+		uncertainty := models.Widen(*entry.MagnitudeUncertainty)
+		event.Magnitudes[0].Mag.Uncertainty = &uncertainty
+	}
+
+	if entry.Place != "" {
+		event.Descriptions = append(event.Descriptions, Description{Text: entry.Place, Type: "region name"})
+	}
+
+	created := entry.Updated
+	if created == nil {
+		created = entry.Published
+	}
+	if created != nil {
+		event.CreationInfo = &CreationInfo{CreationTime: created.UTC().Format(time.RFC3339Nano)}
+	}
+
+	return event
+}
+
+// -----------------------------------------------------------------------------
+// Utilities
+// -----------------------------------------------------------------------------
+
+func (e Event) preferredOrigin() *Origin {
+	for i := range e.Origins {
+		if e.Origins[i].PublicID == e.PreferredOriginID {
+			return &e.Origins[i]
+		}
+	}
+
+	if len(e.Origins) > 0 {
+		return &e.Origins[0]
+	}
+
+	return nil
+}
+
+func (e Event) preferredMagnitude() *Magnitude {
+	for i := range e.Magnitudes {
+		if e.Magnitudes[i].PublicID == e.PreferredMagnitudeID {
+			return &e.Magnitudes[i]
+		}
+	}
+
+	if len(e.Magnitudes) > 0 {
+		return &e.Magnitudes[0]
+	}
+
+	return nil
+}
+
+func (e Event) region() string {
+	for _, d := range e.Descriptions {
+		if d.Type == "region name" || d.Type == "" {
+			return d.Text
+		}
+	}
+
+	if len(e.Descriptions) > 0 {
+		return e.Descriptions[0].Text
+	}
+
+	return ""
+}
+
+// eventID returns the resource identifier of an entry. GUIDs that already are
+// resource identifiers, from imported QuakeML, are kept as they are.
+func eventID(guid string) string {
+	if strings.HasPrefix(guid, "smi:") || strings.HasPrefix(guid, "quakeml:") {
+		return guid
+	}
+
+	return publicIDPrefix + "event/" + url.PathEscape(guid)
+}
+
+// guid reverses eventID so exported events import with their original GUID.
+func guid(publicID string) string {
+	id, ok := strings.CutPrefix(publicID, publicIDPrefix+"event/")
+	if !ok {
+		return publicID
+	}
+
+	unescaped, err := url.PathUnescape(id)
+	if err != nil {
+		return publicID
+	}
+
+	return unescaped
+}
+
+// parseTime accepts xs:dateTime values with or without a zone, times without
+// a zone are taken as UTC.
+func parseTime(v string) (time.Time, error) {
+	v = strings.TrimSpace(v)
+
+	t, err := time.Parse(time.RFC3339Nano, v)
+	if err == nil {
+		return t.UTC(), nil
+	}
+
+	return time.Parse("2006-01-02T15:04:05.999999999", v)
+}
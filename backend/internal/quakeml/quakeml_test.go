@@ -0,0 +1,189 @@
+package quakeml
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+func TestDecode(t *testing.T) {
+	f, err := os.Open("testdata/events.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	doc, err := Decode(f)
+	if err != nil {
+		t.Fatalf("Decode: unexpected error %s", err)
+	}
+
+	events := doc.EventParameters.Events
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+
+	entry, err := events[0].Entry()
+	if err != nil {
+		t.Fatalf("Entry: unexpected error %s", err)
+	}
+
+	if entry.GUID != "smi:ca.gc.nrcan/event/20251016.2200001" {
+		t.Errorf("GUID = %q", entry.GUID)
+	}
+	// the preferred origin and magnitude are used, not the first ones
+	if entry.Latitude != 49.1032 || entry.Longitude != -122.6891 {
+		t.Errorf("Latitude, Longitude = %v, %v, want 49.1032, -122.6891", entry.Latitude, entry.Longitude)
+	}
+	if entry.Elevation != -18420 {
+		t.Errorf("Elevation = %d, want -18420", entry.Elevation)
+	}
+	if entry.Magnitude != 2.6 || entry.MagnitudeType != "ML" {
+		t.Errorf("Magnitude = %v %s, want 2.6 ML", entry.Magnitude, entry.MagnitudeType)
+	}
+	if entry.MagnitudeUncertainty == nil || *entry.MagnitudeUncertainty != 0.2 {
+		t.Errorf("MagnitudeUncertainty = %v, want 0.2", entry.MagnitudeUncertainty)
+	}
+	if entry.Place != "12 km NNE of Blaine, WA" {
+		t.Errorf("Place = %q", entry.Place)
+	}
+	if entry.Title != "M2.6 - 12 km NNE of Blaine, WA" {
+		t.Errorf("Title = %q", entry.Title)
+	}
+
+	wantTime := time.Date(2025, 10, 16, 22, 0, 0, 500_000_000, time.UTC)
+	if entry.Time == nil || !entry.Time.Equal(wantTime) {
+		t.Errorf("Time = %v, want %v", entry.Time, wantTime)
+	}
+
+	_, err = events[1].Entry()
+	if !errors.Is(err, ErrNoMagnitude) {
+		t.Errorf("Entry without magnitude: got error %v, want %v", err, ErrNoMagnitude)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	_, err := Decode(bytes.NewBufferString(`<quakeml xmlns="http://example.com/"></quakeml>`))
+	if err == nil {
+		t.Fatal("Decode: expected error for wrong namespace")
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	f, err := os.Open("testdata/events.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	doc, err := Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := doc.EventParameters.Events[0].Entry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// entries from other sources get a local resource identifier
+	other := want
+	other.GUID = "usgs:uw62100001"
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, []models.Entry{want, other}); err != nil {
+		t.Fatalf("Encode: unexpected error %s", err)
+	}
+
+	doc, err = Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode of encoded document: unexpected error %s", err)
+	}
+	if len(doc.EventParameters.Events) != 2 {
+		t.Fatalf("got %d events, want 2", len(doc.EventParameters.Events))
+	}
+
+	if id := doc.EventParameters.Events[1].PublicID; id != "smi:local/event/usgs:uw62100001" {
+		t.Errorf("PublicID = %q, want %q", id, "smi:local/event/usgs:uw62100001")
+	}
+	if entry, _ := doc.EventParameters.Events[1].Entry(); entry.GUID != other.GUID {
+		t.Errorf("GUID = %q, want %q", entry.GUID, other.GUID)
+	}
+
+	got, err := doc.EventParameters.Events[0].Entry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.GUID != want.GUID {
+		t.Errorf("GUID = %q, want %q", got.GUID, want.GUID)
+	}
+	if got.Latitude != want.Latitude || got.Longitude != want.Longitude || got.Elevation != want.Elevation {
+		t.Errorf("location = %v %v %d, want %v %v %d", got.Latitude, got.Longitude, got.Elevation, want.Latitude, want.Longitude, want.Elevation)
+	}
+	if got.Magnitude != want.Magnitude || got.MagnitudeType != want.MagnitudeType || *got.MagnitudeUncertainty != *want.MagnitudeUncertainty {
+		t.Errorf("magnitude = %v %s, want %v %s", got.Magnitude, got.MagnitudeType, want.Magnitude, want.MagnitudeType)
+	}
+	if !got.Time.Equal(*want.Time) {
+		t.Errorf("Time = %v, want %v", got.Time, want.Time)
+	}
+	if got.Place != want.Place {
+		t.Errorf("Place = %q, want %q", got.Place, want.Place)
+	}
+}
+
+func TestEncodeWithoutTime(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, []models.Entry{{GUID: "no time", Latitude: 45, Longitude: -75, Magnitude: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "<time>") {
+		t.Errorf("got an origin time for an entry without one:\n%s", buf.String())
+	}
+
+	doc, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doc.EventParameters.Events[0].Entry(); err == nil {
+		t.Error("got an entry from an origin without a time")
+	}
+}
+
+func TestEntryOutOfRange(t *testing.T) {
+	event := func(lat, lng, mag float64) Event {
+		return Event{
+			PublicID:   "smi:test/event/1",
+			Origins:    []Origin{{Time: &TimeQuantity{Value: "2025-10-16T22:00:00Z"}, Latitude: RealQuantity{Value: lat}, Longitude: RealQuantity{Value: lng}}},
+			Magnitudes: []Magnitude{{Mag: RealQuantity{Value: mag}}},
+		}
+	}
+
+	for _, tt := range []struct {
+		event Event
+		field string
+	}{
+		{event(45, -75, 2), ""},
+		{event(90, 180, 10), ""},
+		{event(91, -75, 2), FieldLatitude},
+		{event(45, -181, 2), FieldLongitude},
+		{event(45, -75, 11), FieldMagnitude},
+		{event(45, -75, math.NaN()), FieldMagnitude},
+	} {
+		_, err := tt.event.Entry()
+
+		var fieldErr *FieldError
+		switch {
+		case tt.field == "" && err != nil:
+			t.Errorf("got %v for an event in range", err)
+		case tt.field != "" && (!errors.As(err, &fieldErr) || fieldErr.Field != tt.field || !errors.Is(err, ErrOutOfRange)):
+			t.Errorf("got %v, want %s out of range", err, tt.field)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package seismicity
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/earthquake-service/internal/geo"
+)
+
+// Event is an earthquake to decluster.
+type Event struct {
+	ID        int64
+	Time      time.Time
+	Latitude  float64
+	Longitude float64
+	Magnitude float64
+}
+
+// Assignment places an event in a sequence, which is identified by the ID of
+// its mainshock.
+type Assignment struct {
+	SequenceID int64
+	Mainshock  bool
+}
+
+// GardnerKnopoffWindow returns how far in distance and time a mainshock of
+// magnitude m keeps its aftershocks, using the windows of Gardner and
+// Knopoff (1974) as fitted by van Stiphout et al. (2012).
+func GardnerKnopoffWindow(m float64) (distanceKm float64, window time.Duration) {
+	distanceKm = math.Pow(10, 0.1238*m+0.983)
+
+	days := math.Pow(10, 0.5409*m-0.547)
+	if m >= 6.5 {
+		days = math.Pow(10, 0.032*m+2.7389)
+	}
+
+	return distanceKm, time.Duration(days * 24 * float64(time.Hour))
+}
+
+// Decluster groups events into sequences with Gardner-Knopoff windows. The
+// largest event not yet in a sequence becomes a mainshock, and every other
+// unassigned event within its distance window and within its time window
+// either side of it joins its sequence, the earlier ones as foreshocks and
+// the later ones as aftershocks. Events on their own are a sequence of one.
+// Ties in magnitude go to the earlier event.
+func Decluster(events []Event) map[int64]Assignment {
+	byTime := slices.Clone(events)
+	slices.SortFunc(byTime, func(a, b Event) int {
+		return cmp.Or(a.Time.Compare(b.Time), cmp.Compare(a.ID, b.ID))
+	})
+
+	order := make([]int, len(byTime))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(i, j int) int {
+		return cmp.Compare(byTime[j].Magnitude, byTime[i].Magnitude)
+	})
+
+	assignments := make(map[int64]Assignment, len(events))
+
+	for _, i := range order {
+		mainshock := byTime[i]
+		if _, ok := assignments[mainshock.ID]; ok {
+			continue
+		}
+		assignments[mainshock.ID] = Assignment{SequenceID: mainshock.ID, Mainshock: true}
+
+		distanceKm, window := GardnerKnopoffWindow(mainshock.Magnitude)
+		first, _ := slices.BinarySearchFunc(byTime, mainshock.Time.Add(-window), func(e Event, t time.Time) int {
+			return e.Time.Compare(t)
+		})
+		last := mainshock.Time.Add(window)
+
+		for _, e := range byTime[first:] {
+			if e.Time.After(last) {
+				break
+			}
+			if _, ok := assignments[e.ID]; ok {
+				continue
+			}
+			if geo.DistanceKm(mainshock.Latitude, mainshock.Longitude, e.Latitude, e.Longitude) > distanceKm {
+				continue
+			}
+			assignments[e.ID] = Assignment{SequenceID: mainshock.ID}
+		}
+	}
+
+	return assignments
+}
@@ -0,0 +1,69 @@
+package seismicity
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGardnerKnopoffWindow(t *testing.T) {
+	tests := []struct {
+		m          float64
+		distanceKm float64
+		days       float64
+	}{
+		{2, 17.0, 3.42},
+		{5, 40.0, 143.7},
+		{7, 70.8, 918.1},
+	}
+
+	for _, tt := range tests {
+		distanceKm, window := GardnerKnopoffWindow(tt.m)
+		if math.Abs(distanceKm-tt.distanceKm) > 0.1 {
+			t.Errorf("M%g: got %g km, want %g", tt.m, distanceKm, tt.distanceKm)
+		}
+		if days := window.Hours() / 24; math.Abs(days-tt.days) > 0.1 {
+			t.Errorf("M%g: got %g days, want %g", tt.m, days, tt.days)
+		}
+	}
+}
+
+func TestDecluster(t *testing.T) {
+	t0 := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	// 0.1 degrees of latitude is about 11 km
+	events := []Event{
+		{ID: 1, Time: t0, Latitude: 40, Longitude: 140, Magnitude: 6},
+		{ID: 2, Time: t0.Add(day), Latitude: 40.1, Longitude: 140, Magnitude: 4},
+		{ID: 3, Time: t0.Add(-day), Latitude: 39.95, Longitude: 140, Magnitude: 3},
+		{ID: 4, Time: t0.Add(day), Latitude: 45, Longitude: 140, Magnitude: 4},
+		{ID: 5, Time: t0.Add(2000 * day), Latitude: 40, Longitude: 140, Magnitude: 3},
+		// an M4 aftershock of the M4 at 45N, outside the M6 windows
+		{ID: 6, Time: t0.Add(2 * day), Latitude: 45.1, Longitude: 140, Magnitude: 4},
+		// equal magnitudes, so the earlier one is the mainshock
+		{ID: 8, Time: t0.Add(100*day + time.Hour), Latitude: -20, Longitude: -70, Magnitude: 2},
+		{ID: 7, Time: t0.Add(100 * day), Latitude: -20, Longitude: -70, Magnitude: 2},
+	}
+
+	got := Decluster(events)
+
+	want := map[int64]Assignment{
+		1: {1, true},
+		2: {1, false},
+		3: {1, false},
+		4: {4, true},
+		5: {5, true},
+		6: {4, false},
+		7: {7, true},
+		8: {7, false},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for id, a := range want {
+		if got[id] != a {
+			t.Errorf("event %d: got %+v, want %+v", id, got[id], a)
+		}
+	}
+}
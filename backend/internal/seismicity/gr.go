@@ -0,0 +1,170 @@
+// Package seismicity has the statistics of earthquake catalogues.
+package seismicity
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrNoEvents is returned when there are no magnitudes to analyze.
+var ErrNoEvents = errors.New("no events")
+
+// Bin is one magnitude bin of a frequency-magnitude distribution.
+type Bin struct {
+	// Magnitude is the centre of the bin.
+	Magnitude float64
+	// Count is the number of events in the bin.
+	Count int
+	// Cumulative is the number of events in this bin or above.
+	Cumulative int
+}
+
+// GutenbergRichter is the fit of log10 N(M >= m) = a - b m to a catalogue.
+type GutenbergRichter struct {
+	BinWidth float64
+	// Bins run without gaps from the smallest to the largest magnitude.
+	Bins []Bin
+	// Mc is the magnitude of completeness, above which every event is taken
+	// to be recorded.
+	Mc float64
+	// N is the number of events at or above Mc, which the fit is made from.
+	N int
+	// A, B and BUncertainty are NaN when the events at or above Mc don't
+	// determine a fit, as when they all have the same magnitude.
+	A            float64
+	B            float64
+	BUncertainty float64
+}
+
+// AnalyzeGutenbergRichter bins magnitudes into bins binWidth wide, estimates
+// the magnitude of completeness by the maximum curvature method and the
+// b-value by Aki's maximum likelihood method.
+func AnalyzeGutenbergRichter(magnitudes []float64, binWidth float64) (GutenbergRichter, error) {
+	gr, err := newGutenbergRichter(magnitudes, binWidth)
+	if err != nil {
+		return GutenbergRichter{}, err
+	}
+
+	gr.Mc = MaxCurvature(gr.Bins)
+	gr.fit()
+
+	return gr, nil
+}
+
+// AnalyzeGutenbergRichterAbove is AnalyzeGutenbergRichter with a known
+// magnitude of completeness, which is rounded to the nearest bin.
+func AnalyzeGutenbergRichterAbove(magnitudes []float64, binWidth, mc float64) (GutenbergRichter, error) {
+	gr, err := newGutenbergRichter(magnitudes, binWidth)
+	if err != nil {
+		return GutenbergRichter{}, err
+	}
+
+	gr.Mc = binMagnitude(binIndex(mc, binWidth), binWidth)
+	gr.fit()
+
+	return gr, nil
+}
+
+func newGutenbergRichter(magnitudes []float64, binWidth float64) (GutenbergRichter, error) {
+	if binWidth <= 0 || math.IsNaN(binWidth) || math.IsInf(binWidth, 0) {
+		return GutenbergRichter{}, fmt.Errorf("bin width must be positive, got %g", binWidth)
+	}
+	if len(magnitudes) == 0 {
+		return GutenbergRichter{}, ErrNoEvents
+	}
+
+	return GutenbergRichter{BinWidth: binWidth, Bins: Bins(magnitudes, binWidth)}, nil
+}
+
+// fit estimates the b-value from the bins at or above Mc. The binned
+// magnitudes are spread over half a bin either side of their centre, which
+// Utsu's correction to Aki's estimator allows for:
+//
+//	b = log10(e) / (mean(M) - (Mc - ΔM/2))
+//
+// with the standard error b / √N.
+func (gr *GutenbergRichter) fit() {
+	gr.A, gr.B, gr.BUncertainty = math.NaN(), math.NaN(), math.NaN()
+
+	lowest := binIndex(gr.Mc, gr.BinWidth)
+	var sum float64
+	for _, bin := range gr.Bins {
+		if binIndex(bin.Magnitude, gr.BinWidth) < lowest {
+			continue
+		}
+		gr.N = gr.N + bin.Count
+		sum = sum + float64(bin.Count)*bin.Magnitude
+	}
+	if gr.N < 2 {
+		return
+	}
+
+	spread := sum/float64(gr.N) - (gr.Mc - gr.BinWidth/2)
+	// with every event in the Mc bin nothing sets the slope
+	if spread <= gr.BinWidth/2+1e-9 {
+		return
+	}
+
+	gr.B = math.Log10E / spread
+	gr.BUncertainty = gr.B / math.Sqrt(float64(gr.N))
+	gr.A = math.Log10(float64(gr.N)) + gr.B*gr.Mc
+}
+
+// Bins counts magnitudes in bins binWidth wide centred on multiples of
+// binWidth, as catalogue magnitudes are rounded to them.
+func Bins(magnitudes []float64, binWidth float64) []Bin {
+	if len(magnitudes) == 0 {
+		return nil
+	}
+	if binWidth <= 0 || math.IsNaN(binWidth) || math.IsInf(binWidth, 0) {
+		panic(fmt.Sprintf("seismicity: bin width must be positive, got %g", binWidth))
+	}
+
+	lowest, highest := math.MaxInt, math.MinInt
+	counts := map[int]int{}
+	for _, m := range magnitudes {
+		i := binIndex(m, binWidth)
+		counts[i] = counts[i] + 1
+		lowest = min(lowest, i)
+		highest = max(highest, i)
+	}
+
+	bins := make([]Bin, highest-lowest+1)
+	cumulative := 0
+	for i := highest; i >= lowest; i-- {
+		cumulative = cumulative + counts[i]
+		bins[i-lowest] = Bin{
+			Magnitude:  binMagnitude(i, binWidth),
+			Count:      counts[i],
+			Cumulative: cumulative,
+		}
+	}
+
+	return bins
+}
+
+// MaxCurvature returns the magnitude of completeness by the maximum curvature
+// method of Wiemer and Wyss (2000): the magnitude of the bin holding the most
+// events, where the non-cumulative distribution is steepest. A tie goes to
+// the smaller magnitude.
+func MaxCurvature(bins []Bin) float64 {
+	mc, most := math.NaN(), -1
+	for _, bin := range bins {
+		if bin.Count > most {
+			mc, most = bin.Magnitude, bin.Count
+		}
+	}
+
+	return mc
+}
+
+func binIndex(m, binWidth float64) int {
+	return int(math.Round(m / binWidth))
+}
+
+// binMagnitude returns the centre of bin i, rounded so a width of 0.1 gives
+// 2.6 rather than 2.6000000000000005.
+func binMagnitude(i int, binWidth float64) float64 {
+	return math.Round(float64(i)*binWidth*1e9) / 1e9
+}
@@ -0,0 +1,118 @@
+package seismicity
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestBins(t *testing.T) {
+	// 2.5999999 is how 2.6 comes back from a 32-bit column
+	bins := Bins([]float64{2.1, 2.5999999, 2.6, 2.4, 2.1, 2.1}, 0.1)
+
+	want := []Bin{
+		{2.1, 3, 6},
+		{2.2, 0, 3},
+		{2.3, 0, 3},
+		{2.4, 1, 3},
+		{2.5, 0, 2},
+		{2.6, 2, 2},
+	}
+	if len(bins) != len(want) {
+		t.Fatalf("got %+v, want %+v", bins, want)
+	}
+	for i := range want {
+		if bins[i] != want[i] {
+			t.Errorf("bin %d: got %+v, want %+v", i, bins[i], want[i])
+		}
+	}
+}
+
+// catalogue returns magnitudes following Gutenberg-Richter with slope b above
+// mc, rounded to bins 0.1 wide, and a tail of missed events below it.
+func catalogue(n int, b, mc float64) []float64 {
+	var magnitudes []float64
+	for i := range n {
+		// the quantiles of the exponential distribution of magnitudes
+		m := mc - 0.05 - math.Log10(1-(float64(i)+0.5)/float64(n))/b
+		magnitudes = append(magnitudes, math.Round(m*10)/10)
+	}
+
+	// fewer and fewer events are recorded below mc
+	for k, below := 1, n/20; below > 0; k, below = k+1, below/2 {
+		for range below {
+			magnitudes = append(magnitudes, math.Round((mc-0.1*float64(k))*10)/10)
+		}
+	}
+
+	return magnitudes
+}
+
+func TestAnalyzeGutenbergRichter(t *testing.T) {
+	gr, err := AnalyzeGutenbergRichter(catalogue(10000, 1, 2.5), 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gr.Mc != 2.5 {
+		t.Errorf("got Mc %g, want 2.5", gr.Mc)
+	}
+	if gr.N != 10000 {
+		t.Errorf("got N %d, want 10000", gr.N)
+	}
+	if math.Abs(gr.B-1) > 0.02 {
+		t.Errorf("got b %g, want 1", gr.B)
+	}
+	if math.Abs(gr.BUncertainty-gr.B/100) > 1e-12 {
+		t.Errorf("got b uncertainty %g, want b/√N", gr.BUncertainty)
+	}
+	// log10 N(M >= Mc) = a - b Mc
+	if math.Abs(gr.A-(4+gr.B*2.5)) > 1e-9 {
+		t.Errorf("got a %g", gr.A)
+	}
+
+	last := gr.Bins[len(gr.Bins)-1]
+	if gr.Bins[0].Cumulative != len(catalogue(10000, 1, 2.5)) || last.Cumulative != last.Count {
+		t.Errorf("got cumulative counts %d to %d", gr.Bins[0].Cumulative, last.Cumulative)
+	}
+}
+
+func TestAnalyzeGutenbergRichterAbove(t *testing.T) {
+	magnitudes := []float64{1.0, 1.0, 1.1, 1.2, 1.4}
+
+	gr, err := AnalyzeGutenbergRichterAbove(magnitudes, 0.1, 1.04)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the mean of 1.0, 1.0, 1.1, 1.2 and 1.4 is 1.14, above Mc - ΔM/2 = 0.95
+	want := math.Log10E / (1.14 - 0.95)
+	if gr.Mc != 1 || gr.N != 5 || math.Abs(gr.B-want) > 1e-9 {
+		t.Errorf("got Mc %g, N %d, b %g, want 1, 5, %g", gr.Mc, gr.N, gr.B, want)
+	}
+
+	gr, err = AnalyzeGutenbergRichterAbove(magnitudes, 0.1, 1.4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gr.N != 1 || !math.IsNaN(gr.B) || !math.IsNaN(gr.A) {
+		t.Errorf("got N %d, b %g, want no fit from one event", gr.N, gr.B)
+	}
+}
+
+func TestAnalyzeGutenbergRichterErrors(t *testing.T) {
+	if _, err := AnalyzeGutenbergRichter(nil, 0.1); !errors.Is(err, ErrNoEvents) {
+		t.Errorf("got %v, want ErrNoEvents", err)
+	}
+	if _, err := AnalyzeGutenbergRichter([]float64{1}, 0); err == nil {
+		t.Error("got no error for a zero bin width")
+	}
+
+	gr, err := AnalyzeGutenbergRichter([]float64{2, 2, 2}, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gr.N != 3 || !math.IsNaN(gr.B) {
+		t.Errorf("got N %d, b %g, want no fit from a single magnitude", gr.N, gr.B)
+	}
+}
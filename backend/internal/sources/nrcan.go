@@ -0,0 +1,48 @@
+package sources
+
+import (
+	"context"
+
+	"github.com/earthquake-service/internal/feeditem"
+	"github.com/earthquake-service/internal/models"
+	"github.com/mmcdole/gofeed"
+)
+
+// NRCan reads the Earthquakes Canada atom feed with georss extensions.
+type NRCan struct {
+	name string
+	url  string
+}
+
+func NewNRCan(name, url string) *NRCan {
+	return &NRCan{name: name, url: url}
+}
+
+func (s *NRCan) Name() string {
+	return s.name
+}
+
+func (s *NRCan) Fetch(ctx context.Context) ([]models.Entry, []Skipped, error) {
+	fp := gofeed.Parser{}
+	feed, err := fp.ParseURLWithContext(s.url, ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries, skipped := s.parse(feed)
+	return entries, skipped, nil
+}
+
+func (s *NRCan) parse(feed *gofeed.Feed) (entries []models.Entry, skipped []Skipped) {
+	for _, item := range feed.Items {
+		parsed, err := feeditem.Parse(item)
+		if err != nil {
+			skipped = append(skipped, Skipped{ID: item.GUID, Title: item.Title, Reason: err})
+			continue
+		}
+
+		entries = append(entries, parsed.Entry())
+	}
+
+	return entries, skipped
+}
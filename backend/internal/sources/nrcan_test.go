@@ -0,0 +1,57 @@
+package sources
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/earthquake-service/internal/feeditem"
+	"github.com/mmcdole/gofeed"
+)
+
+func TestNRCanParse(t *testing.T) {
+	f, err := os.Open("testdata/nrcan.atom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fp := gofeed.Parser{}
+	feed, err := fp.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, skipped := NewNRCan("nrcan", "").parse(feed)
+
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+
+	got := entries[0]
+	if got.GUID != "tag:earthquakescanada.nrcan.gc.ca,2025:20251016.2200001" {
+		t.Errorf("GUID = %q", got.GUID)
+	}
+	if got.Magnitude != 2.6 || got.MagnitudeType != "ML" {
+		t.Errorf("Magnitude = %v %s, want 2.6 ML", got.Magnitude, got.MagnitudeType)
+	}
+	if got.Place != "12 km NNE of Blaine, WA" {
+		t.Errorf("Place = %q", got.Place)
+	}
+	if got.Elevation != -18400 {
+		t.Errorf("Elevation = %d, want -18400", got.Elevation)
+	}
+
+	// the entry without a magnitude is reported rather than dropped
+	if len(skipped) != 1 {
+		t.Fatalf("got %d skipped, want 1", len(skipped))
+	}
+	if skipped[0].ID != "tag:earthquakescanada.nrcan.gc.ca,2025:20251016.2100001" {
+		t.Errorf("skipped ID = %q", skipped[0].ID)
+	}
+
+	var fieldErr *feeditem.FieldError
+	if !errors.As(skipped[0].Reason, &fieldErr) || fieldErr.Field != feeditem.FieldMagnitude {
+		t.Errorf("skipped reason = %v, want a magnitude error", skipped[0].Reason)
+	}
+}
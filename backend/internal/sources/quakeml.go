@@ -0,0 +1,60 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/earthquake-service/internal/models"
+	"github.com/earthquake-service/internal/quakeml"
+)
+
+// QuakeML reads events from a QuakeML 1.2 document, such as an FDSN event
+// web service query.
+type QuakeML struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+func NewQuakeML(name, url string) *QuakeML {
+	return &QuakeML{name: name, url: url, client: http.DefaultClient}
+}
+
+func (s *QuakeML) Name() string {
+	return s.name
+}
+
+func (s *QuakeML) Fetch(ctx context.Context) (entries []models.Entry, skipped []Skipped, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("fetching %s: unexpected status %s", s.url, res.Status)
+	}
+
+	doc, err := quakeml.Decode(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, event := range doc.EventParameters.Events {
+		entry, err := event.Entry()
+		if err != nil {
+			skipped = append(skipped, Skipped{ID: event.PublicID, Reason: err})
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, skipped, nil
+}
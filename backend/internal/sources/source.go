@@ -0,0 +1,36 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+// Source is a provider of earthquake events. Items of a feed that can't be
+// read are returned as skipped rather than failing the whole fetch.
+type Source interface {
+	Name() string
+	Fetch(ctx context.Context) ([]models.Entry, []Skipped, error)
+}
+
+// Skipped is a feed item that was not converted into an entry.
+type Skipped struct {
+	ID     string
+	Title  string
+	Reason error
+}
+
+// New creates a source of the given kind reading from url.
+func New(kind, name, url string) (Source, error) {
+	switch kind {
+	case "nrcan":
+		return NewNRCan(name, url), nil
+	case USGSNamespace:
+		return NewUSGS(name, url), nil
+	case "quakeml":
+		return NewQuakeML(name, url), nil
+	default:
+		return nil, fmt.Errorf("unknown source kind %q", kind)
+	}
+}
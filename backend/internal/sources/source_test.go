@@ -0,0 +1,32 @@
+package sources
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	for _, tt := range []struct {
+		kind string
+		want string
+	}{
+		{"nrcan", "*sources.NRCan"},
+		{"usgs", "*sources.USGS"},
+		{"quakeml", "*sources.QuakeML"},
+	} {
+		s, err := New(tt.kind, "feed", "https://example.com/feed")
+		if err != nil {
+			t.Errorf("%s: %s", tt.kind, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", s); got != tt.want || s.Name() != "feed" {
+			t.Errorf("%s: got %s named %q", tt.kind, got, s.Name())
+		}
+	}
+
+	for _, kind := range []string{"", "emsc", "NRCAN"} {
+		if s, err := New(kind, "feed", "https://example.com/feed"); err == nil {
+			t.Errorf("%q: got %T, want an error", kind, s)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/earthquake-service/internal/models"
+)
+
+// USGSNamespace prefixes GUIDs and categories of USGS events so they never
+// collide with events from other providers.
+const USGSNamespace = "usgs"
+
+var (
+	ErrNoID        = errors.New("feature has no id")
+	ErrNoMagnitude = errors.New("magnitude is unset")
+	ErrNoGeometry  = errors.New("geometry is incomplete")
+)
+
+// USGS reads a USGS GeoJSON summary feed.
+// https://earthquake.usgs.gov/earthquakes/feed/v1.0/geojson.php
+type USGS struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+type usgsFeatureCollection struct {
+	Features []usgsFeature `json:"features"`
+}
+
+type usgsFeature struct {
+	ID         string `json:"id"`
+	Properties struct {
+		Mag     *float64 `json:"mag"`
+		MagType string   `json:"magType"`
+		Place   string   `json:"place"`
+		Time    int64    `json:"time"`
+		Updated int64    `json:"updated"`
+		Type    string   `json:"type"`
+		Title   string   `json:"title"`
+		URL     string   `json:"url"`
+	} `json:"properties"`
+	Geometry struct {
+		Coordinates []float64 `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+func NewUSGS(name, url string) *USGS {
+	return &USGS{name: name, url: url, client: http.DefaultClient}
+}
+
+func (s *USGS) Name() string {
+	return s.name
+}
+
+func (s *USGS) Fetch(ctx context.Context) ([]models.Entry, []Skipped, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("fetching %s: unexpected status %s", s.url, res.Status)
+	}
+
+	return s.parse(res.Body)
+}
+
+func (s *USGS) parse(r io.Reader) (entries []models.Entry, skipped []Skipped, err error) {
+	var fc usgsFeatureCollection
+	if err := json.NewDecoder(r).Decode(&fc); err != nil {
+		return nil, nil, fmt.Errorf("decoding geojson: %w", err)
+	}
+
+	for _, feature := range fc.Features {
+		p := feature.Properties
+
+		if feature.ID == "" {
+			skipped = append(skipped, Skipped{Title: p.Title, Reason: ErrNoID})
+			continue
+		}
+		if p.Mag == nil {
+			skipped = append(skipped, Skipped{ID: feature.ID, Title: p.Title, Reason: ErrNoMagnitude})
+			continue
+		}
+		if len(feature.Geometry.Coordinates) < 3 {
+			skipped = append(skipped, Skipped{ID: feature.ID, Title: p.Title, Reason: ErrNoGeometry})
+			continue
+		}
+
+		// times are milliseconds since the epoch
+		t := time.UnixMilli(p.Time).UTC()
+		updated := time.UnixMilli(p.Updated).UTC()
+
+		categories := []string{USGSNamespace}
+		if p.Type != "" {
+			categories = append(categories, p.Type)
+		}
+
+		coords := feature.Geometry.Coordinates
+
+		// depth is in km below the surface, elevation is metres above it
+		elevation := int32(math.Round(-coords[2] * 1000))
+
+		entries = append(entries, models.Entry{
+			GUID:          USGSNamespace + ":" + feature.ID,
+			Title:         p.Title,
+			Content:       p.Place,
+			Place:         p.Place,
+			Categories:    strings.Join(categories, ", "),
+			Longitude:     float32(coords[0]),
+			Latitude:      float32(coords[1]),
+			Elevation:     elevation,
+			Magnitude:     float32(*p.Mag),
+			MagnitudeType: p.MagType,
+			Time:          &t,
+			Published:     &t,
+			Updated:       &updated,
+		})
+	}
+
+	return entries, skipped, nil
+}
@@ -0,0 +1,108 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func newTestUSGS(url string) *USGS {
+	return NewUSGS("usgs", url)
+}
+
+func TestUSGSParse(t *testing.T) {
+	f, err := os.Open("testdata/usgs_summary.geojson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	entries, skipped, err := newTestUSGS("").parse(f)
+	if err != nil {
+		t.Fatalf("parse: unexpected error %s", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	// the feature without a magnitude is skipped
+	if len(skipped) != 1 || skipped[0].ID != "nc75000001" || !errors.Is(skipped[0].Reason, ErrNoMagnitude) {
+		t.Errorf("skipped = %+v, want nc75000001 with %v", skipped, ErrNoMagnitude)
+	}
+
+	got := entries[0]
+	if got.GUID != "usgs:uw62100001" {
+		t.Errorf("GUID = %q, want %q", got.GUID, "usgs:uw62100001")
+	}
+	if got.Categories != "usgs, earthquake" {
+		t.Errorf("Categories = %q, want %q", got.Categories, "usgs, earthquake")
+	}
+	if got.Title != "M 2.6 - 12 km NNE of Blaine, Washington" {
+		t.Errorf("Title = %q", got.Title)
+	}
+	if got.Magnitude != 2.61 {
+		t.Errorf("Magnitude = %v, want 2.61", got.Magnitude)
+	}
+	if got.MagnitudeType != "ml" {
+		t.Errorf("MagnitudeType = %q, want %q", got.MagnitudeType, "ml")
+	}
+	if got.Place != "12 km NNE of Blaine, Washington" {
+		t.Errorf("Place = %q", got.Place)
+	}
+	if got.Latitude != 49.1032 || got.Longitude != -122.6891 {
+		t.Errorf("Latitude, Longitude = %v, %v, want 49.1032, -122.6891", got.Latitude, got.Longitude)
+	}
+	if got.Elevation != -18420 {
+		t.Errorf("Elevation = %d, want -18420", got.Elevation)
+	}
+
+	wantTime := time.Date(2025, 10, 16, 22, 0, 0, 500_000_000, time.UTC)
+	if got.Time == nil || !got.Time.Equal(wantTime) {
+		t.Errorf("Time = %v, want %v", got.Time, wantTime)
+	}
+	if got.Updated == nil || got.Updated.UnixMilli() != 1760655600000 {
+		t.Errorf("Updated = %v", got.Updated)
+	}
+
+	if entries[1].GUID != "usgs:ak0251abcd" {
+		t.Errorf("GUID = %q, want %q", entries[1].GUID, "usgs:ak0251abcd")
+	}
+}
+
+func TestUSGSParseTruncated(t *testing.T) {
+	f, err := os.Open("testdata/usgs_truncated.geojson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	_, _, err = newTestUSGS("").parse(f)
+	if err == nil {
+		t.Fatal("parse: expected error for truncated feed")
+	}
+}
+
+func TestUSGSFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/usgs_summary.geojson")
+	}))
+	defer srv.Close()
+
+	entries, _, err := newTestUSGS(srv.URL).Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch: unexpected error %s", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	srv.Config.Handler = http.NotFoundHandler()
+	if _, _, err := newTestUSGS(srv.URL).Fetch(context.Background()); err == nil {
+		t.Fatal("Fetch: expected error for 404 response")
+	}
+}